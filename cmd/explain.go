@@ -0,0 +1,65 @@
+// Copyright © 2018 Science and Technology Facilities Council (UK) <will.rowe@stfc.ac.uk>
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/will-rowe/hulk/src/misc"
+	"github.com/will-rowe/thor/src/run"
+)
+
+// the command line arguments
+var (
+	exSaliency       *[]string // the saliency maps
+	exLegends        *[]string // the row legends for the saliency maps
+	exColourSketches *string   // the reference colour sketches used to make the images
+)
+
+// explainCmd represents the explain command
+var explainCmd = &cobra.Command{
+	Use:   "explain",
+	Short: "Map saliency maps over thor images back to the taxa and sketch bins they were drawn from",
+	Long: `Map saliency maps over thor images back to the taxa and sketch bins they were drawn from.
+
+Each saliency map (a .npy array of height x width (x channels), or a grayscale PNG) is paired
+with the row legend that thor hammer wrote for the image. The absolute saliency is summed for
+each image row, genus and sketch bin (image column), and written as:
+
+	<outFile>.thor-explain-rows.tsv		the importance of each image row
+	<outFile>.thor-explain-genera.tsv	the genera of each sample, ranked by importance
+	<outFile>.thor-explain-bins.tsv		the importance of each sketch bin, with its top genus
+	<outFile>.thor-explain-cohort.tsv	the genera ranked by their mean share of the saliency
+
+Samples are named as in their row legend.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runExplain()
+	},
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return misc.CheckRequiredFlags(cmd.Flags())
+	},
+}
+
+// a function to initialise the command line arguments
+func init() {
+	exSaliency = explainCmd.Flags().StringSliceP("saliency", "s", []string{}, "the saliency map(s) (.npy or grayscale .png), one for each image")
+	exLegends = explainCmd.Flags().StringSliceP("legends", "l", []string{}, "the row legend(s) written by thor hammer, in the same order as the saliency maps")
+	exColourSketches = explainCmd.Flags().StringP("colourSketches", "c", "", "the set of reference colour sketches used to make the images")
+	explainCmd.MarkFlagRequired("saliency")
+	explainCmd.MarkFlagRequired("legends")
+	explainCmd.MarkFlagRequired("colourSketches")
+	explainCmd.Flags().SortFlags = false
+	RootCmd.AddCommand(explainCmd)
+}
+
+/*
+  The main function for the explain subcommand
+*/
+func runExplain() error {
+	defer startRun()()
+	return run.Explain(&run.ExplainOptions{
+		Saliency:       *exSaliency,
+		Legends:        *exLegends,
+		ColourSketches: *exColourSketches,
+		OutFile:        *outFile,
+	})
+}
@@ -3,23 +3,12 @@
 package cmd
 
 import (
-	//"encoding/csv"
-	"fmt"
-	"log"
-	"os"
-
-	"github.com/pkg/profile"
 	"github.com/spf13/cobra"
 	"github.com/will-rowe/hulk/src/misc"
-	"github.com/will-rowe/thor/src/colour"
-	"github.com/will-rowe/thor/src/draw"
 	"github.com/will-rowe/thor/src/hammer"
-	"github.com/will-rowe/thor/src/version"
+	"github.com/will-rowe/thor/src/run"
 )
 
-// the currently supported otu table formats
-var supportedFormats [1]string = [1]string{"qiime"}
-
 // the command line arguments
 var (
 	otuTables      *[]string // the input OTU tables
@@ -27,20 +16,51 @@ var (
 	colourSketches *string   // the reference colour sketches
 	alphaAbundance *bool     // replace the alpha channel of the colour sketch with the OTU abundance
 	padding        *bool     // pad out the image with white pixels if OTUs are absent
+	abundance      *string   // how to scale abundances to pixel values
+	bitDepth       *int      // the bits per channel of the PNGs
+	spectrumFile   *string   // a spectrum file that the colour sketches must be compatible with
+	taxonomyFile   *string   // a taxonomy file for OTU tables without a taxonomy column
+	minConfidence  *float64  // the minimum confidence for a taxonomy file assignment
+	label          *string   // the distance label to use from mothur shared files
+	rank           *string   // the rank to use from taxonomic profiles
+	synonyms       *string   // a synonym table for matching genera to the colour sketches
+	taxdump        *string   // an NCBI taxdump directory for matching genera to the colour sketches
+	rowOrder       *string   // how to order the rows of each image
+	orderFile      *string   // a file listing the genera in row order
+	tree           *string   // a Newick tree of the reference genera
+	minAbundance   *float64  // the minimum abundance of an OTU in a sample
+	minRelAbun     *float64  // the minimum relative abundance of an OTU in a sample
+	minPrevalence  *float64  // the minimum fraction of samples an OTU must be in
+	augment        *int      // the number of augmented variants to make of each sample
+	augmentSeed    *int64    // the seed for the augmented variants
+	subsample      *float64  // the fraction of counts kept when subsampling a variant
+	dropout        *float64  // the probability of dropping a low abundance OTU from a variant
+	dropoutBelow   *float64  // the relative abundance below which an OTU may be dropped
+	noise          *float64  // the standard deviation of the log-normal noise applied to variant abundances
+	tensor         *string   // also write each sample as an N-channel tensor in this format
+	channels       *[]string // the tensor channels to write
+	legendTable    *bool     // also write a long format legend table for the run
 )
 
 // hammerCmd represents the hammer command
 var hammerCmd = &cobra.Command{
 	Use:   "hammer",
 	Short: "Hammer an OTU table into an image...",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
+	Long: `Hammer an OTU table into an image...
 
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		runHammer()
+Each sample in the OTU table(s) is coloured with the reference colour sketches of its most
+abundant genera, then drawn, encoded and written as a PNG. The samples of each table are
+sorted and hammered by up to --processors workers at a time; the output doesn't depend
+on the number of workers.
+
+Memory: the tables are hammered one at a time, with the next table read while the current
+one is hammered, so no more than two tables are held in memory at once. QIIME and BIOM
+tables hold a column per sample, so each table is read fully before its samples are
+hammered; a run needs enough memory for its largest table, not for every sample in the run.
+Single sample profiles (kraken2, bracken, metaphlan) are combined into one table first, and
+the mean row order reads every table before the run starts.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHammer()
 	},
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		return misc.CheckRequiredFlags(cmd.Flags())
@@ -49,130 +69,81 @@ to quickly create a Cobra application.`,
 
 // a function to initialise the command line arguments
 func init() {
-	otuTables = hammerCmd.Flags().StringSliceP("otuTables", "i", []string{}, "input OTU table(s) to transform to hashed OTU RGBA images")
-	format = hammerCmd.Flags().StringP("otuFormat", "f", "qiime", "the format of the input OTU table(s) (only QIIME currently supported)")
+	otuTables = hammerCmd.Flags().StringSliceP("otuTables", "i", []string{}, "input OTU table(s) to transform to hashed OTU RGBA images (can be gzip/bzip2/zstd compressed, use - for STDIN)")
+	format = hammerCmd.Flags().StringP("otuFormat", "f", "qiime", "the format of the input OTU table(s) (qiime, mothur, dada2, biom (JSON or HDF5) or qza (QIIME2 feature table)), or of single sample profiles (kraken2, bracken or metaphlan)")
 	colourSketches = hammerCmd.Flags().StringP("colourSketches", "c", "", "the set of reference colour sketches (from `thor colour`)")
 	alphaAbundance = hammerCmd.Flags().Bool("alphaAbundance", false, "include the OTU abundance (replaces existing alpha value of colour sketches) --NOT SUPPORTED YET!")
 	padding = hammerCmd.Flags().Bool("padding", false, "pad out images with rows of white pixels if OTUs are absent")
+	abundance = hammerCmd.Flags().String("abundance", "auto", "how to scale abundances to pixel values (auto, counts or relative), auto treats a table as relative if every sample totals 1 (MetaPhlAn profiles are always relative)")
+	bitDepth = hammerCmd.Flags().Int("bitDepth", 8, "bits per channel of the PNGs (8: sketch in R+G and abundance in B, 16: sketch in R and abundance in B, with 65536 abundance levels)")
+	spectrumFile = hammerCmd.Flags().String("spectrum", "", "check the colour sketches were built with a spectrum compatible with this .spectrum file")
+	taxonomyFile = hammerCmd.Flags().StringP("taxonomy", "t", "", "a taxonomy file (QIIME2 taxonomy.tsv or .qza, DADA2 assignTaxonomy csv, or OTU id and SILVA/Greengenes/GTDB lineage), needed for OTU tables without a taxonomy column")
+	minConfidence = hammerCmd.Flags().Float64("minConfidence", 0, "ignore taxonomy file assignments with a confidence below this value")
+	label = hammerCmd.Flags().String("label", "", "the distance label to use from mothur shared files (defaults to the first label, use with a .cons.taxonomy --taxonomy file)")
+	rank = hammerCmd.Flags().String("rank", "genus", "the rank to use from kraken2, bracken and metaphlan profiles (domain, phylum, class, order, family, genus or species)")
+	synonyms = hammerCmd.Flags().String("synonyms", "", "a tab separated synonym table (synonym, accepted name) used to match genera to the colour sketches")
+	taxdump = hammerCmd.Flags().String("taxdump", "", "an NCBI taxdump directory (names.dmp and nodes.dmp) used to match genera to the colour sketches via their taxid")
+	rowOrder = hammerCmd.Flags().String("rowOrder", "abundance", "how to order the image rows (abundance, store, mean, fixed or phylogeny), all but abundance give each genus the same row in every image and need --padding")
+	orderFile = hammerCmd.Flags().String("orderFile", "", "a file listing the genera in row order (one per line), for --rowOrder fixed")
+	tree = hammerCmd.Flags().String("tree", "", "a Newick tree of the reference genera, for --rowOrder phylogeny (leaves are matched to the colour sketches)")
+	minAbundance = hammerCmd.Flags().Float64("minAbundance", 0, "drop OTUs with an abundance below this value in a sample")
+	minRelAbun = hammerCmd.Flags().Float64("minRelAbundance", 0, "drop OTUs with a relative abundance (fraction of the sample total) below this value in a sample")
+	minPrevalence = hammerCmd.Flags().Float64("minPrevalence", 0, "drop OTUs that pass the abundance filters in less than this fraction of a table's samples")
+	augment = hammerCmd.Flags().Int("augment", 0, "also write this many augmented variants of each sample (subsampled, with low abundance OTUs dropped and noise added), listed in <outFile>.thor-augment.tsv")
+	augmentSeed = hammerCmd.Flags().Int64("augmentSeed", 1, "the seed for the augmented variants")
+	subsample = hammerCmd.Flags().Float64("subsample", 0.8, "the fraction of counts kept when subsampling each variant (not used for relative abundances)")
+	dropout = hammerCmd.Flags().Float64("dropout", 0.1, "the probability of dropping each low abundance OTU from a variant")
+	dropoutBelow = hammerCmd.Flags().Float64("dropoutBelow", 0.01, "OTUs with a relative abundance below this value can be dropped from a variant")
+	noise = hammerCmd.Flags().Float64("noise", 0.1, "the standard deviation of the log-normal noise applied to the abundances of a variant")
+	tensor = hammerCmd.Flags().String("tensor", "", "also write each sample as an N-channel tensor (npy, tiff (one 32-bit float page per channel), hdf5 (tensor, channels and channel_max datasets) or png (projection of the first 4 channels))")
+	channels = hammerCmd.Flags().StringSlice("channels", []string{}, "the tensor channels to write (sketch, sketch_lo, sketch_hi, abundance, prevalence, mask, depth), defaults to sketch_lo,sketch_hi,abundance,mask")
+	legendTable = hammerCmd.Flags().Bool("legendTable", false, "also write a long format table of the row legends for every sample (<outFile>.thor-legend.tsv), a legend is always written for each image")
 	hammerCmd.MarkFlagRequired("otuTables")
 	hammerCmd.MarkFlagRequired("colourSketches")
 	hammerCmd.Flags().SortFlags = false
 	RootCmd.AddCommand(hammerCmd)
 }
 
-// check the program input
-func checkInput() error {
-	// check specified format is supported
-	var check bool
-	for _, sf := range supportedFormats {
-		if sf == *format {
-			check = true
-		}
-	}
-	if check == false {
-		return fmt.Errorf("OTU table format not supported: %v", *format)
-	}
-	// check the OTU tables
-	for _, otuTable := range *otuTables {
-		if _, err := os.Stat(otuTable); err != nil {
-			if os.IsNotExist(err) {
-				return fmt.Errorf("file does not exist: %v", otuTable)
-			} else {
-				return fmt.Errorf("can't access file (check permissions): %v", otuTable)
-			}
-		}
-		// TODO: check the supplied file is actually an OTU table
-
-		// TODO: check that the supplied file is in the specified format
-
-	}
-	// check the colour sketch file
-	if *colourSketches == "" {
-		return fmt.Errorf("require --colourSketches, run `thor colour` if you haven't already")
-	}
-	if _, err := os.Stat(*colourSketches); err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("file does not exist: %v", *colourSketches)
-		} else {
-			return fmt.Errorf("can't access file (check permissions): %v", *colourSketches)
-		}
-	}
-	return nil
-}
-
 /*
   The main function for the hammer subcommand
 */
-func runHammer() {
-	// set up profiling
-	if *profiling == true {
-		//defer profile.Start(profile.MemProfile, profile.ProfilePath("./")).Stop()
-		defer profile.Start(profile.ProfilePath("./")).Stop()
-	}
-	// start logging
-	logFH := misc.StartLogging((*outFile + ".log"))
-	defer logFH.Close()
-	log.SetOutput(logFH)
-	log.Printf("thor (version %s)", version.VERSION)
-	log.Printf("starting the hammer subcommand")
-	// check the supplied files and then log some stuff
-	log.Printf("checking parameters...")
-	misc.ErrorCheck(checkInput())
-	log.Printf("\tinput OTU tables:")
-	for _, file := range *otuTables {
-		log.Printf("\t\t%v", file)
-	}
-	log.Printf("\tOTU table format: %v", *format)
-	log.Printf("\toutput file basename: %v", *outFile)
-	log.Printf("\tinclude OTU abundance: %t", *alphaAbundance)
-	log.Printf("\tpad PNG: %t", *padding)
-	log.Printf("\tcolour sketches: %v", *colourSketches)
-	// load the reference colour sketches
-	css := make(colour.ColourSketchStore)
-	misc.ErrorCheck(css.Load(*colourSketches))
-	sketchLength := css.GetSketchLength()
-	log.Printf("\tsketch length: %d", sketchLength)
-	// process each OTU table
-	log.Printf("processing OTU table(s)...")
-	// TODO: should I make this run concurrently?
-	for i, otuTable := range *otuTables {
-		// read the OTU table
-		table, err := hammer.NewOTUtable(otuTable, *format)
-		misc.ErrorCheck(err)
-		log.Printf("\ttable %d: %v", (i + 1), otuTable)
-		log.Printf("\tnum. samples: %d", table.GetNumSamples())
-		log.Printf("\tnum. OTU ids at genus level: %d", table.GetTotalGenusOTUs())
-		// get the top N most abundant OTUs for each sample
-		misc.ErrorCheck(table.KeepTopN(sketchLength))
-		// attach the colour sketch store, parse top OTUs, lookup the coloursketches and keep corresponding rgba slices for each sample
-		sampleRGBAs, err := table.ColourTopN(css, *padding)
-		misc.ErrorCheck(err)
-		// process each sample, collecting the pixel vectors
-		for j, sampleRGBA := range sampleRGBAs {
-			// create the canvas
-			// TODO: this is created as a square for now
-			img, err := draw.NewThorPNG(sketchLength, sketchLength)
-			misc.ErrorCheck(err)
-			// collect the pixel vectors
-			for _, line := range sampleRGBA {
-				// if padding is not requested, skip this line
-				if line == nil {
-					if *padding == false {
-						continue
-					}
-					// TODO: if OTU not found in RefSeq, a nil line will be returned - need to handle this!
-					continue
-					//misc.ErrorCheck(fmt.Errorf("nil line found in coloured OTU table"))
-				}
-				err := img.DrawOTU(line)
-				misc.ErrorCheck(err)
-			}
-			// write the png
-			sample, err := table.GetSampleName(j)
-			misc.ErrorCheck(err)
-			filename := fmt.Sprintf("%v-%v.thor-image.png", *outFile, sample)
-			misc.ErrorCheck(img.Save(filename, *padding))
-		}
-	}
-
+func runHammer() error {
+	defer startRun()()
+	return run.Hammer(&run.HammerOptions{
+		OTUtables:      *otuTables,
+		Format:         *format,
+		ColourSketches: *colourSketches,
+		AlphaAbundance: *alphaAbundance,
+		Padding:        *padding,
+		Abundance:      *abundance,
+		BitDepth:       *bitDepth,
+		Spectrum:       *spectrumFile,
+		Taxonomy:       *taxonomyFile,
+		MinConfidence:  *minConfidence,
+		Label:          *label,
+		Rank:           *rank,
+		Synonyms:       *synonyms,
+		Taxdump:        *taxdump,
+		RowOrder:       *rowOrder,
+		OrderFile:      *orderFile,
+		Tree:           *tree,
+		Filter: hammer.Filter{
+			MinAbundance:         *minAbundance,
+			MinRelativeAbundance: *minRelAbun,
+			MinPrevalence:        *minPrevalence,
+		},
+		Augment: hammer.Augmentation{
+			Variants:         *augment,
+			Seed:             *augmentSeed,
+			Subsample:        *subsample,
+			DropoutThreshold: *dropoutBelow,
+			Dropout:          *dropout,
+			Noise:            *noise,
+		},
+		Tensor:      *tensor,
+		Channels:    *channels,
+		LegendTable: *legendTable,
+		OutFile:     *outFile,
+		Processors:  *proc,
+	})
 }
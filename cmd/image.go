@@ -0,0 +1,97 @@
+// Copyright © 2018 Science and Technology Facilities Council (UK) <will.rowe@stfc.ac.uk>
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/will-rowe/hulk/src/misc"
+	"github.com/will-rowe/thor/src/run"
+)
+
+// the command line arguments
+var (
+	imgReads         *[]string // the read file(s) for the sample
+	imgFasta         *bool     // the read file(s) are FASTA rather than FASTQ
+	imgSample        *string   // the sample name
+	imgLayout        *string   // how to lay out the image rows
+	imgColourSketch  *string   // the reference colour sketches (required for the nearest layout)
+	imgKsize         *int      // size of k-mer
+	imgEpsilon       *float64  // epsilon value for countminsketch generation
+	imgDelta         *float64  // delta value for countminsketch generation
+	imgMinCount      *int      // minimum k-mer count for it to be histosketched
+	imgSketchSize    *uint     // size of sketch
+	imgQualTrim      *bool     // quality trim the ends of reads
+	imgMinQual       *int      // minimum base quality used when trimming
+	imgMinReadLength *int      // minimum read length after trimming
+	imgSpectrum      *string   // a spectrum file from a previous run, to make sure the sketches are comparable
+)
+
+// imageCmd represents the image command
+var imageCmd = &cobra.Command{
+	Use:   "image",
+	Short: "Sketch a sample's reads and hammer the sketch directly into an image (no OTU table needed)",
+	Long: `Sketch a sample's reads and hammer the sketch directly into an image (no OTU table needed).
+
+The reads are histosketched using the same pipeline as thor sketch, the histosketch is coloured
+using the same encoding as thor colour, and then an image is drawn using one of two layouts:
+
+	tile:		the sample's coloured sketch is repeated on every row
+	nearest:	the first row is the sample's coloured sketch, the remaining rows are the most
+			similar reference sketches from the --colourSketches store (in order of
+			decreasing similarity, with the similarity encoded in the B slot)`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runImage()
+	},
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := misc.CheckRequiredFlags(cmd.Flags()); err != nil {
+			return err
+		}
+		return checkSpectrumFlags(cmd.Flags())
+	},
+}
+
+// a function to initialise the command line arguments
+func init() {
+	imgReads = imageCmd.Flags().StringSliceP("reads", "i", []string{}, "read file(s) for a single sample (e.g. R1,R2), can be gzipped and/or interleaved")
+	imgFasta = imageCmd.Flags().Bool("fasta", false, "the read file(s) are FASTA rather than FASTQ")
+	imgSample = imageCmd.Flags().StringP("sampleName", "n", "", "name of the sample (defaults to the basename of the first read file)")
+	imgLayout = imageCmd.Flags().StringP("layout", "l", "tile", "how to lay out the image rows (tile or nearest)")
+	imgColourSketch = imageCmd.Flags().StringP("colourSketches", "c", "", "the set of reference colour sketches (from `thor colour`), needed for --layout nearest")
+	imgKsize = imageCmd.Flags().IntP("kmerSize", "k", 21, "size of k-mer")
+	imgEpsilon = imageCmd.Flags().Float64P("epsilon", "e", 0.00001, "epsilon value for countminsketch generation")
+	imgDelta = imageCmd.Flags().Float64P("delta", "d", 0.90, "delta value for countminsketch generation")
+	imgMinCount = imageCmd.Flags().IntP("minCount", "m", 1, "minimum k-mer count for it to be histosketched (increase for reads to suppress sequencing errors)")
+	imgSketchSize = imageCmd.Flags().UintP("sketchSize", "s", 200, "size of sketch")
+	imgQualTrim = imageCmd.Flags().Bool("qualTrim", false, "quality trim the ends of FASTQ reads before sketching")
+	imgMinQual = imageCmd.Flags().Int("minQual", 20, "minimum base quality used by --qualTrim")
+	imgMinReadLength = imageCmd.Flags().Int("minReadLength", 0, "minimum read length after trimming (defaults to k-mer size)")
+	imgSpectrum = imageCmd.Flags().String("reuseSpectrum", "", "reuse the sketching parameters from a .spectrum file, the countmin hash fingerprint must also match (can't be used with -k/-e/-d/-m/-s)")
+	imageCmd.MarkFlagRequired("reads")
+	imageCmd.Flags().SortFlags = false
+	RootCmd.AddCommand(imageCmd)
+}
+
+/*
+  The main function for the image subcommand
+*/
+func runImage() error {
+	defer startRun()()
+	return run.Image(&run.ImageOptions{
+		Reads:          *imgReads,
+		Fasta:          *imgFasta,
+		SampleName:     *imgSample,
+		Layout:         *imgLayout,
+		ColourSketches: *imgColourSketch,
+		KmerSize:       *imgKsize,
+		Epsilon:        *imgEpsilon,
+		Delta:          *imgDelta,
+		MinCount:       *imgMinCount,
+		SketchSize:     *imgSketchSize,
+		QualTrim:       *imgQualTrim,
+		MinQual:        *imgMinQual,
+		MinReadLength:  *imgMinReadLength,
+		ReuseSpectrum:  *imgSpectrum,
+		OutFile:        *outFile,
+		Processors:     *proc,
+	})
+}
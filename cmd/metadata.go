@@ -0,0 +1,52 @@
+// Copyright © 2018 Science and Technology Facilities Council (UK) <will.rowe@stfc.ac.uk>
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/will-rowe/hulk/src/misc"
+	"github.com/will-rowe/thor/src/run"
+)
+
+// the command line arguments
+var (
+	mdImages *[]string // the images to read the metadata from
+	mdJSON   *bool     // print the metadata as JSON
+)
+
+// metadataCmd represents the metadata command
+var metadataCmd = &cobra.Command{
+	Use:   "metadata",
+	Short: "Print the metadata that thor hammer embeds in each image",
+	Long: `Print the metadata that thor hammer embeds in each image.
+
+thor hammer writes PNG text chunks describing how each image was made: the sample name,
+the source OTU table, the colour sketch store (and its checksum), the genus drawn on each
+row and how the abundances were normalised. This prints them as tab separated lines
+(image, keyword, quoted text) or as JSON.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runMetadata()
+	},
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return misc.CheckRequiredFlags(cmd.Flags())
+	},
+}
+
+// a function to initialise the command line arguments
+func init() {
+	mdImages = metadataCmd.Flags().StringSliceP("images", "i", []string{}, "the image(s) to read the metadata from")
+	mdJSON = metadataCmd.Flags().Bool("json", false, "print the metadata as JSON")
+	metadataCmd.MarkFlagRequired("images")
+	metadataCmd.Flags().SortFlags = false
+	RootCmd.AddCommand(metadataCmd)
+}
+
+/*
+  The main function for the metadata subcommand
+*/
+func runMetadata() error {
+	return run.Metadata(&run.MetadataOptions{
+		Images: *mdImages,
+		JSON:   *mdJSON,
+	})
+}
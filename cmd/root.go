@@ -4,11 +4,18 @@ package cmd
 
 import (
 	"fmt"
-	"github.com/spf13/cobra"
+	"log"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
+
+	"github.com/pkg/profile"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/will-rowe/hulk/src/misc"
+	"github.com/will-rowe/thor/src/version"
 )
 
 // the command line arguments
@@ -28,6 +35,9 @@ var RootCmd = &cobra.Command{
 THOR is a tool that...
 
 It works by ...`,
+	// errors are printed by Execute, so don't let cobra print them (or the usage) as well
+	SilenceErrors: true,
+	SilenceUsage:  true,
 }
 
 /*
@@ -72,3 +82,38 @@ func persChecker() error {
 	runtime.GOMAXPROCS(*proc)
 	return nil
 }
+
+// checkSpectrumFlags makes sure the sketching parameters aren't set along with --reuseSpectrum, which supplies them
+func checkSpectrumFlags(flags *pflag.FlagSet) error {
+	if !flags.Changed("reuseSpectrum") {
+		return nil
+	}
+	var conflicts []string
+	for _, name := range []string{"kmerSize", "epsilon", "delta", "minCount", "sketchSize"} {
+		if flags.Changed(name) {
+			conflicts = append(conflicts, "--"+name)
+		}
+	}
+	if len(conflicts) != 0 {
+		return fmt.Errorf("--reuseSpectrum sets the sketching parameters, it can't be used with %v", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// startRun sets up logging (and profiling if requested) for a subcommand
+// it returns a function that should be deferred to close the log and stop the profiler
+func startRun() func() {
+	var prof interface{ Stop() }
+	if *profiling == true {
+		prof = profile.Start(profile.ProfilePath("./"))
+	}
+	logFH := misc.StartLogging((*outFile + ".log"))
+	log.SetOutput(logFH)
+	log.Printf("thor (version %s)", version.VERSION)
+	return func() {
+		logFH.Close()
+		if prof != nil {
+			prof.Stop()
+		}
+	}
+}
@@ -99,6 +99,46 @@ func (colourSketch *colourSketch) PrintPNGline() ([]color.RGBA, error) {
 	return line, nil
 }
 
+// Values is a method to get the sketch values held in the R (low byte) and G (high byte) slots of the colour sketch
+func (colourSketch *colourSketch) Values() []uint16 {
+	values := make([]uint16, len(colourSketch.Colours))
+	for i, colour := range colourSketch.Colours {
+		values[i] = uint16(colour.RGBA.R) | uint16(colour.RGBA.G)<<8
+	}
+	return values
+}
+
+// PrintPNGline64 is a method to print the coloured sketch as a line for 16-bit PNG conversion
+// the R slot holds the full uint16 sketch value, the B slot holds the supplied abundance and the A slot is set to visible
+func (colourSketch *colourSketch) PrintPNGline64(abundance uint16) ([]color.NRGBA64, error) {
+	if colourSketch.Id == "" {
+		return nil, fmt.Errorf("no ID is set for this colour sketch")
+	}
+	line := make([]color.NRGBA64, len(colourSketch.Colours))
+	for i, value := range colourSketch.Values() {
+		line[i] = color.NRGBA64{R: value, B: abundance, A: math.MaxUint16}
+	}
+	return line, nil
+}
+
+// Similarity is a method to estimate the similarity of two colour sketches
+// it returns the fraction of sketch elements which share the same R and G values (i.e. the same uint16 sketch value)
+func (colourSketch *colourSketch) Similarity(other *colourSketch) (float64, error) {
+	if len(colourSketch.Colours) != len(other.Colours) {
+		return 0, fmt.Errorf("sketch length mismatch (%d : %d)", len(colourSketch.Colours), len(other.Colours))
+	}
+	if len(colourSketch.Colours) == 0 {
+		return 0, fmt.Errorf("can't compare empty colour sketches")
+	}
+	var matches int
+	for i, colour := range colourSketch.Colours {
+		if colour.RGBA.R == other.Colours[i].RGBA.R && colour.RGBA.G == other.Colours[i].RGBA.G {
+			matches++
+		}
+	}
+	return float64(matches) / float64(len(colourSketch.Colours)), nil
+}
+
 // Adjust is a method to increment a RGBA slot in each element of a colourSketch
 func (colourSketch *colourSketch) Adjust(slot rune, increment uint8) error {
 	var overflowCheck uint16
@@ -201,6 +241,28 @@ func NewColourSketch(sketch []uint32, v string) *colourSketch {
 	}
 }
 
+// ConvertSketch collects histosketch values so that they can be encoded as R and G values (uint16)
+// the colour library uses []uint32 as input (encodes as RGBA), but we just want to use the R and G slots
+// if sketch values overflow uint16, modulo is used to scale the values to fit and an error is returned as a warning
+func ConvertSketch(sketch []uint) ([]uint32, error) {
+	values := make([]uint32, len(sketch))
+	var overflow error
+	for i := 0; i < len(sketch); i++ {
+		if sketch[i] > math.MaxUint16 {
+			overflow = fmt.Errorf("sketch values overflow uint16, using modulo to scale the values to fit")
+			break
+		}
+		values[i] = uint32(sketch[i])
+	}
+	// if a sketch value overflowed uint16, rerun the loop and modulo the values across uint16
+	if overflow != nil {
+		for i := 0; i < len(sketch); i++ {
+			values[i] = uint32(sketch[i] % 65535)
+		}
+	}
+	return values, overflow
+}
+
 // NewColourSketchChan is a constructor function to create a channel for sending colour sketches
 func NewColourSketchChan() colourSketchChan {
 	return make(colourSketchChan)
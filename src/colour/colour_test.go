@@ -107,3 +107,56 @@ func Test_ColourSketchStoreDump(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// test the sketch conversion and similarity
+func TestSimilarity(t *testing.T) {
+	values, err := ConvertSketch([]uint{1, 2, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ConvertSketch([]uint{1, math.MaxUint16 + 1}); err == nil {
+		t.Fatal("should warn when sketch values overflow uint16")
+	}
+	csA := NewColourSketch(values, "coloursketchA")
+	csB := NewColourSketch([]uint32{1, 2, 30, 40}, "coloursketchB")
+	sim, err := csA.Similarity(csB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sim != 0.5 {
+		t.Fatalf("expected similarity of 0.5, got %v", sim)
+	}
+	if _, err := csA.Similarity(NewColourSketch(sketch2, "coloursketchC")); err == nil {
+		t.Fatal("sketches of different lengths can't be compared")
+	}
+}
+
+func TestValues(t *testing.T) {
+	cs := NewColourSketch(sketch, "coloursketchA")
+	values := cs.Values()
+	if len(values) != len(sketch) {
+		t.Fatal("wrong number of sketch values")
+	}
+	for i, value := range values {
+		if value != uint16(sketch[i]) {
+			t.Fatalf("sketch value %d not recovered from the R and G slots: %d != %d", i, value, uint16(sketch[i]))
+		}
+	}
+}
+
+func TestPrintPNGline64(t *testing.T) {
+	cs := NewColourSketch(sketch, "coloursketchA")
+	line, err := cs.PrintPNGline64(40000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, pixel := range line {
+		if pixel.R != uint16(sketch[i]) || pixel.G != 0 || pixel.B != 40000 || pixel.A != math.MaxUint16 {
+			t.Fatalf("incorrect 16-bit pixel %d: %v", i, pixel)
+		}
+	}
+	cs.Id = ""
+	if _, err := cs.PrintPNGline64(0); err == nil {
+		t.Fatal("colour sketch without an ID should raise an error")
+	}
+}
@@ -1,6 +1,7 @@
 package draw
 
 import (
+	"bytes"
 	"fmt"
 	"image"
 	"image/color"
@@ -11,12 +12,20 @@ import (
 // PAD_COLOUR is the colour to use to fill the rest of the PNG if not enough OTU vectors are given
 var PAD_COLOUR = color.RGBA{255, 255, 255, 255}
 
+// thorCanvas is an image that can be drawn on (either 8 or 16 bits per channel)
+type thorCanvas interface {
+	image.Image
+	Set(x, y int, c color.Color)
+}
+
 // thorPNG
 type thorPNG struct {
-	canvas   *image.RGBA
+	canvas   thorCanvas
+	bitDepth int
 	xy       int
 	padding  int
 	currentY int
+	text     map[string]string
 }
 
 // GetPadding is a method to return the number of padding rows needed to square the PNG
@@ -24,16 +33,32 @@ func (thorPNG *thorPNG) GetPadding() int {
 	return thorPNG.padding
 }
 
-// DrawOTU method will add a row of pixels to the PNG,
-func (thorPNG *thorPNG) DrawOTU(colours []color.RGBA) error {
+// GetBitDepth is a method to return the number of bits per channel of the PNG
+func (thorPNG *thorPNG) GetBitDepth() int {
+	return thorPNG.bitDepth
+}
+
+// checkRow is a method to check that a row of pixels can be added to the PNG
+func (thorPNG *thorPNG) checkRow(length int) error {
 	// check the incoming vector is compatible with the image
-	if len(colours) != thorPNG.xy {
-		return fmt.Errorf("was expecting sketch of length %d, received vector of length %d", thorPNG.xy, len(colours))
+	if length != thorPNG.xy {
+		return fmt.Errorf("was expecting sketch of length %d, received vector of length %d", thorPNG.xy, length)
 	}
 	// check if the image is full yet
-	if (thorPNG.currentY + thorPNG.padding) == len(colours) {
+	if (thorPNG.currentY + thorPNG.padding) == length {
 		return fmt.Errorf("image full")
 	}
+	return nil
+}
+
+// DrawOTU method will add a row of pixels to the PNG,
+func (thorPNG *thorPNG) DrawOTU(colours []color.RGBA) error {
+	if err := thorPNG.checkRow(len(colours)); err != nil {
+		return err
+	}
+	if thorPNG.bitDepth != 8 {
+		return fmt.Errorf("can't draw 8-bit colours on a %d-bit PNG", thorPNG.bitDepth)
+	}
 	// add each pixel to the new row in the image
 	for x := 0; x < thorPNG.xy; x++ {
 		thorPNG.canvas.Set(x, thorPNG.currentY, colours[x])
@@ -42,6 +67,21 @@ func (thorPNG *thorPNG) DrawOTU(colours []color.RGBA) error {
 	return nil
 }
 
+// DrawOTU64 method will add a row of 16-bit pixels to the PNG
+func (thorPNG *thorPNG) DrawOTU64(colours []color.NRGBA64) error {
+	if err := thorPNG.checkRow(len(colours)); err != nil {
+		return err
+	}
+	if thorPNG.bitDepth != 16 {
+		return fmt.Errorf("can't draw 16-bit colours on a %d-bit PNG", thorPNG.bitDepth)
+	}
+	for x := 0; x < thorPNG.xy; x++ {
+		thorPNG.canvas.Set(x, thorPNG.currentY, colours[x])
+	}
+	thorPNG.currentY++
+	return nil
+}
+
 // Save method will check and save the thorPNG to disk
 func (thorPNG *thorPNG) Save(filepath string, padding bool) error {
 	// check the PNG has been built from enough OTUs for current canvas size
@@ -59,18 +99,35 @@ func (thorPNG *thorPNG) Save(filepath string, padding bool) error {
 
 		}
 	}
+	// encode as png, adding any text chunks
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, thorPNG.canvas); err != nil {
+		return err
+	}
 	fh, err := os.Create(filepath)
 	if err != nil {
 		return err
 	}
 	defer fh.Close()
-	// encode as png and save (returning any error)
-	return png.Encode(fh, thorPNG.canvas)
-
+	if len(thorPNG.text) == 0 {
+		_, err = encoded.WriteTo(fh)
+		return err
+	}
+	return writeText(fh, encoded.Bytes(), thorPNG.text)
 }
 
 // NewThorPNG is the thorPNG constructor
 func NewThorPNG(sketchLength, numOtus int) (*thorPNG, error) {
+	return newThorPNG(sketchLength, numOtus, 8)
+}
+
+// NewThorPNG16 is the thorPNG constructor for 16 bits per channel PNGs
+func NewThorPNG16(sketchLength, numOtus int) (*thorPNG, error) {
+	return newThorPNG(sketchLength, numOtus, 16)
+}
+
+// newThorPNG creates a square thorPNG with the requested bits per channel
+func newThorPNG(sketchLength, numOtus, bitDepth int) (*thorPNG, error) {
 	// we adding padding if sketchLength < number of otus
 	var pad int
 	if sketchLength >= numOtus {
@@ -79,8 +136,15 @@ func NewThorPNG(sketchLength, numOtus int) (*thorPNG, error) {
 		return nil, fmt.Errorf("number of OTUs > sketch length (%d : %d). Suggest supplying top %d OTUs.", numOtus, sketchLength, sketchLength)
 	}
 	// create the canvas so that it is a square, equal to the sketchLength
+	var canvas thorCanvas
+	if bitDepth == 16 {
+		canvas = image.NewNRGBA64(image.Rect(0, 0, sketchLength, sketchLength))
+	} else {
+		canvas = image.NewRGBA(image.Rect(0, 0, sketchLength, sketchLength))
+	}
 	return &thorPNG{
-		canvas:   image.NewRGBA(image.Rect(0, 0, sketchLength, sketchLength)),
+		canvas:   canvas,
+		bitDepth: bitDepth,
 		xy:       sketchLength,
 		padding:  pad,
 		currentY: 0,
@@ -2,6 +2,7 @@ package draw
 
 import (
 	"image/color"
+	"image/png"
 	"os"
 	"testing"
 )
@@ -70,3 +71,42 @@ func TestSavePNG(t *testing.T) {
 }
 
 // test padding, printing, etc.
+
+func TestSavePNG16(t *testing.T) {
+	testImg, err := NewThorPNG16(sketchLength, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if testImg.GetBitDepth() != 16 {
+		t.Fatal("incorrect bit depth")
+	}
+	if err := testImg.DrawOTU(otu1); err == nil {
+		t.Fatal("8-bit colours can't be drawn on a 16-bit PNG")
+	}
+	line := make([]color.NRGBA64, sketchLength)
+	for i := range line {
+		line[i] = color.NRGBA64{R: uint16(1000 * i), B: 65000, A: 65535}
+	}
+	if err := testImg.DrawOTU64(line); err != nil {
+		t.Fatal(err)
+	}
+	if err := testImg.Save("./test16.png", true); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("./test16.png")
+	fh, err := os.Open("./test16.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+	img, err := png.Decode(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model := img.ColorModel(); model != color.RGBA64Model && model != color.NRGBA64Model {
+		t.Fatal("PNG was not saved with 16 bits per channel")
+	}
+	if pixel := color.NRGBA64Model.Convert(img.At(3, 0)); pixel != line[3] {
+		t.Fatalf("16-bit pixel not recovered: %v", pixel)
+	}
+}
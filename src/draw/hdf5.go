@@ -0,0 +1,40 @@
+package draw
+
+import (
+	"io"
+
+	"github.com/will-rowe/thor/src/hdf5"
+)
+
+// the chunk height and zlib level used for the HDF5 tensor dataset
+const (
+	hdf5ChunkRows = 64
+	hdf5Deflate   = 4
+)
+
+// WriteHDF5 is a method to write the tensor as an HDF5 file
+// the values are a float32 "tensor" dataset (height, width, channels), compressed in chunks of rows, with the channel names and max values in the "channels" and "channel_max" datasets
+func (tensor *Tensor) WriteHDF5(w io.Writer) error {
+	names := make([]string, len(tensor.channels))
+	maxes := make([]float32, len(tensor.channels))
+	for i, channel := range tensor.channels {
+		names[i], maxes[i] = channel.Name, channel.Max
+	}
+	rows := hdf5ChunkRows
+	if rows > tensor.height {
+		rows = tensor.height
+	}
+	shape := []int{tensor.height, tensor.width, len(tensor.channels)}
+	writer := hdf5.NewWriter()
+	if err := writer.Add("tensor", shape, tensor.data, hdf5.Options{Chunks: []int{rows, tensor.width, len(tensor.channels)}, Deflate: hdf5Deflate}); err != nil {
+		return err
+	}
+	if err := writer.Add("channels", []int{len(names)}, names, hdf5.Options{}); err != nil {
+		return err
+	}
+	if err := writer.Add("channel_max", []int{len(maxes)}, maxes, hdf5.Options{}); err != nil {
+		return err
+	}
+	_, err := writer.WriteTo(w)
+	return err
+}
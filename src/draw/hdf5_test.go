@@ -0,0 +1,56 @@
+package draw
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/will-rowe/thor/src/hdf5"
+)
+
+func TestWriteHDF5(t *testing.T) {
+	tensor, _ := NewTensor(3, 2, testChannels)
+	tensor.DrawRow([][]float32{{1, 2, 65535}, {0.5, 0.25, 0}})
+	var buf bytes.Buffer
+	if err := tensor.WriteHDF5(&buf); err != nil {
+		t.Fatal(err)
+	}
+	file, err := hdf5.Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds, err := file.Dataset("tensor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds.Shape) != 3 || ds.Shape[0] != 2 || ds.Shape[1] != 3 || ds.Shape[2] != 2 {
+		t.Fatalf("incorrect shape: %v", ds.Shape)
+	}
+	values, err := ds.Floats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, value := range tensor.data {
+		if values[i] != float64(value) {
+			t.Fatalf("value %d not recovered: %v != %v", i, values[i], value)
+		}
+	}
+	if ds, err = file.Dataset("channels"); err != nil {
+		t.Fatal(err)
+	}
+	names, err := ds.Strings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds, err = file.Dataset("channel_max"); err != nil {
+		t.Fatal(err)
+	}
+	maxes, err := ds.Floats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, channel := range testChannels {
+		if names[i] != channel.Name || maxes[i] != float64(channel.Max) {
+			t.Fatalf("channel %d not recovered: %v %v", i, names[i], maxes[i])
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package draw
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// npyMagic is the start of a .npy file
+var npyMagic = []byte("\x93NUMPY")
+
+// the .npy header fields
+var (
+	npyDescr   = regexp.MustCompile(`'descr':\s*'([<>|=]?)([fiub])(\d+)'`)
+	npyFortran = regexp.MustCompile(`'fortran_order':\s*(True|False)`)
+	npyShape   = regexp.MustCompile(`'shape':\s*\(([^)]*)\)`)
+)
+
+// WriteNpy is a method to write the tensor as a little endian float32 .npy array, with the shape (height, width, channels)
+func (tensor *Tensor) WriteNpy(w io.Writer) error {
+	return WriteNpy(w, []int{tensor.height, tensor.width, len(tensor.channels)}, tensor.data)
+}
+
+// WriteNpy writes a C ordered, little endian float32 .npy (version 1.0) array
+func WriteNpy(w io.Writer, shape []int, data []float32) error {
+	size := 1
+	dims := make([]string, len(shape))
+	for i, dim := range shape {
+		size *= dim
+		dims[i] = strconv.Itoa(dim)
+	}
+	if size != len(data) {
+		return fmt.Errorf("npy shape %v does not match the data length (%d)", shape, len(data))
+	}
+	shapeString := strings.Join(dims, ", ")
+	if len(shape) == 1 {
+		shapeString += ","
+	}
+	header := fmt.Sprintf("{'descr': '<f4', 'fortran_order': False, 'shape': (%v), }", shapeString)
+	// pad the header with spaces so that the data is 64 byte aligned, ending with a newline
+	padding := 64 - (len(npyMagic)+4+len(header)+1)%64
+	if padding == 64 {
+		padding = 0
+	}
+	header += strings.Repeat(" ", padding) + "\n"
+	bw := bufio.NewWriter(w)
+	bw.Write(npyMagic)
+	bw.Write([]byte{1, 0})
+	binary.Write(bw, binary.LittleEndian, uint16(len(header)))
+	bw.WriteString(header)
+	buf := make([]byte, 4)
+	for _, value := range data {
+		binary.LittleEndian.PutUint32(buf, math.Float32bits(value))
+		bw.Write(buf)
+	}
+	return bw.Flush()
+}
+
+// ReadNpy reads a C ordered .npy array of floats, integers, unsigned integers or bools, returning the shape and the values as float32
+func ReadNpy(r io.Reader) ([]int, []float32, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(npyMagic)+2)
+	if _, err := io.ReadFull(br, magic); err != nil || !bytes.Equal(magic[:len(npyMagic)], npyMagic) {
+		return nil, nil, fmt.Errorf("not a npy file")
+	}
+	var headerLen int
+	switch magic[len(npyMagic)] {
+	case 1:
+		var l uint16
+		if err := binary.Read(br, binary.LittleEndian, &l); err != nil {
+			return nil, nil, err
+		}
+		headerLen = int(l)
+	case 2, 3:
+		var l uint32
+		if err := binary.Read(br, binary.LittleEndian, &l); err != nil {
+			return nil, nil, err
+		}
+		headerLen = int(l)
+	default:
+		return nil, nil, fmt.Errorf("unsupported npy version: %d", magic[len(npyMagic)])
+	}
+	headerBytes := make([]byte, headerLen)
+	if _, err := io.ReadFull(br, headerBytes); err != nil {
+		return nil, nil, err
+	}
+	header := string(headerBytes)
+	// parse the header
+	descr := npyDescr.FindStringSubmatch(header)
+	if descr == nil {
+		return nil, nil, fmt.Errorf("unsupported npy dtype in header: %v", strings.TrimSpace(header))
+	}
+	if fortran := npyFortran.FindStringSubmatch(header); fortran == nil || fortran[1] == "True" {
+		return nil, nil, fmt.Errorf("only C ordered npy arrays are supported")
+	}
+	shapeMatch := npyShape.FindStringSubmatch(header)
+	if shapeMatch == nil {
+		return nil, nil, fmt.Errorf("no shape found in npy header")
+	}
+	var shape []int
+	size := 1
+	for _, dim := range strings.Split(shapeMatch[1], ",") {
+		dim = strings.TrimSpace(dim)
+		if dim == "" {
+			continue
+		}
+		d, err := strconv.Atoi(dim)
+		if err != nil || d < 0 {
+			return nil, nil, fmt.Errorf("invalid npy shape: (%v)", shapeMatch[1])
+		}
+		shape = append(shape, d)
+		size *= d
+	}
+	// read the values
+	var order binary.ByteOrder = binary.LittleEndian
+	if descr[1] == ">" {
+		order = binary.BigEndian
+	}
+	itemSize, _ := strconv.Atoi(descr[3])
+	kind := descr[2]
+	if !((kind == "f" && (itemSize == 4 || itemSize == 8)) || (kind != "f" && (itemSize == 1 || itemSize == 2 || itemSize == 4 || itemSize == 8))) {
+		return nil, nil, fmt.Errorf("unsupported npy dtype: %v%v", kind, itemSize)
+	}
+	raw := make([]byte, size*itemSize)
+	if _, err := io.ReadFull(br, raw); err != nil {
+		return nil, nil, fmt.Errorf("npy data is shorter than its shape: %v", err)
+	}
+	data := make([]float32, size)
+	for i := range data {
+		b := raw[i*itemSize : (i+1)*itemSize]
+		var bits uint64
+		switch itemSize {
+		case 1:
+			bits = uint64(b[0])
+		case 2:
+			bits = uint64(order.Uint16(b))
+		case 4:
+			bits = uint64(order.Uint32(b))
+		case 8:
+			bits = order.Uint64(b)
+		}
+		switch kind {
+		case "f":
+			if itemSize == 4 {
+				data[i] = math.Float32frombits(uint32(bits))
+			} else {
+				data[i] = float32(math.Float64frombits(bits))
+			}
+		case "i":
+			// sign extend the integer
+			shift := uint(64 - 8*itemSize)
+			data[i] = float32(int64(bits<<shift) >> shift)
+		default:
+			data[i] = float32(bits)
+		}
+	}
+	return shape, data, nil
+}
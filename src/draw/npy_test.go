@@ -0,0 +1,65 @@
+package draw
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNpy(t *testing.T) {
+	tensor, _ := NewTensor(3, 2, testChannels)
+	tensor.DrawRow([][]float32{{1, 2, 65535}, {0.5, 0.25, 0}})
+	var buf bytes.Buffer
+	if err := tensor.WriteNpy(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// the header must leave the data 64 byte aligned
+	if (buf.Len()-2*3*2*4)%64 != 0 {
+		t.Fatal("npy data is not 64 byte aligned")
+	}
+	shape, data, err := ReadNpy(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shape) != 3 || shape[0] != 2 || shape[1] != 3 || shape[2] != 2 {
+		t.Fatalf("incorrect shape: %v", shape)
+	}
+	for i, value := range tensor.data {
+		if data[i] != value {
+			t.Fatalf("value %d not recovered: %v != %v", i, data[i], value)
+		}
+	}
+	if err := WriteNpy(&buf, []int{2, 2}, []float32{1}); err == nil {
+		t.Fatal("shape must match the data length")
+	}
+}
+
+func TestReadNpy(t *testing.T) {
+	header := "{'descr': '|u1', 'fortran_order': False, 'shape': (3,), }"
+	npy := append([]byte("\x93NUMPY\x01\x00"), byte(len(header)), 0)
+	npy = append(npy, header...)
+	npy = append(npy, 0, 128, 255)
+	shape, data, err := ReadNpy(bytes.NewReader(npy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shape) != 1 || shape[0] != 3 || data[1] != 128 || data[2] != 255 {
+		t.Fatalf("uint8 npy not read: %v %v", shape, data)
+	}
+	header = "{'descr': '<i2', 'fortran_order': False, 'shape': (1,), }"
+	npy = append([]byte("\x93NUMPY\x01\x00"), byte(len(header)), 0)
+	npy = append(npy, header...)
+	npy = append(npy, 0xFE, 0xFF)
+	if _, data, err := ReadNpy(bytes.NewReader(npy)); err != nil || data[0] != -2 {
+		t.Fatalf("int16 npy not read: %v %v", data, err)
+	}
+	header = "{'descr': '<f4', 'fortran_order': True, 'shape': (1,), }"
+	npy = append([]byte("\x93NUMPY\x01\x00"), byte(len(header)), 0)
+	npy = append(npy, header...)
+	npy = append(npy, 0, 0, 0, 0)
+	if _, _, err := ReadNpy(bytes.NewReader(npy)); err == nil {
+		t.Fatal("fortran ordered npy should raise an error")
+	}
+	if _, _, err := ReadNpy(bytes.NewReader([]byte("not a npy file"))); err == nil {
+		t.Fatal("non-npy input should raise an error")
+	}
+}
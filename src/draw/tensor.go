@@ -0,0 +1,191 @@
+package draw
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// the supported tensor output formats
+const (
+	TENSOR_NPY  = "npy"
+	TENSOR_TIFF = "tiff"
+	TENSOR_PNG  = "png"
+	TENSOR_HDF5 = "hdf5"
+)
+
+// TensorFormats are the supported tensor output formats
+var TensorFormats = []string{TENSOR_NPY, TENSOR_TIFF, TENSOR_PNG, TENSOR_HDF5}
+
+// TensorExtensions are the file extensions used for each tensor output format
+var TensorExtensions = map[string]string{
+	TENSOR_NPY:  ".npy",
+	TENSOR_TIFF: ".tiff",
+	TENSOR_PNG:  ".png",
+	TENSOR_HDF5: ".h5",
+}
+
+// Channel describes a channel of a Tensor, Max is the value that is scaled to 255 when the channel is projected to a PNG
+type Channel struct {
+	Name string
+	Max  float32
+}
+
+// Tensor is an N-channel canvas, holding a float32 value for each channel of each pixel
+// the data is stored row major with the channels last (height x width x channels), which is the layout used when saving as .npy
+type Tensor struct {
+	width    int
+	height   int
+	channels []Channel
+	data     []float32
+	currentY int
+}
+
+// NewTensor is the Tensor constructor
+func NewTensor(width, height int, channels []Channel) (*Tensor, error) {
+	if width < 1 || height < 1 {
+		return nil, fmt.Errorf("tensor width and height must be at least 1 (%d : %d)", width, height)
+	}
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("tensor needs at least 1 channel")
+	}
+	for _, channel := range channels {
+		if channel.Max <= 0 {
+			return nil, fmt.Errorf("channel max must be > 0: %v", channel.Name)
+		}
+	}
+	return &Tensor{
+		width:    width,
+		height:   height,
+		channels: channels,
+		data:     make([]float32, width*height*len(channels)),
+	}, nil
+}
+
+// GetShape is a method to get the height, width and number of channels of the tensor
+func (tensor *Tensor) GetShape() (int, int, int) {
+	return tensor.height, tensor.width, len(tensor.channels)
+}
+
+// GetChannels is a method to get a copy of the tensor channels
+func (tensor *Tensor) GetChannels() []Channel {
+	channels := make([]Channel, len(tensor.channels))
+	copy(channels, tensor.channels)
+	return channels
+}
+
+// At is a method to get the value of a channel at a pixel
+func (tensor *Tensor) At(x, y, c int) float32 {
+	return tensor.data[tensor.index(x, y, c)]
+}
+
+// Set is a method to set the value of a channel at a pixel
+func (tensor *Tensor) Set(x, y, c int, value float32) {
+	tensor.data[tensor.index(x, y, c)] = value
+}
+
+// index returns the position of a value in the tensor data
+func (tensor *Tensor) index(x, y, c int) int {
+	return (y*tensor.width+x)*len(tensor.channels) + c
+}
+
+// DrawRow is a method to add a row of pixels to the tensor, the values are given per channel (values[channel][x])
+func (tensor *Tensor) DrawRow(values [][]float32) error {
+	if len(values) != len(tensor.channels) {
+		return fmt.Errorf("was expecting %d channels, received %d", len(tensor.channels), len(values))
+	}
+	if tensor.currentY == tensor.height {
+		return fmt.Errorf("tensor full")
+	}
+	for c, channel := range values {
+		if len(channel) != tensor.width {
+			return fmt.Errorf("was expecting row of length %d, received row of length %d", tensor.width, len(channel))
+		}
+		for x, value := range channel {
+			tensor.Set(x, tensor.currentY, c, value)
+		}
+	}
+	tensor.currentY++
+	return nil
+}
+
+// Project is a method to project 4 of the tensor channels onto an RGBA image, scaling each channel by its max value
+// a channel index of -1 fills that slot with 255
+func (tensor *Tensor) Project(channels [4]int) (*image.RGBA, error) {
+	for _, c := range channels {
+		if c < -1 || c >= len(tensor.channels) {
+			return nil, fmt.Errorf("channel index out of range: %d", c)
+		}
+	}
+	img := image.NewRGBA(image.Rect(0, 0, tensor.width, tensor.height))
+	for y := 0; y < tensor.height; y++ {
+		for x := 0; x < tensor.width; x++ {
+			var slots [4]uint8
+			for i, c := range channels {
+				if c == -1 {
+					slots[i] = 255
+					continue
+				}
+				value := tensor.At(x, y, c) / tensor.channels[c].Max * 255
+				switch {
+				case value > 255:
+					value = 255
+				case value < 0 || value != value:
+					value = 0
+				}
+				slots[i] = uint8(value + 0.5)
+			}
+			img.SetRGBA(x, y, color.RGBA{slots[0], slots[1], slots[2], slots[3]})
+		}
+	}
+	return img, nil
+}
+
+// CheckTensorFormat checks that a tensor format can be written
+func CheckTensorFormat(format string) error {
+	if _, ok := TensorExtensions[format]; !ok {
+		return fmt.Errorf("unsupported tensor format: %v (supported: %v)", format, strings.Join(TensorFormats, ", "))
+	}
+	return nil
+}
+
+// Save is a method to save the tensor in one of the TensorFormats
+// PNG output is a projection of the first 4 channels (fewer channels leave the remaining slots at 255)
+func (tensor *Tensor) Save(path, format string) error {
+	if err := CheckTensorFormat(format); err != nil {
+		return err
+	}
+	fh, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case TENSOR_NPY:
+		err = tensor.WriteNpy(fh)
+	case TENSOR_TIFF:
+		err = tensor.WriteTIFF(fh)
+	case TENSOR_HDF5:
+		err = tensor.WriteHDF5(fh)
+	case TENSOR_PNG:
+		channels := [4]int{-1, -1, -1, -1}
+		for i := 0; i < 4 && i < len(tensor.channels); i++ {
+			channels[i] = i
+		}
+		var img *image.RGBA
+		if img, err = tensor.Project(channels); err == nil {
+			err = png.Encode(fh, img)
+		}
+	}
+	if closeErr := fh.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("could not write tensor (%v): %v", filepath.Base(path), err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package draw
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testChannels = []Channel{{"sketch", 65535}, {"abundance", 1}}
+
+func TestTensorConstructor(t *testing.T) {
+	if _, err := NewTensor(0, 2, testChannels); err == nil {
+		t.Fatal("tensor width must be at least 1")
+	}
+	if _, err := NewTensor(2, 2, nil); err == nil {
+		t.Fatal("tensor needs at least 1 channel")
+	}
+	if _, err := NewTensor(2, 2, []Channel{{"sketch", 0}}); err == nil {
+		t.Fatal("channel max must be > 0")
+	}
+	tensor, err := NewTensor(3, 2, testChannels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, w, c := tensor.GetShape(); h != 2 || w != 3 || c != 2 {
+		t.Fatalf("incorrect tensor shape: %d %d %d", h, w, c)
+	}
+}
+
+func TestDrawRow(t *testing.T) {
+	tensor, _ := NewTensor(3, 2, testChannels)
+	row := [][]float32{{1, 2, 65535}, {0.5, 0.5, 0.5}}
+	if err := tensor.DrawRow(row); err != nil {
+		t.Fatal(err)
+	}
+	if tensor.At(2, 0, 0) != 65535 || tensor.At(0, 0, 1) != 0.5 {
+		t.Fatal("row not drawn")
+	}
+	if err := tensor.DrawRow(row[:1]); err == nil {
+		t.Fatal("row with too few channels should raise an error")
+	}
+	if err := tensor.DrawRow([][]float32{{1, 2}, {0.5, 0.5}}); err == nil {
+		t.Fatal("row of wrong length should raise an error")
+	}
+	if err := tensor.DrawRow(row); err != nil {
+		t.Fatal(err)
+	}
+	if err := tensor.DrawRow(row); err == nil {
+		t.Fatal("tensor already full")
+	}
+}
+
+func TestProject(t *testing.T) {
+	tensor, _ := NewTensor(3, 1, testChannels)
+	tensor.DrawRow([][]float32{{0, 32768, 65535}, {0.5, 2, -1}})
+	img, err := tensor.Project([4]int{0, 1, -1, -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := img.RGBAAt(2, 0); c.R != 255 || c.G != 0 || c.B != 255 || c.A != 255 {
+		t.Fatalf("incorrect projection: %v", c)
+	}
+	if c := img.RGBAAt(1, 0); c.R != 128 || c.G != 255 {
+		t.Fatalf("incorrect projection: %v", c)
+	}
+	if _, err := tensor.Project([4]int{0, 1, 2, -1}); err == nil {
+		t.Fatal("projecting a missing channel should raise an error")
+	}
+}
+
+func TestSaveTensor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thor-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tensor, _ := NewTensor(3, 2, testChannels)
+	for _, format := range []string{TENSOR_NPY, TENSOR_TIFF, TENSOR_PNG, TENSOR_HDF5} {
+		path := filepath.Join(dir, "test"+TensorExtensions[format])
+		if err := tensor.Save(path, format); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, "test.zarr")
+	if err := tensor.Save(path, "zarr"); err == nil {
+		t.Fatal("unsupported formats should return an error")
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Fatal("no file should be written for an unsupported format")
+	}
+}
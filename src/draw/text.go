@@ -0,0 +1,231 @@
+package draw
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"sort"
+	"unicode/utf8"
+)
+
+// pngMagic is the start of a PNG file
+var pngMagic = []byte("\x89PNG\r\n\x1a\n")
+
+// TEXT_COMPRESS_SIZE is the size above which text is compressed when it is written to an iTXt chunk
+const TEXT_COMPRESS_SIZE = 1024
+
+// SetText is a method to add a text chunk to the PNG, which will be written when the PNG is saved
+// keywords must be 1-79 printable Latin-1 characters, without leading or trailing spaces
+func (thorPNG *thorPNG) SetText(keyword, text string) error {
+	if err := checkKeyword(keyword); err != nil {
+		return err
+	}
+	if thorPNG.text == nil {
+		thorPNG.text = make(map[string]string)
+	}
+	thorPNG.text[keyword] = text
+	return nil
+}
+
+// checkKeyword checks that a PNG text keyword is valid
+func checkKeyword(keyword string) error {
+	if len(keyword) == 0 || len(keyword) > 79 {
+		return fmt.Errorf("PNG text keyword must be 1-79 characters: %q", keyword)
+	}
+	if keyword[0] == ' ' || keyword[len(keyword)-1] == ' ' {
+		return fmt.Errorf("PNG text keyword can't start or end with a space: %q", keyword)
+	}
+	for i := 0; i < len(keyword); i++ {
+		if c := keyword[i]; c < 32 || c > 126 {
+			return fmt.Errorf("PNG text keyword must be printable ASCII: %q", keyword)
+		}
+	}
+	return nil
+}
+
+// writeText writes an encoded PNG, adding the text chunks (in keyword order) after the header chunk
+// ASCII text is written as tEXt chunks, other text (or long text) is written as UTF-8 iTXt chunks
+func writeText(w io.Writer, encoded []byte, text map[string]string) error {
+	if len(encoded) < len(pngMagic)+25 || !bytes.Equal(encoded[:len(pngMagic)], pngMagic) {
+		return fmt.Errorf("not a PNG")
+	}
+	// the IHDR chunk is always first and is 25 bytes long (length, type, 13 bytes of data, CRC)
+	headerEnd := len(pngMagic) + 25
+	if _, err := w.Write(encoded[:headerEnd]); err != nil {
+		return err
+	}
+	keywords := make([]string, 0, len(text))
+	for keyword := range text {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+	for _, keyword := range keywords {
+		value := text[keyword]
+		var chunk bytes.Buffer
+		chunk.WriteString(keyword)
+		chunk.WriteByte(0)
+		if isASCII(value) && len(value) <= TEXT_COMPRESS_SIZE {
+			chunk.WriteString(value)
+			if err := writeChunk(w, "tEXt", chunk.Bytes()); err != nil {
+				return err
+			}
+			continue
+		}
+		// compression flag and method, then empty language tag and translated keyword
+		if len(value) > TEXT_COMPRESS_SIZE {
+			chunk.Write([]byte{1, 0, 0, 0})
+			zw := zlib.NewWriter(&chunk)
+			if _, err := zw.Write([]byte(value)); err != nil {
+				return err
+			}
+			if err := zw.Close(); err != nil {
+				return err
+			}
+		} else {
+			chunk.Write([]byte{0, 0, 0, 0})
+			chunk.WriteString(value)
+		}
+		if err := writeChunk(w, "iTXt", chunk.Bytes()); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(encoded[headerEnd:])
+	return err
+}
+
+// writeChunk writes a PNG chunk
+func writeChunk(w io.Writer, chunkType string, data []byte) error {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	copy(header[4:], chunkType)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	footer := make([]byte, 4)
+	binary.BigEndian.PutUint32(footer, crc.Sum32())
+	for _, b := range [][]byte{header, data, footer} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isASCII checks that text only holds printable ASCII and newlines
+// tEXt chunks are Latin-1 encoded, so any other text is written to an iTXt chunk as UTF-8
+func isASCII(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if c := text[i]; c > 126 || (c < 32 && c != '\n') {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadText reads the tEXt, zTXt and iTXt chunks of a PNG, returning the text for each keyword
+func ReadText(r io.Reader) (map[string]string, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(pngMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || !bytes.Equal(magic, pngMagic) {
+		return nil, fmt.Errorf("not a PNG")
+	}
+	text := make(map[string]string)
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(br, header); err != nil {
+			return nil, fmt.Errorf("PNG is truncated: %v", err)
+		}
+		length := binary.BigEndian.Uint32(header)
+		chunkType := string(header[4:])
+		if chunkType == "IEND" {
+			return text, nil
+		}
+		if chunkType != "tEXt" && chunkType != "zTXt" && chunkType != "iTXt" {
+			if _, err := br.Discard(int(length) + 4); err != nil {
+				return nil, fmt.Errorf("PNG is truncated: %v", err)
+			}
+			continue
+		}
+		data := make([]byte, length+4)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return nil, fmt.Errorf("PNG is truncated: %v", err)
+		}
+		crc := crc32.NewIEEE()
+		crc.Write(header[4:])
+		crc.Write(data[:length])
+		if crc.Sum32() != binary.BigEndian.Uint32(data[length:]) {
+			return nil, fmt.Errorf("%v chunk has a bad CRC", chunkType)
+		}
+		keyword, value, err := parseTextChunk(chunkType, data[:length])
+		if err != nil {
+			return nil, err
+		}
+		text[keyword] = value
+	}
+}
+
+// parseTextChunk gets the keyword and text from a tEXt, zTXt or iTXt chunk
+func parseTextChunk(chunkType string, data []byte) (string, string, error) {
+	sep := bytes.IndexByte(data, 0)
+	if sep < 1 {
+		return "", "", fmt.Errorf("%v chunk has no keyword", chunkType)
+	}
+	keyword, data := latin1(data[:sep]), data[sep+1:]
+	switch chunkType {
+	case "tEXt":
+		return keyword, latin1(data), nil
+	case "zTXt":
+		if len(data) < 1 {
+			return "", "", fmt.Errorf("zTXt chunk is truncated")
+		}
+		value, err := inflate(data[1:])
+		return keyword, latin1(value), err
+	}
+	// iTXt: compression flag, compression method, language tag, translated keyword, text
+	if len(data) < 2 {
+		return "", "", fmt.Errorf("iTXt chunk is truncated")
+	}
+	compressed := data[0] == 1
+	data = data[2:]
+	for i := 0; i < 2; i++ {
+		sep := bytes.IndexByte(data, 0)
+		if sep < 0 {
+			return "", "", fmt.Errorf("iTXt chunk is truncated")
+		}
+		data = data[sep+1:]
+	}
+	if compressed {
+		var err error
+		if data, err = inflate(data); err != nil {
+			return "", "", err
+		}
+	}
+	if !utf8.Valid(data) {
+		return "", "", fmt.Errorf("iTXt chunk is not UTF-8: %v", keyword)
+	}
+	return keyword, string(data), nil
+}
+
+// inflate decompresses zlib data
+func inflate(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return ioutil.ReadAll(zr)
+}
+
+// latin1 converts Latin-1 text to a UTF-8 string
+func latin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
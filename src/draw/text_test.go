@@ -0,0 +1,58 @@
+package draw
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	testImg, _ := NewThorPNG(sketchLength, len(otus))
+	for _, otuVector := range otus {
+		_ = testImg.DrawOTU(otuVector)
+	}
+	if err := testImg.SetText("", "empty keyword"); err == nil {
+		t.Fatal("empty keyword should raise an error")
+	}
+	if err := testImg.SetText(" sample", "leading space"); err == nil {
+		t.Fatal("keyword with a leading space should raise an error")
+	}
+	text := map[string]string{
+		"Software":    "thor",
+		"thor-sample": "Escherichia–Shigella",
+		"thor-rows":   strings.Repeat("Bacteroides\n", 200),
+	}
+	for keyword, value := range text {
+		if err := testImg.SetText(keyword, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := testImg.Save("./test-text.png", false); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("./test-text.png")
+	fh, err := os.Open("./test-text.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+	read, err := ReadText(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for keyword, value := range text {
+		if read[keyword] != value {
+			t.Fatalf("text not recovered for %v: %q", keyword, read[keyword])
+		}
+	}
+	// the PNG must still decode
+	fh.Seek(0, 0)
+	if _, err := png.Decode(fh); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadText(bytes.NewReader([]byte("not a png"))); err == nil {
+		t.Fatal("non-PNG input should raise an error")
+	}
+}
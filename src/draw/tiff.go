@@ -0,0 +1,109 @@
+package draw
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// the TIFF tags used for each page
+const (
+	tiffImageWidth      = 256
+	tiffImageLength     = 257
+	tiffBitsPerSample   = 258
+	tiffCompression     = 259
+	tiffPhotometric     = 262
+	tiffStripOffsets    = 273
+	tiffSamplesPerPixel = 277
+	tiffRowsPerStrip    = 278
+	tiffStripByteCounts = 279
+	tiffPlanarConfig    = 284
+	tiffPageName        = 285
+	tiffSampleFormat    = 339
+)
+
+// the TIFF field types used
+const (
+	tiffShort = 3
+	tiffLong  = 4
+	tiffASCII = 2
+)
+
+// tiffEntry is a TIFF IFD entry, values that don't fit in the entry are written at offset
+type tiffEntry struct {
+	tag, fieldType uint16
+	count, value   uint32
+}
+
+// WriteTIFF is a method to write the tensor as a little endian, multi-page TIFF
+// each channel is written as a page of 32-bit floating point greyscale pixels, named after the channel
+func (tensor *Tensor) WriteTIFF(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	le := binary.LittleEndian
+	const headerSize, entrySize = 8, 12
+	numEntries := 12
+	ifdSize := uint32(2 + numEntries*entrySize + 4)
+	pageBytes := uint32(tensor.width * tensor.height * 4)
+	// lay out each page as its IFD, then its name, then its pixels
+	offset := uint32(headerSize)
+	bw.Write([]byte("II"))
+	binary.Write(bw, le, uint16(42))
+	binary.Write(bw, le, offset)
+	for c, channel := range tensor.channels {
+		name := append([]byte(channel.Name), 0)
+		nameOffset := offset + ifdSize
+		pixelOffset := nameOffset + uint32(len(name))
+		// keep the pixels word aligned
+		namePadding := (4 - pixelOffset%4) % 4
+		pixelOffset += namePadding
+		next := uint32(0)
+		if c != len(tensor.channels)-1 {
+			next = pixelOffset + pageBytes
+		}
+		entries := []tiffEntry{
+			{tiffImageWidth, tiffLong, 1, uint32(tensor.width)},
+			{tiffImageLength, tiffLong, 1, uint32(tensor.height)},
+			{tiffBitsPerSample, tiffShort, 1, 32},
+			{tiffCompression, tiffShort, 1, 1},
+			{tiffPhotometric, tiffShort, 1, 1},
+			{tiffStripOffsets, tiffLong, 1, pixelOffset},
+			{tiffSamplesPerPixel, tiffShort, 1, 1},
+			{tiffRowsPerStrip, tiffLong, 1, uint32(tensor.height)},
+			{tiffStripByteCounts, tiffLong, 1, pageBytes},
+			{tiffPlanarConfig, tiffShort, 1, 1},
+			{tiffPageName, tiffASCII, uint32(len(name)), nameOffset},
+			{tiffSampleFormat, tiffShort, 1, 3},
+		}
+		binary.Write(bw, le, uint16(len(entries)))
+		for _, entry := range entries {
+			binary.Write(bw, le, entry.tag)
+			binary.Write(bw, le, entry.fieldType)
+			binary.Write(bw, le, entry.count)
+			// short values are left justified in the value field, and short ascii values are held in place
+			switch {
+			case entry.fieldType == tiffShort:
+				binary.Write(bw, le, uint16(entry.value))
+				binary.Write(bw, le, uint16(0))
+			case entry.fieldType == tiffASCII && entry.count <= 4:
+				var field [4]byte
+				copy(field[:], name)
+				bw.Write(field[:])
+			default:
+				binary.Write(bw, le, entry.value)
+			}
+		}
+		binary.Write(bw, le, next)
+		bw.Write(name)
+		bw.Write(make([]byte, namePadding))
+		buf := make([]byte, 4)
+		for y := 0; y < tensor.height; y++ {
+			for x := 0; x < tensor.width; x++ {
+				le.PutUint32(buf, math.Float32bits(tensor.At(x, y, c)))
+				bw.Write(buf)
+			}
+		}
+		offset = next
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,59 @@
+package draw
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestWriteTIFF(t *testing.T) {
+	tensor, _ := NewTensor(3, 2, testChannels)
+	tensor.DrawRow([][]float32{{1, 2, 65535}, {0.5, 0.25, 0}})
+	var buf bytes.Buffer
+	if err := tensor.WriteTIFF(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	le := binary.LittleEndian
+	if string(data[:2]) != "II" || le.Uint16(data[2:]) != 42 {
+		t.Fatal("incorrect TIFF header")
+	}
+	// walk the pages, checking each holds a channel
+	offset := le.Uint32(data[4:])
+	var page int
+	for offset != 0 {
+		tags := make(map[uint16][]byte)
+		numEntries := int(le.Uint16(data[offset:]))
+		for i := 0; i < numEntries; i++ {
+			entry := data[int(offset)+2+i*12:]
+			tags[le.Uint16(entry)] = entry[4:12]
+		}
+		if le.Uint32(tags[tiffImageWidth][4:]) != 3 || le.Uint32(tags[tiffImageLength][4:]) != 2 {
+			t.Fatalf("page %d has the wrong size", page)
+		}
+		if le.Uint16(tags[tiffSampleFormat][4:]) != 3 {
+			t.Fatalf("page %d is not floating point", page)
+		}
+		nameLen := le.Uint32(tags[tiffPageName])
+		nameOffset := le.Uint32(tags[tiffPageName][4:])
+		if name := string(data[nameOffset : nameOffset+nameLen-1]); name != testChannels[page].Name {
+			t.Fatalf("page %d has the wrong name: %v", page, name)
+		}
+		pixels := le.Uint32(tags[tiffStripOffsets][4:])
+		if pixels%4 != 0 {
+			t.Fatal("pixels are not word aligned")
+		}
+		for x := 0; x < 3; x++ {
+			value := math.Float32frombits(le.Uint32(data[int(pixels)+x*4:]))
+			if value != tensor.At(x, 0, page) {
+				t.Fatalf("page %d pixel %d incorrect: %v", page, x, value)
+			}
+		}
+		offset = le.Uint32(data[int(offset)+2+numEntries*12:])
+		page++
+	}
+	if page != len(testChannels) {
+		t.Fatalf("expected %d pages, found %d", len(testChannels), page)
+	}
+}
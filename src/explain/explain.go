@@ -0,0 +1,268 @@
+// explain contains the types/methods/functions to map saliency maps over thor images back to the taxa and sketch bins they were drawn from
+
+package explain
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/will-rowe/thor/src/colour"
+	"github.com/will-rowe/thor/src/draw"
+	"github.com/will-rowe/thor/src/hammer"
+)
+
+// ROW_EMPTY is the status of an image row that is not in the legend (e.g. the end of an image that was not padded)
+const ROW_EMPTY = "empty"
+
+// Saliency holds the importance of each pixel of an image, as absolute values
+type Saliency struct {
+	height int
+	width  int
+	values []float64
+}
+
+// NewSaliency is the Saliency constructor, the values are row major
+func NewSaliency(height, width int, values []float64) (*Saliency, error) {
+	if height < 1 || width < 1 {
+		return nil, fmt.Errorf("saliency map must be at least 1x1 (%d x %d)", height, width)
+	}
+	if len(values) != height*width {
+		return nil, fmt.Errorf("saliency map shape (%d x %d) does not match the number of values (%d)", height, width, len(values))
+	}
+	saliency := &Saliency{height: height, width: width, values: make([]float64, len(values))}
+	for i, value := range values {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, fmt.Errorf("saliency map holds an invalid value: %v", value)
+		}
+		saliency.values[i] = math.Abs(value)
+	}
+	return saliency, nil
+}
+
+// GetShape is a method to get the height and width of the saliency map
+func (saliency *Saliency) GetShape() (int, int) {
+	return saliency.height, saliency.width
+}
+
+// At is a method to get the saliency of a pixel
+func (saliency *Saliency) At(x, y int) float64 {
+	return saliency.values[y*saliency.width+x]
+}
+
+// LoadSaliency reads a saliency map from a .npy array or a grayscale PNG
+// npy arrays can be (height, width) or (height, width, channels), with the channels summed, and leading dimensions of size 1 are ignored
+func LoadSaliency(path string) (*Saliency, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".npy":
+		shape, data, err := draw.ReadNpy(fh)
+		if err != nil {
+			return nil, err
+		}
+		for len(shape) > 2 && shape[0] == 1 {
+			shape = shape[1:]
+		}
+		channels := 1
+		switch len(shape) {
+		case 2:
+		case 3:
+			channels = shape[2]
+		default:
+			return nil, fmt.Errorf("saliency array must have 2 or 3 dimensions, not %d", len(shape))
+		}
+		values := make([]float64, shape[0]*shape[1])
+		for i, value := range data {
+			values[i/channels] += math.Abs(float64(value))
+		}
+		return NewSaliency(shape[0], shape[1], values)
+	case ".png":
+		img, err := png.Decode(fh)
+		if err != nil {
+			return nil, err
+		}
+		return saliencyFromImage(img)
+	}
+	return nil, fmt.Errorf("saliency map must be a .npy array or a grayscale .png: %v", path)
+}
+
+// saliencyFromImage reads a grayscale image as a saliency map, scaling each pixel to between 0 and 1
+func saliencyFromImage(img image.Image) (*Saliency, error) {
+	if model := img.ColorModel(); model != color.GrayModel && model != color.Gray16Model {
+		return nil, fmt.Errorf("saliency PNG must be grayscale")
+	}
+	bounds := img.Bounds()
+	values := make([]float64, 0, bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			values = append(values, float64(color.Gray16Model.Convert(img.At(x, y)).(color.Gray16).Y)/math.MaxUint16)
+		}
+	}
+	return NewSaliency(bounds.Dy(), bounds.Dx(), values)
+}
+
+// RowImportance is the saliency of an image row
+type RowImportance struct {
+	Row        int
+	Genus      string
+	Status     string
+	Importance float64
+	Share      float64
+}
+
+// GenusImportance is the saliency of the rows drawn for a genus
+type GenusImportance struct {
+	Genus      string
+	Importance float64
+	Share      float64
+}
+
+// BinImportance is the saliency of a sketch bin (an image column), along with the genus row that contributes the most to it
+type BinImportance struct {
+	Bin         int
+	Importance  float64
+	Share       float64
+	TopGenus    string
+	SketchValue uint16
+}
+
+// Explanation holds the saliency of a sample's image, aggregated by row, genus and sketch bin
+// the genera are ranked by decreasing importance, and the share is the fraction of the image's total saliency
+type Explanation struct {
+	Sample string
+	Total  float64
+	Rows   []RowImportance
+	Genera []GenusImportance
+	Bins   []BinImportance
+}
+
+// Explain aggregates a saliency map over an image using the image's row legend
+// the colour sketch store is used to check the image width and to get the sketch value of the top genus in each bin
+func Explain(sample string, saliency *Saliency, legend []hammer.Row, colourStore colour.ColourSketchStore) (*Explanation, error) {
+	if sketchLength := colourStore.GetSketchLength(); saliency.width != sketchLength {
+		return nil, fmt.Errorf("saliency map width (%d) does not match the colour sketch length (%d)", saliency.width, sketchLength)
+	}
+	explanation := &Explanation{
+		Sample: sample,
+		Rows:   make([]RowImportance, saliency.height),
+		Bins:   make([]BinImportance, saliency.width),
+	}
+	for y := range explanation.Rows {
+		explanation.Rows[y] = RowImportance{Row: y, Status: ROW_EMPTY}
+	}
+	// map the image rows to their genus
+	values := make(map[string][]uint16)
+	for _, row := range legend {
+		if row.Index == -1 {
+			continue
+		}
+		if row.Index >= saliency.height {
+			return nil, fmt.Errorf("legend row %d is outside the saliency map (height %d)", row.Index, saliency.height)
+		}
+		explanation.Rows[row.Index].Genus = row.Genus
+		explanation.Rows[row.Index].Status = row.Status
+		if row.Status != hammer.ROW_DRAWN {
+			continue
+		}
+		cs, ok := colourStore[row.Genus]
+		if !ok {
+			return nil, fmt.Errorf("legend genus is not in the colour sketches (was the image made with this store?): %v", row.Genus)
+		}
+		values[row.Genus] = cs.Values()
+	}
+	// sum the saliency for each row and bin
+	genera := make(map[string]float64)
+	binMax := make([]float64, saliency.width)
+	for x := range explanation.Bins {
+		explanation.Bins[x].Bin = x
+	}
+	for y := range explanation.Rows {
+		row := &explanation.Rows[y]
+		for x := range explanation.Bins {
+			value := saliency.At(x, y)
+			row.Importance += value
+			bin := &explanation.Bins[x]
+			bin.Importance += value
+			if row.Status == hammer.ROW_DRAWN && (bin.TopGenus == "" || value > binMax[x]) {
+				binMax[x] = value
+				bin.TopGenus = row.Genus
+				bin.SketchValue = values[row.Genus][x]
+			}
+		}
+		explanation.Total += row.Importance
+		if row.Status == hammer.ROW_DRAWN {
+			genera[row.Genus] += row.Importance
+		}
+	}
+	// get each share of the total saliency, and rank the genera
+	share := func(importance float64) float64 {
+		if explanation.Total == 0 {
+			return 0
+		}
+		return importance / explanation.Total
+	}
+	for i := range explanation.Rows {
+		explanation.Rows[i].Share = share(explanation.Rows[i].Importance)
+	}
+	for i := range explanation.Bins {
+		explanation.Bins[i].Share = share(explanation.Bins[i].Importance)
+	}
+	for genus, importance := range genera {
+		explanation.Genera = append(explanation.Genera, GenusImportance{genus, importance, share(importance)})
+	}
+	sort.Slice(explanation.Genera, func(i, j int) bool {
+		if explanation.Genera[i].Importance != explanation.Genera[j].Importance {
+			return explanation.Genera[i].Importance > explanation.Genera[j].Importance
+		}
+		return explanation.Genera[i].Genus < explanation.Genera[j].Genus
+	})
+	return explanation, nil
+}
+
+// CohortImportance is the importance of a genus averaged across a cohort of samples
+// samples that don't have the genus drawn contribute a share of 0
+type CohortImportance struct {
+	Genus          string
+	MeanShare      float64
+	MeanImportance float64
+	Samples        int
+}
+
+// Cohort averages the genus importances of a set of explanations, ranked by decreasing mean share
+// the share is used for ranking so that each sample contributes equally, regardless of the scale of its saliency map
+func Cohort(explanations []*Explanation) []CohortImportance {
+	cohort := make(map[string]*CohortImportance)
+	for _, explanation := range explanations {
+		for _, genus := range explanation.Genera {
+			if _, ok := cohort[genus.Genus]; !ok {
+				cohort[genus.Genus] = &CohortImportance{Genus: genus.Genus}
+			}
+			cohort[genus.Genus].MeanShare += genus.Share
+			cohort[genus.Genus].MeanImportance += genus.Importance
+			cohort[genus.Genus].Samples++
+		}
+	}
+	ranked := make([]CohortImportance, 0, len(cohort))
+	for _, genus := range cohort {
+		genus.MeanShare /= float64(len(explanations))
+		genus.MeanImportance /= float64(len(explanations))
+		ranked = append(ranked, *genus)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].MeanShare != ranked[j].MeanShare {
+			return ranked[i].MeanShare > ranked[j].MeanShare
+		}
+		return ranked[i].Genus < ranked[j].Genus
+	})
+	return ranked
+}
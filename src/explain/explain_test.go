@@ -0,0 +1,168 @@
+package explain
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/will-rowe/thor/src/colour"
+	"github.com/will-rowe/thor/src/draw"
+	"github.com/will-rowe/thor/src/hammer"
+)
+
+// makeStore returns a colour sketch store holding two genera with a sketch length of 3
+func makeStore() colour.ColourSketchStore {
+	css := make(colour.ColourSketchStore)
+	css["Bacteroides"] = colour.NewColourSketch([]uint32{1, 2, 3}, "Bacteroides")
+	css["Escherichia"] = colour.NewColourSketch([]uint32{4, 5, 6}, "Escherichia")
+	return css
+}
+
+// makeLegend returns a legend with a genus on each of the first two rows, a missing genus and a padding row
+func makeLegend() []hammer.Row {
+	return []hammer.Row{
+		{Index: 0, Genus: "Bacteroides", Status: hammer.ROW_DRAWN},
+		{Index: 1, Genus: "Escherichia", Status: hammer.ROW_DRAWN},
+		{Index: -1, Genus: "Clostridium", Status: hammer.ROW_MISSING},
+		{Index: 2, Status: hammer.ROW_PADDING},
+	}
+}
+
+// test the saliency constructor
+func TestNewSaliency(t *testing.T) {
+	if _, err := NewSaliency(0, 2, nil); err == nil {
+		t.Fatal("empty saliency map should return an error")
+	}
+	if _, err := NewSaliency(2, 2, []float64{1, 2, 3}); err == nil {
+		t.Fatal("saliency map shape mismatch should return an error")
+	}
+	saliency, err := NewSaliency(2, 2, []float64{1, -2, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, w := saliency.GetShape(); h != 2 || w != 2 {
+		t.Fatalf("wrong shape: %d x %d", h, w)
+	}
+	if saliency.At(1, 0) != 2 || saliency.At(0, 1) != 3 {
+		t.Fatal("saliency should be row major and absolute")
+	}
+}
+
+// test saliency maps can be read from npy arrays and grayscale PNGs
+func TestLoadSaliency(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thor-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	npyPath := filepath.Join(dir, "saliency.npy")
+	fh, err := os.Create(npyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := draw.WriteNpy(fh, []int{1, 2, 3, 2}, []float32{1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6}); err != nil {
+		t.Fatal(err)
+	}
+	fh.Close()
+	saliency, err := LoadSaliency(npyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, w := saliency.GetShape(); h != 2 || w != 3 {
+		t.Fatalf("wrong shape for npy saliency map: %d x %d", h, w)
+	}
+	if saliency.At(2, 1) != 12 {
+		t.Fatalf("channels should be summed, got %v", saliency.At(2, 1))
+	}
+	pngPath := filepath.Join(dir, "saliency.png")
+	img := image.NewGray(image.Rect(0, 0, 3, 2))
+	img.SetGray(1, 1, color.Gray{255})
+	fh, err = os.Create(pngPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(fh, img); err != nil {
+		t.Fatal(err)
+	}
+	fh.Close()
+	if saliency, err = LoadSaliency(pngPath); err != nil {
+		t.Fatal(err)
+	}
+	if saliency.At(1, 1) != 1 || saliency.At(0, 0) != 0 {
+		t.Fatal("grayscale PNG should be scaled to 0-1")
+	}
+	if _, err := LoadSaliency(filepath.Join(dir, "saliency.txt")); err == nil {
+		t.Fatal("unsupported saliency map format should return an error")
+	}
+}
+
+// test a saliency map is aggregated by row, genus and sketch bin
+func TestExplain(t *testing.T) {
+	css := makeStore()
+	saliency, err := NewSaliency(3, 3, []float64{
+		1, 0, 4,
+		2, 3, 1,
+		0, 0, 9,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	explanation, err := Explain("s1", saliency, makeLegend(), css)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if explanation.Total != 20 {
+		t.Fatalf("expected a total saliency of 20, got %v", explanation.Total)
+	}
+	if explanation.Rows[2].Status != hammer.ROW_PADDING || explanation.Rows[2].Share != 0.45 {
+		t.Fatalf("unexpected padding row: %+v", explanation.Rows[2])
+	}
+	if len(explanation.Genera) != 2 || explanation.Genera[0].Genus != "Escherichia" || explanation.Genera[0].Importance != 6 {
+		t.Fatalf("genera not ranked: %+v", explanation.Genera)
+	}
+	bin := explanation.Bins[1]
+	if bin.Importance != 3 || bin.TopGenus != "Escherichia" || bin.SketchValue != css["Escherichia"].Values()[1] {
+		t.Fatalf("unexpected bin: %+v", bin)
+	}
+	if explanation.Bins[2].TopGenus != "Bacteroides" || explanation.Bins[2].Importance != 14 {
+		t.Fatalf("padding rows should count towards the bin but not be its top genus: %+v", explanation.Bins[2])
+	}
+	wide, _ := NewSaliency(3, 4, make([]float64, 12))
+	if _, err := Explain("s1", wide, makeLegend(), css); err == nil {
+		t.Fatal("saliency map width that doesn't match the sketch length should return an error")
+	}
+	legend := append(makeLegend(), hammer.Row{Index: 2, Genus: "Clostridium", Status: hammer.ROW_DRAWN})
+	if _, err := Explain("s1", saliency, legend, css); err == nil {
+		t.Fatal("legend genus missing from the colour sketches should return an error")
+	}
+	legend = append(makeLegend(), hammer.Row{Index: 3, Genus: "Bacteroides", Status: hammer.ROW_DRAWN})
+	if _, err := Explain("s1", saliency, legend, css); err == nil {
+		t.Fatal("legend row outside the saliency map should return an error")
+	}
+}
+
+// test genus importances are averaged across a cohort
+func TestCohort(t *testing.T) {
+	css := makeStore()
+	s1, _ := NewSaliency(3, 3, []float64{3, 0, 0, 1, 0, 0, 0, 0, 0})
+	s2, _ := NewSaliency(3, 3, []float64{1, 0, 0, 0, 0, 0, 0, 0, 0})
+	e1, err := Explain("s1", s1, makeLegend(), css)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e2, err := Explain("s2", s2, makeLegend()[:1], css)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cohort := Cohort([]*Explanation{e1, e2})
+	if len(cohort) != 2 || cohort[0].Genus != "Bacteroides" || cohort[0].MeanShare != 0.875 || cohort[0].Samples != 2 {
+		t.Fatalf("unexpected cohort ranking: %+v", cohort)
+	}
+	if cohort[1].MeanShare != 0.125 || cohort[1].MeanImportance != 0.5 || cohort[1].Samples != 1 {
+		t.Fatalf("samples without a genus should count as 0: %+v", cohort[1])
+	}
+}
@@ -0,0 +1,48 @@
+package hammer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// zipMagic are the first bytes of a zip file (e.g. a QIIME2 .qza artifact)
+var zipMagic = []byte("PK\x03\x04")
+
+// isZip checks if a buffered reader holds a zip file, without consuming any input
+func isZip(br *bufio.Reader) bool {
+	magic, err := br.Peek(len(zipMagic))
+	return err == nil && bytes.Equal(magic, zipMagic)
+}
+
+// openArtifactData opens a file from the data directory of a QIIME2 artifact (<uuid>/data/<file>)
+// it returns the file and a name for it to use when reporting errors
+func openArtifactData(data []byte, name, file string) (io.ReadCloser, string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, "", fmt.Errorf("could not open QIIME2 artifact (%v): %v", name, err)
+	}
+	var found []string
+	for _, f := range archive.File {
+		parts := strings.Split(f.Name, "/")
+		if len(parts) != 3 || parts[1] != "data" {
+			continue
+		}
+		base := parts[2]
+		found = append(found, base)
+		if base == file {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, "", err
+			}
+			return rc, name + ":" + f.Name, nil
+		}
+	}
+	if len(found) == 0 {
+		return nil, "", fmt.Errorf("no data directory found in QIIME2 artifact: %v", name)
+	}
+	return nil, "", fmt.Errorf("QIIME2 artifact (%v) has no %v data file (found: %v)", name, file, strings.Join(found, ", "))
+}
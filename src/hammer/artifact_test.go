@@ -0,0 +1,57 @@
+package hammer
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// makeArtifact creates an in-memory QIIME2 artifact holding the given data files
+func makeArtifact(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for name, content := range files {
+		fw, err := w.Create("5e7c4c2a-1d2b-4f3e-9a8b-0c1d2e3f4a5b/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// test reading feature tables and taxonomies from QIIME2 artifacts
+func TestQZA(t *testing.T) {
+	artifact := makeArtifact(t, map[string]string{"metadata.yaml": "uuid: x", "data/feature-table.biom": string(makeBiomHDF5(t, true, []int32{0, 1, 3, 4}))})
+	for _, format := range []string{"qza", "biom"} {
+		table, err := NewOTUTableWithOptions(bytes.NewReader(artifact), TableOptions{Format: format, Name: "table.qza"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if table.GetNumSamples() != 2 || table.GetTotalGenusOTUs() != 2 {
+			t.Fatal("feature table not read from artifact")
+		}
+	}
+	_, err := NewOTUTableWithOptions(bytes.NewReader(makeArtifact(t, map[string]string{"data/taxonomy.tsv": ""})), TableOptions{Format: "qza", Name: "taxonomy.qza"})
+	if err == nil || !strings.Contains(err.Error(), "feature-table.biom") {
+		t.Fatalf("an artifact without a feature table should raise an error: %v", err)
+	}
+	taxonomy, err := ReadTaxonomy(bytes.NewReader(makeArtifact(t, map[string]string{"data/taxonomy.tsv": "Feature ID\tTaxon\tConfidence\nASV_1\td__Bacteria; g__Bacteroides\t0.9\n"})), "taxonomy.qza", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taxonomy.GetGenus("ASV_1") != "Bacteroides" {
+		t.Fatal("taxonomy not read from artifact")
+	}
+	if _, err := ReadTaxonomy(bytes.NewReader(makeArtifact(t, map[string]string{"data/feature-table.biom": ""})), "table.qza", 0); err == nil {
+		t.Fatal("an artifact without a taxonomy should raise an error")
+	}
+	// a truncated HDF5 table
+	if _, err := NewOTUTableWithOptions(bytes.NewReader(makeArtifact(t, map[string]string{"data/feature-table.biom": "\x89HDF\r\n\x1a\n\x00"})), TableOptions{Format: "qza"}); err == nil {
+		t.Fatal("a truncated HDF5 BIOM table should raise an error")
+	}
+}
@@ -0,0 +1,152 @@
+package hammer
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// AUGMENT_SUFFIX is added to a sample name, along with the variant number, to name each augmented variant
+const AUGMENT_SUFFIX = "-aug"
+
+// Augmentation holds the settings used to generate training variants of each sample
+// the variants are made from the abundances, before they are coloured, so that the colour encoding is not broken
+type Augmentation struct {
+	Variants         int     `json:"variants"`          // the number of variants to make for each sample
+	Seed             int64   `json:"seed"`              // the seed for the variants, each variant is seeded from this, the sample name and the variant number
+	Subsample        float64 `json:"subsample"`         // the fraction of counts kept when subsampling each sample (1 keeps every count, ignored for relative abundances)
+	DropoutThreshold float64 `json:"dropout_threshold"` // the relative abundance below which an OTU may be dropped
+	Dropout          float64 `json:"dropout"`           // the probability of dropping each OTU below the dropout threshold
+	Noise            float64 `json:"noise"`             // the standard deviation of the log-normal noise applied to each abundance (0 adds no noise)
+}
+
+// AugmentedSample links an augmented variant to the sample it was made from
+type AugmentedSample struct {
+	Name    string
+	Source  string
+	Variant int
+	Seed    int64
+}
+
+// check is a method to check the augmentation settings are valid
+func (augmentation Augmentation) check() error {
+	if augmentation.Variants < 1 {
+		return fmt.Errorf("the number of augmented variants must be at least 1")
+	}
+	if augmentation.Subsample <= 0 || augmentation.Subsample > 1 {
+		return fmt.Errorf("the subsample fraction must be > 0 and <= 1: %v", augmentation.Subsample)
+	}
+	for _, value := range []float64{augmentation.DropoutThreshold, augmentation.Dropout} {
+		if value < 0 || value > 1 || math.IsNaN(value) {
+			return fmt.Errorf("the dropout threshold and probability must be fractions between 0 and 1")
+		}
+	}
+	if augmentation.Noise < 0 || math.IsNaN(augmentation.Noise) || math.IsInf(augmentation.Noise, 0) {
+		return fmt.Errorf("invalid augmentation noise: %v", augmentation.Noise)
+	}
+	return nil
+}
+
+// Augment is a method to make a new OTU table holding the augmented variants of each sample
+// each variant is subsampled, then OTUs below the dropout threshold are randomly dropped, then noise is applied to the remaining abundances
+// the new table keeps the abundance cap and filter of the original table so that the variants are scaled in the same way
+func (otuTable *OTUTable) Augment(augmentation Augmentation) (*OTUTable, []AugmentedSample, error) {
+	if err := augmentation.check(); err != nil {
+		return nil, nil, err
+	}
+	augmented := &OTUTable{
+		program:      otuTable.program,
+		comments:     otuTable.comments,
+		unclassified: otuTable.unclassified,
+		relative:     otuTable.relative,
+		filter:       otuTable.filter,
+		depth:        otuTable.depth,
+	}
+	samples := make([]AugmentedSample, 0, len(otuTable.sampleNames)*augmentation.Variants)
+	genera := make(map[string]struct{})
+	for i, name := range otuTable.sampleNames {
+		// sort the genera so that the variants don't depend on map ordering
+		names := make([]string, 0, len(otuTable.sampleData[i]))
+		for genus := range otuTable.sampleData[i] {
+			names = append(names, genus)
+		}
+		sort.Strings(names)
+		for v := 1; v <= augmentation.Variants; v++ {
+			seed := variantSeed(augmentation.Seed, string(name), v)
+			sampleData := augmentation.vary(otuTable.sampleData[i], names, otuTable.IsRelative(), rand.New(rand.NewSource(seed)))
+			for genus := range sampleData {
+				genera[genus] = struct{}{}
+			}
+			sample := AugmentedSample{
+				Name:    fmt.Sprintf("%v%v%d", string(name), AUGMENT_SUFFIX, v),
+				Source:  string(name),
+				Variant: v,
+				Seed:    seed,
+			}
+			augmented.sampleNames = append(augmented.sampleNames, []byte(sample.Name))
+			augmented.sampleData = append(augmented.sampleData, sampleData)
+			samples = append(samples, sample)
+		}
+	}
+	augmented.topN = make([][]OTU, len(augmented.sampleNames))
+	augmented.totalOTUs = len(genera)
+	return augmented, samples, nil
+}
+
+// vary is a method to make a single variant of a sample's abundances
+func (augmentation Augmentation) vary(sampleData map[string]float64, names []string, relative bool, rng *rand.Rand) map[string]float64 {
+	variant := make(map[string]float64, len(names))
+	var total float64
+	for _, genus := range names {
+		abundance := sampleData[genus]
+		if !relative && augmentation.Subsample < 1 {
+			abundance = subsample(abundance, augmentation.Subsample, rng)
+		}
+		if abundance > 0 {
+			variant[genus] = abundance
+			total += abundance
+		}
+	}
+	for _, genus := range names {
+		abundance, ok := variant[genus]
+		if !ok {
+			continue
+		}
+		// draw for every OTU so that the random sequence doesn't depend on which OTUs are below the threshold
+		drop := rng.Float64() < augmentation.Dropout
+		if drop && abundance/total < augmentation.DropoutThreshold {
+			delete(variant, genus)
+			continue
+		}
+		if augmentation.Noise > 0 {
+			variant[genus] = abundance * math.Exp(rng.NormFloat64()*augmentation.Noise)
+		}
+	}
+	return variant
+}
+
+// subsample draws the number of counts kept from a binomial distribution, using a normal approximation for large counts
+func subsample(count, fraction float64, rng *rand.Rand) float64 {
+	n := int(math.Round(count))
+	if n < 1000 {
+		var kept int
+		for i := 0; i < n; i++ {
+			if rng.Float64() < fraction {
+				kept++
+			}
+		}
+		return float64(kept)
+	}
+	mean := float64(n) * fraction
+	kept := math.Round(mean + rng.NormFloat64()*math.Sqrt(mean*(1-fraction)))
+	return math.Max(0, math.Min(float64(n), kept))
+}
+
+// variantSeed returns the seed for a variant of a sample, so that each variant is reproducible regardless of the processing order
+func variantSeed(seed int64, sample string, variant int) int64 {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%d\t%v\t%d", seed, sample, variant)
+	return int64(hash.Sum64() >> 1)
+}
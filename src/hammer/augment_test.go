@@ -0,0 +1,80 @@
+package hammer
+
+import (
+	"reflect"
+	"testing"
+)
+
+// test the augmentation settings are checked
+func TestAugmentationCheck(t *testing.T) {
+	table, err := NewOTUTableFromMaps(map[string]map[string]float64{"s1": {"Bacteroides": 100}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, augmentation := range []Augmentation{
+		{Variants: 0, Subsample: 1},
+		{Variants: 1, Subsample: 0},
+		{Variants: 1, Subsample: 1, Dropout: 2},
+		{Variants: 1, Subsample: 1, Noise: -1},
+	} {
+		if _, _, err := table.Augment(augmentation); err == nil {
+			t.Fatalf("invalid augmentation should raise an error: %+v", augmentation)
+		}
+	}
+}
+
+// test the augmented variants are reproducible and linked to their source
+func TestAugment(t *testing.T) {
+	table, err := NewOTUTableFromMaps(map[string]map[string]float64{
+		"s1": {"Bacteroides": 4000, "Streptococcus": 500, "Simonsiella": 5},
+		"s2": {"Bacteroides": 10, "Propionibacterium": 2000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	augmentation := Augmentation{Variants: 3, Seed: 42, Subsample: 0.5, DropoutThreshold: 0.01, Dropout: 1, Noise: 0.1}
+	augmented, samples, err := table.Augment(augmentation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if augmented.GetNumSamples() != 6 || len(samples) != 6 {
+		t.Fatalf("expected 6 variants, got %d", augmented.GetNumSamples())
+	}
+	if samples[0].Name != "s1-aug1" || samples[0].Source != "s1" || samples[0].Variant != 1 {
+		t.Fatalf("variant not linked to its source: %+v", samples[0])
+	}
+	if augmented.relative != table.relative {
+		t.Fatal("variants should be scaled the same way as the original table")
+	}
+	for i, sample := range samples {
+		sampleData, _ := augmented.GetSampleData(i)
+		if sample.Source == "s1" {
+			if _, ok := sampleData["Simonsiella"]; ok {
+				t.Fatal("low abundance OTU should have been dropped")
+			}
+			if sampleData["Bacteroides"] == 4000 || sampleData["Bacteroides"] == 0 {
+				t.Fatalf("abundance not subsampled: %v", sampleData["Bacteroides"])
+			}
+		}
+	}
+	// the same seed gives the same variants
+	again, _, err := table.Augment(augmentation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again.sampleData, augmented.sampleData) {
+		t.Fatal("variants are not reproducible")
+	}
+	augmentation.Seed = 43
+	other, _, err := table.Augment(augmentation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(other.sampleData, augmented.sampleData) {
+		t.Fatal("a different seed should give different variants")
+	}
+	// the original table is unchanged
+	if sampleData, _ := table.GetSampleData(0); sampleData["Bacteroides"] != 4000 {
+		t.Fatal("augmentation should not change the original table")
+	}
+}
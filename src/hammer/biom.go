@@ -0,0 +1,334 @@
+package hammer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/will-rowe/thor/src/hdf5"
+)
+
+// hdf5Magic are the first bytes of an HDF5 file (used by BIOM 2.x)
+var hdf5Magic = []byte("\x89HDF\r\n\x1a\n")
+
+// biomTable is a BIOM 1.0 (JSON) table
+type biomTable struct {
+	Format     string         `json:"format"`
+	Type       string         `json:"type"`
+	MatrixType string         `json:"matrix_type"`
+	Shape      []int          `json:"shape"`
+	Data       [][]float64    `json:"data"`
+	Rows       []biomMetadata `json:"rows"`
+	Columns    []biomMetadata `json:"columns"`
+}
+
+// biomMetadata is a BIOM row (observation) or column (sample)
+type biomMetadata struct {
+	ID       string                 `json:"id"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+// getLineage returns the taxonomy from the metadata of a BIOM observation, which can be a list of ranks or a single string
+func (metadata biomMetadata) getLineage() (string, bool) {
+	for _, key := range []string{"taxonomy", "Taxonomy", "Consensus Lineage"} {
+		switch taxonomy := metadata.Metadata[key].(type) {
+		case string:
+			return taxonomy, true
+		case []interface{}:
+			ranks := make([]string, 0, len(taxonomy))
+			for _, rank := range taxonomy {
+				if r, ok := rank.(string); ok {
+					ranks = append(ranks, r)
+				}
+			}
+			return strings.Join(ranks, ";"), true
+		}
+	}
+	return "", false
+}
+
+// biomMatrix collects the values of a BIOM table into the otuTable
+type biomMatrix struct {
+	otuTable *OTUTable
+	name     string
+	rows     []string // the observation ids
+	columns  []string // the sample ids
+	genera   []string
+	totals   []float64
+}
+
+// newBiomMatrix is a method to set up the samples of the otuTable and get the genus of each observation
+// lineage returns the taxonomy metadata of an observation, if there is none the genus comes from opts.Taxonomy
+func (otuTable *OTUTable) newBiomMatrix(opts TableOptions, rows, columns []string, lineage func(row int) (string, bool)) (*biomMatrix, error) {
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("%v: no samples found in BIOM table", opts.Name)
+	}
+	matrix := &biomMatrix{
+		otuTable: otuTable,
+		name:     opts.Name,
+		rows:     rows,
+		columns:  columns,
+		genera:   make([]string, len(rows)),
+		totals:   make([]float64, len(columns)),
+	}
+	for i, id := range rows {
+		var taxonomy Lineage
+		if lineageString, ok := lineage(i); ok {
+			taxonomy = ParseLineage(lineageString)
+		} else if opts.Taxonomy != nil {
+			taxonomy = opts.Taxonomy[id]
+		} else {
+			return nil, fmt.Errorf("%v: observation %v has no taxonomy metadata, a taxonomy file is needed for this table", opts.Name, id)
+		}
+		matrix.genera[i] = taxonomy.Get(GENUS)
+		if matrix.genera[i] == "" {
+			otuTable.unclassified++
+		} else {
+			otuTable.totalOTUs++
+			otuTable.setDepth(matrix.genera[i], taxonomy.Depth())
+		}
+	}
+	otuTable.sampleNames = make([][]byte, len(columns))
+	otuTable.sampleData = make([]map[string]float64, len(columns))
+	otuTable.topN = make([][]OTU, len(columns))
+	for i, id := range columns {
+		otuTable.sampleNames[i] = []byte(id)
+		otuTable.sampleData[i] = make(map[string]float64)
+	}
+	return matrix, nil
+}
+
+// add is a method to add the value of an observation in a sample
+func (matrix *biomMatrix) add(row, column int, value float64) error {
+	if row < 0 || row >= len(matrix.rows) || column < 0 || column >= len(matrix.columns) {
+		return fmt.Errorf("%v: BIOM matrix position out of range: [%d, %d]", matrix.name, row, column)
+	}
+	if value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("%v: invalid abundance for observation %v in sample %v: %v", matrix.name, matrix.rows[row], matrix.columns[column], value)
+	}
+	matrix.totals[column] += value
+	if matrix.genera[row] == "" {
+		return nil
+	}
+	matrix.otuTable.sampleData[column][matrix.genera[row]] += value
+	return nil
+}
+
+// readBiom will load a BIOM table into the otuTable, either BIOM 1.0 (JSON), BIOM 2.x (HDF5) or the BIOM 2.x table of a QIIME2 .qza feature table artifact
+// the observation taxonomy metadata is used if there is any, otherwise the genera come from opts.Taxonomy
+func (otuTable *OTUTable) readBiom(fh io.Reader, opts TableOptions) error {
+	br := bufio.NewReader(fh)
+	if isZip(br) {
+		return otuTable.readQZA(br, opts)
+	}
+	if magic, err := br.Peek(len(hdf5Magic)); err == nil && bytes.Equal(magic, hdf5Magic) {
+		data, err := ioutil.ReadAll(br)
+		if err != nil {
+			return err
+		}
+		return otuTable.readBiomHDF5(data, opts)
+	}
+	table := &biomTable{}
+	if err := json.NewDecoder(br).Decode(table); err != nil {
+		return fmt.Errorf("%v: could not decode BIOM table: %v", opts.Name, err)
+	}
+	if len(table.Shape) != 2 || table.Shape[0] != len(table.Rows) || table.Shape[1] != len(table.Columns) {
+		return fmt.Errorf("%v: BIOM shape %v does not match the number of rows (%d) and columns (%d)", opts.Name, table.Shape, len(table.Rows), len(table.Columns))
+	}
+	rows := make([]string, len(table.Rows))
+	for i, row := range table.Rows {
+		rows[i] = row.ID
+	}
+	columns := make([]string, len(table.Columns))
+	for i, column := range table.Columns {
+		columns[i] = column.ID
+	}
+	matrix, err := otuTable.newBiomMatrix(opts, rows, columns, func(row int) (string, bool) {
+		return table.Rows[row].getLineage()
+	})
+	if err != nil {
+		return err
+	}
+	// add the values, sparse matrices hold [row, column, value] triples
+	switch table.MatrixType {
+	case "sparse":
+		for _, entry := range table.Data {
+			if len(entry) != 3 {
+				return fmt.Errorf("%v: sparse BIOM entries must have 3 values", opts.Name)
+			}
+			if err := matrix.add(int(entry[0]), int(entry[1]), entry[2]); err != nil {
+				return err
+			}
+		}
+	case "dense":
+		if len(table.Data) != len(table.Rows) {
+			return fmt.Errorf("%v: dense BIOM matrix has %d rows, expected %d", opts.Name, len(table.Data), len(table.Rows))
+		}
+		for row, values := range table.Data {
+			if len(values) != len(table.Columns) {
+				return fmt.Errorf("%v: dense BIOM matrix row %d has %d values, expected %d", opts.Name, row, len(values), len(table.Columns))
+			}
+			for column, value := range values {
+				if err := matrix.add(row, column, value); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("%v: unsupported BIOM matrix type: %v", opts.Name, table.MatrixType)
+	}
+	otuTable.relative = isRelative(matrix.totals)
+	return nil
+}
+
+// readBiomHDF5 will load a BIOM 2.x (HDF5) table into the otuTable
+// the counts are read from the observation matrix (compressed sparse rows), the taxonomy from the observation metadata if there is any
+func (otuTable *OTUTable) readBiomHDF5(data []byte, opts TableOptions) error {
+	file, err := hdf5.Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("%v: could not open BIOM 2.x table: %v", opts.Name, err)
+	}
+	rows, err := readBiomStrings(file, "observation/ids")
+	if err != nil {
+		return fmt.Errorf("%v: %v", opts.Name, err)
+	}
+	columns, err := readBiomStrings(file, "sample/ids")
+	if err != nil {
+		return fmt.Errorf("%v: %v", opts.Name, err)
+	}
+	lineages, err := readBiomTaxonomy(file, len(rows))
+	if err != nil {
+		return fmt.Errorf("%v: %v", opts.Name, err)
+	}
+	matrix, err := otuTable.newBiomMatrix(opts, rows, columns, func(row int) (string, bool) {
+		if lineages == nil {
+			return "", false
+		}
+		return lineages[row], true
+	})
+	if err != nil {
+		return err
+	}
+	indptr, err := readBiomInts(file, "observation/matrix/indptr")
+	if err != nil {
+		return fmt.Errorf("%v: %v", opts.Name, err)
+	}
+	indices, err := readBiomInts(file, "observation/matrix/indices")
+	if err != nil {
+		return fmt.Errorf("%v: %v", opts.Name, err)
+	}
+	ds, err := file.Dataset("observation/matrix/data")
+	if err != nil {
+		return fmt.Errorf("%v: %v", opts.Name, err)
+	}
+	values, err := ds.Floats()
+	if err != nil {
+		return fmt.Errorf("%v: %v", opts.Name, err)
+	}
+	if len(indptr) != len(rows)+1 || len(indices) != len(values) {
+		return fmt.Errorf("%v: BIOM matrix does not match the number of observations (%d)", opts.Name, len(rows))
+	}
+	for row := range rows {
+		start, end := indptr[row], indptr[row+1]
+		if start < 0 || start > end || end > int64(len(values)) {
+			return fmt.Errorf("%v: invalid BIOM matrix index pointer for observation %v", opts.Name, rows[row])
+		}
+		for i := start; i < end; i++ {
+			if err := matrix.add(row, int(indices[i]), values[i]); err != nil {
+				return err
+			}
+		}
+	}
+	otuTable.relative = isRelative(matrix.totals)
+	return nil
+}
+
+// readBiomStrings reads a 1-D string dataset of a BIOM 2.x table
+func readBiomStrings(file *hdf5.File, path string) ([]string, error) {
+	ds, err := file.Dataset(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(ds.Shape) != 1 {
+		return nil, fmt.Errorf("BIOM %v should be 1-D, not %v", path, ds.Shape)
+	}
+	return ds.Strings()
+}
+
+// readBiomInts reads an integer dataset of a BIOM 2.x table
+func readBiomInts(file *hdf5.File, path string) ([]int64, error) {
+	ds, err := file.Dataset(path)
+	if err != nil {
+		return nil, err
+	}
+	return ds.Ints()
+}
+
+// readBiomTaxonomy reads the observation taxonomy metadata of a BIOM 2.x table, which holds the ranks of each observation (n x ranks), or a lineage string
+// it returns nil if the table has no taxonomy metadata
+func readBiomTaxonomy(file *hdf5.File, n int) ([]string, error) {
+	groups, err := file.List("observation")
+	if err != nil {
+		return nil, err
+	}
+	if !hasMember(groups, "metadata") {
+		return nil, nil
+	}
+	categories, err := file.List("observation/metadata")
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range []string{"taxonomy", "Taxonomy", "Consensus Lineage"} {
+		if !hasMember(categories, key) {
+			continue
+		}
+		ds, err := file.Dataset("observation/metadata/" + key)
+		if err != nil {
+			return nil, err
+		}
+		if len(ds.Shape) == 0 || len(ds.Shape) > 2 || ds.Shape[0] != n {
+			return nil, fmt.Errorf("BIOM taxonomy metadata shape %v does not match the number of observations (%d)", ds.Shape, n)
+		}
+		ranks, err := ds.Strings()
+		if err != nil {
+			return nil, err
+		}
+		width := 1
+		if len(ds.Shape) == 2 {
+			width = ds.Shape[1]
+		}
+		lineages := make([]string, n)
+		for i := range lineages {
+			lineages[i] = strings.Join(ranks[i*width:(i+1)*width], ";")
+		}
+		return lineages, nil
+	}
+	return nil, nil
+}
+
+// hasMember checks if a sorted list of group members includes name
+func hasMember(members []string, name string) bool {
+	i := sort.SearchStrings(members, name)
+	return i < len(members) && members[i] == name
+}
+
+// readQZA will load the BIOM feature table (data/feature-table.biom) from a QIIME2 .qza artifact into the otuTable
+func (otuTable *OTUTable) readQZA(fh io.Reader, opts TableOptions) error {
+	data, err := ioutil.ReadAll(fh)
+	if err != nil {
+		return err
+	}
+	biom, biomName, err := openArtifactData(data, opts.Name, "feature-table.biom")
+	if err != nil {
+		return err
+	}
+	defer biom.Close()
+	opts.Name = biomName
+	return otuTable.readBiom(biom, opts)
+}
@@ -0,0 +1,129 @@
+package hammer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/will-rowe/thor/src/hdf5"
+)
+
+var testBiom = `{"id": null, "format": "Biological Observation Matrix 1.0.0", "type": "OTU table", "matrix_type": "sparse", "shape": [3, 2],
+"rows": [{"id": "OTU_1", "metadata": {"taxonomy": ["k__Bacteria", "p__Bacteroidetes", "g__Bacteroides"]}},
+	{"id": "OTU_2", "metadata": {"taxonomy": "k__Bacteria; p__Bacteroidetes; g__Prevotella"}},
+	{"id": "OTU_3", "metadata": {"taxonomy": ["k__Bacteria", "p__Firmicutes", "g__"]}}],
+"columns": [{"id": "sampleA", "metadata": null}, {"id": "sampleB", "metadata": null}],
+"data": [[0, 0, 10], [1, 0, 5], [1, 1, 20], [2, 1, 2]]}`
+
+// makeBiomHDF5 creates a BIOM 2.1 (HDF5) version of testBiom, the taxonomy metadata is left out if taxonomy is false
+// indptr gives the start of each observation in the compressed sparse rows of the observation matrix
+func makeBiomHDF5(t *testing.T, taxonomy bool, indptr []int32) []byte {
+	writer := hdf5.NewWriter()
+	chunked := hdf5.Options{Chunks: []int{2}, Deflate: 6}
+	type dataset struct {
+		path  string
+		shape []int
+		data  interface{}
+		opts  hdf5.Options
+	}
+	datasets := []dataset{
+		{"observation/ids", []int{3}, []string{"OTU_1", "OTU_2", "OTU_3"}, chunked},
+		{"observation/matrix/data", []int{4}, []float64{10, 5, 20, 2}, chunked},
+		{"observation/matrix/indices", []int{4}, []int32{0, 0, 1, 1}, chunked},
+		{"observation/matrix/indptr", []int{len(indptr)}, indptr, chunked},
+		{"observation/group-metadata/phylogeny", []int{0}, []string{}, hdf5.Options{}},
+		{"sample/ids", []int{2}, []string{"sampleA", "sampleB"}, chunked},
+		{"sample/matrix/data", []int{4}, []float64{10, 5, 20, 2}, hdf5.Options{}},
+	}
+	if taxonomy {
+		datasets = append(datasets, dataset{"observation/metadata/taxonomy", []int{3, 3}, []string{
+			"k__Bacteria", "p__Bacteroidetes", "g__Bacteroides",
+			"k__Bacteria", "p__Bacteroidetes", "g__Prevotella",
+			"k__Bacteria", "p__Firmicutes", "g__"}, hdf5.Options{Chunks: []int{2, 2}, Deflate: 6}})
+	}
+	for _, ds := range datasets {
+		if err := writer.Add(ds.path, ds.shape, ds.data, ds.opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := writer.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// test the BIOM 2.x (HDF5) reader
+func TestBiomHDF5(t *testing.T) {
+	table, err := NewOTUTableWithOptions(bytes.NewReader(makeBiomHDF5(t, true, []int32{0, 1, 3, 4})), TableOptions{Format: "biom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.GetNumSamples() != 2 || table.GetTotalGenusOTUs() != 2 || table.GetUnclassifiedOTUs() != 1 {
+		t.Fatal("BIOM table not parsed correctly")
+	}
+	if string(table.sampleNames[1]) != "sampleB" {
+		t.Fatalf("wrong sample name: %s", table.sampleNames[1])
+	}
+	if data, _ := table.GetSampleData(1); data["Prevotella"] != 20 || len(data) != 1 {
+		t.Fatalf("wrong sample data: %v", data)
+	}
+	if table.GetDepth("Bacteroides") != GENUS {
+		t.Fatal("depth not recorded from the taxonomy metadata")
+	}
+	// without taxonomy metadata the genera come from the taxonomy file
+	noTaxonomy := makeBiomHDF5(t, false, []int32{0, 1, 3, 4})
+	if _, err := NewOTUTableWithOptions(bytes.NewReader(noTaxonomy), TableOptions{Format: "biom"}); err == nil {
+		t.Fatal("a table without taxonomy metadata should need a taxonomy")
+	}
+	taxonomy := Taxonomy{"OTU_1": ParseLineage("g__Bacteroides"), "OTU_2": ParseLineage("g__Bacteroides")}
+	table, err = NewOTUTableWithOptions(bytes.NewReader(noTaxonomy), TableOptions{Format: "biom", Taxonomy: taxonomy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := table.GetSampleData(0); data["Bacteroides"] != 15 {
+		t.Fatalf("wrong sample data: %v", data)
+	}
+	// the index pointer must cover each observation
+	for _, indptr := range [][]int32{{0, 1, 3}, {0, 3, 1, 4}, {0, 1, 3, 5}} {
+		if _, err := NewOTUTableWithOptions(bytes.NewReader(makeBiomHDF5(t, true, indptr)), TableOptions{Format: "biom"}); err == nil {
+			t.Fatalf("expected an error for index pointer: %v", indptr)
+		}
+	}
+}
+
+// test the BIOM reader
+func TestBiom(t *testing.T) {
+	table, err := NewOTUTableWithOptions(strings.NewReader(testBiom), TableOptions{Format: "biom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.GetNumSamples() != 2 || table.GetTotalGenusOTUs() != 2 || table.GetUnclassifiedOTUs() != 1 {
+		t.Fatal("BIOM table not parsed correctly")
+	}
+	if data, _ := table.GetSampleData(0); data["Bacteroides"] != 10 || data["Prevotella"] != 5 {
+		t.Fatalf("wrong sample data: %v", data)
+	}
+	// a dense table without taxonomy metadata
+	dense := `{"matrix_type": "dense", "shape": [2, 1], "rows": [{"id": "ASV_1"}, {"id": "ASV_2"}], "columns": [{"id": "sampleA"}], "data": [[1], [3]]}`
+	if _, err := NewOTUTableWithOptions(strings.NewReader(dense), TableOptions{Format: "biom"}); err == nil {
+		t.Fatal("a table without taxonomy metadata should need a taxonomy")
+	}
+	taxonomy := Taxonomy{"ASV_1": ParseLineage("g__Bacteroides"), "ASV_2": ParseLineage("g__Prevotella")}
+	table, err = NewOTUTableWithOptions(strings.NewReader(dense), TableOptions{Format: "biom", Taxonomy: taxonomy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := table.GetSampleData(0); data["Prevotella"] != 3 {
+		t.Fatalf("wrong sample data: %v", data)
+	}
+	for _, data := range []string{
+		"\x89HDF\r\n\x1a\n\x00\x00",
+		`{"matrix_type": "sparse", "shape": [1, 1], "rows": [{"id": "OTU_1", "metadata": {"taxonomy": "g__A"}}], "columns": [{"id": "s"}], "data": [[0, 5, 1]]}`,
+		`{"matrix_type": "sparse", "shape": [2, 1], "rows": [], "columns": [{"id": "s"}], "data": []}`,
+	} {
+		if _, err := NewOTUTableWithOptions(strings.NewReader(data), TableOptions{Format: "biom"}); err == nil {
+			t.Fatalf("expected an error for BIOM table: %q", data)
+		}
+	}
+}
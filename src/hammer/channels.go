@@ -0,0 +1,122 @@
+package hammer
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/will-rowe/thor/src/colour"
+	"github.com/will-rowe/thor/src/draw"
+)
+
+// the channels that can be drawn for each OTU row of a tensor
+const (
+	CHANNEL_SKETCH     = "sketch"     // the uint16 sketch value
+	CHANNEL_SKETCH_LO  = "sketch_lo"  // the low byte of the sketch value (the R slot of the colour sketch)
+	CHANNEL_SKETCH_HI  = "sketch_hi"  // the high byte of the sketch value (the G slot of the colour sketch)
+	CHANNEL_ABUNDANCE  = "abundance"  // the OTU abundance, scaled by the abundance cap to between 0 and 1
+	CHANNEL_PREVALENCE = "prevalence" // the fraction of samples in the table that contain the genus
+	CHANNEL_MASK       = "mask"       // 1 for genus rows, 0 for padding rows
+	CHANNEL_DEPTH      = "depth"      // the taxonomic depth, the deepest rank that the OTUs of the genus were classified to in the table (species is the maximum)
+)
+
+// Channels are the supported tensor channels, with the value that is scaled to the maximum pixel value
+var Channels = map[string]float32{
+	CHANNEL_SKETCH:     math.MaxUint16,
+	CHANNEL_SKETCH_LO:  math.MaxUint8,
+	CHANNEL_SKETCH_HI:  math.MaxUint8,
+	CHANNEL_ABUNDANCE:  1,
+	CHANNEL_PREVALENCE: 1,
+	CHANNEL_MASK:       1,
+	CHANNEL_DEPTH:      float32(SPECIES),
+}
+
+// DefaultChannels are the tensor channels used if none are requested, they project onto the same RGBA slots as ColourSample
+var DefaultChannels = []string{CHANNEL_SKETCH_LO, CHANNEL_SKETCH_HI, CHANNEL_ABUNDANCE, CHANNEL_MASK}
+
+// GetChannels returns the tensor channels for a list of channel names
+func GetChannels(names []string) ([]draw.Channel, error) {
+	if len(names) == 0 {
+		names = DefaultChannels
+	}
+	channels := make([]draw.Channel, len(names))
+	seen := make(map[string]bool)
+	for i, name := range names {
+		max, ok := Channels[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported tensor channel: %v", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("tensor channel requested more than once: %v", name)
+		}
+		seen[name] = true
+		channels[i] = draw.Channel{Name: name, Max: max}
+	}
+	return channels, nil
+}
+
+// ChannelSample returns the tensor channels for each of the kept OTUs in a sample, as [row][channel][sketch bin]
+// like ColourSample, a row is nil if the genus is not in the colour sketches, or if it is padding and padding is not requested
+// padding rows are all 0s, so their values don't depend on the padding line in the store
+func (otuTable *OTUTable) ChannelSample(i int, colourStore colour.ColourSketchStore, channels []draw.Channel, pad bool) ([][][]float32, error) {
+	if err := otuTable.checkIndex(i); err != nil {
+		return nil, err
+	}
+	sketchLength := colourStore.GetSketchLength()
+	rows := make([][][]float32, len(otuTable.topN[i]))
+	for j, otu := range otuTable.topN[i] {
+		var values []uint16
+		if otu.Name == PAD_LINE {
+			// padding rows are only drawn if the store has a padding line, so that the rows match ColourSample
+			if _, ok := colourStore[PAD_LINE]; !ok || !pad {
+				continue
+			}
+			values = make([]uint16, sketchLength)
+		} else {
+			cs, ok := colourStore[otu.Name]
+			if !ok {
+				continue
+			}
+			values = cs.Values()
+		}
+		rows[j] = make([][]float32, len(channels))
+		for c, channel := range channels {
+			row := make([]float32, len(values))
+			if otu.Name != PAD_LINE {
+				var fill float32
+				switch channel.Name {
+				case CHANNEL_ABUNDANCE:
+					fill = float32(otuTable.scaleAbundance(otu.Abundance))
+				case CHANNEL_PREVALENCE:
+					fill = float32(otuTable.prevalence[otu.Name])
+				case CHANNEL_MASK:
+					fill = 1
+				case CHANNEL_DEPTH:
+					fill = float32(otuTable.GetDepth(otu.Name))
+				}
+				for x, value := range values {
+					switch channel.Name {
+					case CHANNEL_SKETCH:
+						row[x] = float32(value)
+					case CHANNEL_SKETCH_LO:
+						row[x] = float32(value & 0xFF)
+					case CHANNEL_SKETCH_HI:
+						row[x] = float32(value >> 8)
+					default:
+						row[x] = fill
+					}
+				}
+			}
+			rows[j][c] = row
+		}
+	}
+	return rows, nil
+}
+
+// scaleAbundance is a method to scale an abundance by the abundance cap of the table, to between 0 and 1
+func (otuTable *OTUTable) scaleAbundance(abundance float64) float64 {
+	abunCap := otuTable.GetAbundanceCap()
+	if abundance > abunCap {
+		return 1
+	}
+	return abundance / abunCap
+}
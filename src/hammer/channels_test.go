@@ -0,0 +1,111 @@
+package hammer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/will-rowe/thor/src/colour"
+)
+
+// test the channel lookup
+func TestGetChannels(t *testing.T) {
+	channels, err := GetChannels(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(channels) != len(DefaultChannels) {
+		t.Fatal("default channels not used")
+	}
+	if _, err := GetChannels([]string{"phylum"}); err == nil {
+		t.Fatal("unsupported channel should raise an error")
+	}
+	if _, err := GetChannels([]string{CHANNEL_MASK, CHANNEL_MASK}); err == nil {
+		t.Fatal("duplicate channel should raise an error")
+	}
+}
+
+// test the ChannelSample method
+func TestChannelSample(t *testing.T) {
+	table, err := NewOTUTableFromMaps(map[string]map[string]float64{
+		"s1": {"Bacteroides": 2500, "Streptococcus": 10},
+		"s2": {"Bacteroides": 100},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.KeepTopN(3); err != nil {
+		t.Fatal(err)
+	}
+	css := make(colour.ColourSketchStore)
+	css["Bacteroides"] = colour.NewColourSketch([]uint32{1, 258, 65535}, "Bacteroides")
+	css[PAD_LINE] = colour.NewColourSketch([]uint32{1, 1, 1}, PAD_LINE)
+	channels, err := GetChannels([]string{CHANNEL_SKETCH, CHANNEL_SKETCH_LO, CHANNEL_SKETCH_HI, CHANNEL_ABUNDANCE, CHANNEL_PREVALENCE, CHANNEL_MASK})
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, err := table.GetSampleIndex("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := table.ChannelSample(i, css, channels, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Bacteroides, then Streptococcus (not in the store), then padding
+	if len(rows) != 3 || rows[0] == nil || rows[1] != nil || rows[2] == nil {
+		t.Fatalf("unexpected rows: %v", rows)
+	}
+	bacteroides := rows[0]
+	if bacteroides[0][1] != 258 || bacteroides[1][1] != 2 || bacteroides[2][1] != 1 || bacteroides[0][2] != 65535 {
+		t.Fatalf("sketch channels incorrect: %v", bacteroides[:3])
+	}
+	if bacteroides[3][0] != 0.5 || bacteroides[4][0] != 1 || bacteroides[5][0] != 1 {
+		t.Fatalf("abundance, prevalence or mask channel incorrect: %v", bacteroides[3:])
+	}
+	for _, channel := range rows[2] {
+		for _, value := range channel {
+			if value != 0 {
+				t.Fatal("padding rows should be 0")
+			}
+		}
+	}
+	rows, err = table.ChannelSample(i, css, channels, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[2] != nil {
+		t.Fatal("padding row should be skipped when padding is not requested")
+	}
+	if _, err := table.ChannelSample(2, css, channels, false); err == nil {
+		t.Fatal("sample index out of range should raise an error")
+	}
+}
+
+// test the taxonomic depth channel
+func TestDepthChannel(t *testing.T) {
+	data := "#OTU ID\ts1\tConsensus Lineage\nOTU_1\t5\tk__Bacteria;g__Bacteroides;s__fragilis\nOTU_2\t5\tk__Bacteria;g__Bacteroides;s__\nOTU_3\t3\tk__Bacteria;g__Prevotella\n"
+	table, err := NewOTUTableWithOptions(strings.NewReader(data), TableOptions{Format: "qiime", Name: "table.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.GetDepth("Bacteroides") != SPECIES || table.GetDepth("Prevotella") != GENUS {
+		t.Fatalf("wrong taxonomic depths: %v %v", table.GetDepth("Bacteroides"), table.GetDepth("Prevotella"))
+	}
+	if err := table.KeepTopN(2); err != nil {
+		t.Fatal(err)
+	}
+	css := make(colour.ColourSketchStore)
+	css["Bacteroides"] = colour.NewColourSketch([]uint32{1, 2}, "Bacteroides")
+	css["Prevotella"] = colour.NewColourSketch([]uint32{3, 4}, "Prevotella")
+	channels, err := GetChannels([]string{CHANNEL_DEPTH})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := table.ChannelSample(0, css, channels, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0][0][0] != float32(SPECIES) || rows[1][0][1] != float32(GENUS) || channels[0].Max != float32(SPECIES) {
+		t.Fatalf("depth channel incorrect: %v", rows)
+	}
+}
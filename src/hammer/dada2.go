@@ -0,0 +1,100 @@
+package hammer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// sequenceRegex matches an ASV sequence, which DADA2 uses as the feature id
+var sequenceRegex = regexp.MustCompile(`^[ACGTNacgtn]+$`)
+
+// readDada2Seqtab will load a DADA2 sequence table (exported as CSV or TSV) into the otuTable
+// the table can have a row per sample (as from write.csv(seqtab)) or be transposed, with a row per sequence,
+// and the genera come from opts.Taxonomy (e.g. the output of assignTaxonomy)
+// the values of a sequence table are read counts
+func (otuTable *OTUTable) readDada2Seqtab(fh io.Reader, opts TableOptions) error {
+	if opts.Taxonomy == nil {
+		return fmt.Errorf("DADA2 sequence tables need a taxonomy file (e.g. from assignTaxonomy)")
+	}
+	br := bufio.NewReader(fh)
+	first, err := br.Peek(4096)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return err
+	}
+	r := csv.NewReader(br)
+	r.LazyQuotes = true
+	if firstLine := strings.SplitN(string(first), "\n", 2)[0]; strings.Contains(firstLine, "\t") {
+		r.Comma = '\t'
+	}
+	records, err := r.ReadAll()
+	if err != nil {
+		if csvErr, ok := err.(*csv.ParseError); ok {
+			return &ParseError{File: opts.Name, Line: csvErr.Line, Column: csvErr.Column, Err: csvErr.Err}
+		}
+		return err
+	}
+	if len(records) < 2 || len(records[0]) < 2 {
+		return &ParseError{File: opts.Name, Line: 1, Err: fmt.Errorf("expected a header and at least one row")}
+	}
+	header := records[0]
+	// work out the orientation from whether the header holds the sequences
+	samplesAsRows := true
+	for _, id := range header[1:] {
+		if !sequenceRegex.MatchString(strings.TrimSpace(id)) {
+			samplesAsRows = false
+			break
+		}
+	}
+	var sampleNames, featureIDs []string
+	if samplesAsRows {
+		featureIDs = header[1:]
+		for _, record := range records[1:] {
+			sampleNames = append(sampleNames, record[0])
+		}
+	} else {
+		sampleNames = header[1:]
+		for _, record := range records[1:] {
+			featureIDs = append(featureIDs, record[0])
+		}
+	}
+	// get the genus for each sequence
+	genera := make([]string, len(featureIDs))
+	for i, id := range featureIDs {
+		lineage := opts.Taxonomy[strings.TrimSpace(id)]
+		genera[i] = lineage.Get(GENUS)
+		if genera[i] == "" {
+			otuTable.unclassified++
+		} else {
+			otuTable.totalOTUs++
+			otuTable.setDepth(genera[i], lineage.Depth())
+		}
+	}
+	otuTable.sampleNames = make([][]byte, len(sampleNames))
+	otuTable.sampleData = make([]map[string]float64, len(sampleNames))
+	otuTable.topN = make([][]OTU, len(sampleNames))
+	for i, name := range sampleNames {
+		otuTable.sampleNames[i] = []byte(strings.TrimSpace(name))
+		otuTable.sampleData[i] = make(map[string]float64)
+	}
+	for line, record := range records[1:] {
+		for col, field := range record[1:] {
+			sample, feature := line, col
+			if !samplesAsRows {
+				sample, feature = col, line
+			}
+			value, err := parseAbundance(field)
+			if err != nil {
+				return &ParseError{File: opts.Name, Line: line + 2, Column: col + 2, Sample: sampleNames[sample], Err: err}
+			}
+			if genera[feature] == "" {
+				continue
+			}
+			otuTable.sampleData[sample][genera[feature]] += value
+		}
+	}
+	return nil
+}
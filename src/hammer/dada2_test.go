@@ -0,0 +1,42 @@
+package hammer
+
+import (
+	"strings"
+	"testing"
+)
+
+var (
+	testSeqtab  = "\"\",\"ACGTACGT\",\"TTGGCCAA\",\"GGGGAAAA\"\n\"sampleA\",10,5,1\n\"sampleB\",0,20,2\n"
+	testDadaTax = "\"\",\"Kingdom\",\"Phylum\",\"Class\",\"Order\",\"Family\",\"Genus\"\n\"ACGTACGT\",\"Bacteria\",\"Bacteroidetes\",\"Bacteroidia\",\"Bacteroidales\",\"Bacteroidaceae\",\"Bacteroides\"\n\"TTGGCCAA\",\"Bacteria\",\"Bacteroidetes\",\"Bacteroidia\",\"Bacteroidales\",\"Prevotellaceae\",\"Prevotella\"\n\"GGGGAAAA\",\"Bacteria\",\"Firmicutes\",\"Clostridia\",\"Clostridiales\",NA,NA\n"
+)
+
+// test the DADA2 sequence table reader, with both orientations
+func TestDada2Seqtab(t *testing.T) {
+	taxonomy, err := ReadTaxonomy(strings.NewReader(testDadaTax), "taxa.csv", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taxonomy.GetGenus("TTGGCCAA") != "Prevotella" || taxonomy["GGGGAAAA"].Get(PHYLUM) != "Firmicutes" || taxonomy.GetGenus("GGGGAAAA") != "" {
+		t.Fatalf("DADA2 taxonomy not parsed correctly: %v", taxonomy)
+	}
+	transposed := "\tsampleA\tsampleB\nACGTACGT\t10\t0\nTTGGCCAA\t5\t20\nGGGGAAAA\t1\t2\n"
+	for _, data := range []string{testSeqtab, transposed} {
+		table, err := NewOTUTableWithOptions(strings.NewReader(data), TableOptions{Format: "dada2", Taxonomy: taxonomy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if table.GetNumSamples() != 2 || table.GetTotalGenusOTUs() != 2 || table.GetUnclassifiedOTUs() != 1 {
+			t.Fatal("sequence table not parsed correctly")
+		}
+		if data, _ := table.GetSampleData(1); data["Prevotella"] != 20 || len(data) != 2 {
+			t.Fatalf("wrong sample data: %v", data)
+		}
+	}
+	if _, err := NewOTUTableWithOptions(strings.NewReader(testSeqtab), TableOptions{Format: "dada2"}); err == nil {
+		t.Fatal("sequence tables should need a taxonomy")
+	}
+	bad := strings.Replace(testSeqtab, "10,5,1", "10,x,1", 1)
+	if _, err := NewOTUTableWithOptions(strings.NewReader(bad), TableOptions{Format: "dada2", Taxonomy: taxonomy}); err == nil {
+		t.Fatal("invalid abundance should raise an error")
+	}
+}
@@ -0,0 +1,54 @@
+package hammer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// the magic numbers used to detect compressed input
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte{'B', 'Z', 'h'}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// zstdReader wraps the zstd decoder so that it can be closed as an io.ReadCloser
+type zstdReader struct {
+	*zstd.Decoder
+}
+
+// Close is a method to release the resources held by the zstd decoder
+func (zstdReader zstdReader) Close() error {
+	zstdReader.Decoder.Close()
+	return nil
+}
+
+// decompress checks the magic number of the input and transparently decompresses gzip, bzip2 or zstd data
+// uncompressed input is passed through as is; the returned reader should be closed once finished with
+func decompress(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return ioutil.NopCloser(bzip2.NewReader(br)), nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		dec, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return zstdReader{dec}, nil
+	default:
+		return ioutil.NopCloser(br), nil
+	}
+}
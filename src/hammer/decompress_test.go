@@ -0,0 +1,40 @@
+package hammer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// test compressed OTU tables are read transparently
+func TestDecompress(t *testing.T) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// gzip
+	var gz bytes.Buffer
+	gzw := gzip.NewWriter(&gz)
+	gzw.Write(raw)
+	gzw.Close()
+	// zstd
+	var zs bytes.Buffer
+	zsw, err := zstd.NewWriter(&zs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zsw.Write(raw)
+	zsw.Close()
+	for name, data := range map[string][]byte{"plain": raw, "gzip": gz.Bytes(), "zstd": zs.Bytes()} {
+		table, err := NewOTUTableFromReader(bytes.NewReader(data), prog)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if table.GetTotalGenusOTUs() != 4 {
+			t.Fatalf("%v: table not read correctly", name)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package hammer
+
+import (
+	"fmt"
+	"math"
+)
+
+// Filter holds the thresholds used to drop OTUs from a sample before the OTUs are kept for drawing
+// a zero value Filter keeps every OTU with a non-zero abundance
+type Filter struct {
+	MinAbundance         float64 `json:"min_abundance"`          // the minimum abundance of a genus in a sample
+	MinRelativeAbundance float64 `json:"min_relative_abundance"` // the minimum abundance of a genus in a sample, as a fraction of the sample's total abundance
+	MinPrevalence        float64 `json:"min_prevalence"`         // the minimum fraction of samples in the table that a genus must pass the abundance filters in
+}
+
+// check is a method to check the filter thresholds are valid
+func (filter Filter) check() error {
+	for _, value := range []float64{filter.MinAbundance, filter.MinRelativeAbundance, filter.MinPrevalence} {
+		if value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+			return fmt.Errorf("invalid filter threshold: %v", value)
+		}
+	}
+	if filter.MinRelativeAbundance > 1 || filter.MinPrevalence > 1 {
+		return fmt.Errorf("minimum relative abundance and prevalence must be fractions between 0 and 1")
+	}
+	return nil
+}
+
+// SetFilter is a method to set the filter used when the OTUs are kept (by KeepTopN or KeepOrder)
+// the original abundances are not changed, so the filter can be changed and the OTUs kept again
+func (otuTable *OTUTable) SetFilter(filter Filter) error {
+	if err := filter.check(); err != nil {
+		return err
+	}
+	otuTable.filter = filter
+	return nil
+}
+
+// GetFilter is a method to get the filter used when the OTUs are kept
+func (otuTable *OTUTable) GetFilter() Filter {
+	return otuTable.filter
+}
+
+// keep returns true if a genus passes the abundance filters, given its abundance and the total abundance of the sample
+func (filter Filter) keep(abundance, total float64) bool {
+	if abundance == 0 || abundance < filter.MinAbundance {
+		return false
+	}
+	return total <= 0 || abundance/total >= filter.MinRelativeAbundance
+}
+
+// sampleTotal returns the total abundance of a sample
+func sampleTotal(sampleData map[string]float64) float64 {
+	var total float64
+	for _, abundance := range sampleData {
+		total += abundance
+	}
+	return total
+}
+
+// setPrevalence is a method to record the fraction of samples that each genus passes the abundance filters in
+// it must be called before filterSample, as the prevalence filter needs every sample
+func (otuTable *OTUTable) setPrevalence() {
+	counts := make(map[string]int)
+	for _, sampleData := range otuTable.sampleData {
+		total := sampleTotal(sampleData)
+		for genus, abundance := range sampleData {
+			if otuTable.filter.keep(abundance, total) {
+				counts[genus]++
+			}
+		}
+	}
+	otuTable.prevalence = make(map[string]float64, len(counts))
+	for genus, count := range counts {
+		otuTable.prevalence[genus] = float64(count) / float64(len(otuTable.sampleData))
+	}
+}
+
+// filterSample is a method to get a filtered copy of the abundances for a sample
+// only one sample is copied at a time, so a large table isn't held twice while the OTUs are kept
+func (otuTable *OTUTable) filterSample(i int) map[string]float64 {
+	sampleData := otuTable.sampleData[i]
+	total := sampleTotal(sampleData)
+	filtered := make(map[string]float64, len(sampleData))
+	for genus, abundance := range sampleData {
+		if !otuTable.filter.keep(abundance, total) || otuTable.prevalence[genus] < otuTable.filter.MinPrevalence {
+			continue
+		}
+		filtered[genus] = abundance
+	}
+	return filtered
+}
@@ -0,0 +1,53 @@
+package hammer
+
+import (
+	"testing"
+)
+
+// test the abundance and prevalence filters
+func TestFilter(t *testing.T) {
+	table, err := NewOTUTableFromMaps(map[string]map[string]float64{
+		"sampleA": {"Bacteroides": 90, "Prevotella": 8, "Simonsiella": 2},
+		"sampleB": {"Bacteroides": 50, "Prevotella": 50},
+		"sampleC": {"Bacteroides": 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.SetFilter(Filter{MinRelativeAbundance: 2}); err == nil {
+		t.Fatal("relative abundance must be a fraction")
+	}
+	if err := table.SetFilter(Filter{MinAbundance: -1}); err == nil {
+		t.Fatal("negative thresholds should raise an error")
+	}
+	tests := []struct {
+		filter   Filter
+		expected []int // number of non-padding OTUs kept for each sample
+	}{
+		{Filter{}, []int{3, 2, 1}},
+		{Filter{MinAbundance: 5}, []int{2, 2, 0}},
+		{Filter{MinRelativeAbundance: 0.05}, []int{2, 2, 1}},
+		{Filter{MinPrevalence: 0.5}, []int{2, 2, 1}},
+		{Filter{MinAbundance: 5, MinPrevalence: 1}, []int{0, 0, 0}},
+	}
+	for _, test := range tests {
+		if err := table.SetFilter(test.filter); err != nil {
+			t.Fatal(err)
+		}
+		if err := table.KeepTopN(3); err != nil {
+			t.Fatal(err)
+		}
+		for i, expected := range test.expected {
+			topN, _ := table.GetTopN(i)
+			var kept int
+			for _, otu := range topN {
+				if otu.Name != PAD_LINE {
+					kept++
+				}
+			}
+			if kept != expected || len(topN) != 3 {
+				t.Fatalf("filter %+v: sample %d kept %d OTUs, expected %d", test.filter, i, kept, expected)
+			}
+		}
+	}
+}
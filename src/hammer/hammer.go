@@ -3,45 +3,92 @@
 package hammer
 
 import (
-	"bufio"
-	"bytes"
-	"encoding/csv"
 	"fmt"
 	"image/color"
 	"io"
+	"math"
 	"os"
+	"runtime"
 	"sort"
-	"strconv"
-	"strings"
-	"sync"
 
 	"github.com/will-rowe/thor/src/colour"
+	"github.com/will-rowe/thor/src/pool"
 )
 
 const PAD_LINE = "thorPaddingLine"
 
-// otu
-type otu struct {
-	otu       string
-	abundance int
+// COUNT_ABUNDANCE_CAP and RELATIVE_ABUNDANCE_CAP are the abundances that are scaled to the maximum pixel value, for count and relative abundance tables
+const (
+	COUNT_ABUNDANCE_CAP    = 5000.0
+	RELATIVE_ABUNDANCE_CAP = 1.0
+)
+
+// RELATIVE_TOLERANCE is how far the total of a sample can be from 1 for it to hold relative abundances (allowing for rounding)
+const RELATIVE_TOLERANCE = 0.01
+
+// the ways the abundances of a hammer run can be scaled to pixel values
+const (
+	ABUNDANCE_AUTO     = "auto"     // use what the tables hold, which must be the same for every table
+	ABUNDANCE_COUNTS   = "counts"   // scale every table as counts
+	ABUNDANCE_RELATIVE = "relative" // scale every table as relative abundances
+)
+
+// AbundanceTypes are the supported ways of scaling abundances
+var AbundanceTypes = []string{ABUNDANCE_AUTO, ABUNDANCE_COUNTS, ABUNDANCE_RELATIVE}
+
+// ParseError records where an OTU table could not be parsed
+type ParseError struct {
+	File   string
+	Line   int
+	Column int
+	Sample string
+	Err    error
 }
 
-// otuTable
-type otuTable struct {
+// Error is a method to satisfy the error interface, reporting as much of the position as is known
+func (parseError *ParseError) Error() string {
+	msg := fmt.Sprintf("%v: line %d", parseError.File, parseError.Line)
+	if parseError.Column > 0 {
+		msg = fmt.Sprintf("%v, column %d", msg, parseError.Column)
+	}
+	if parseError.Sample != "" {
+		msg = fmt.Sprintf("%v (sample %v)", msg, parseError.Sample)
+	}
+	return fmt.Sprintf("%v: %v", msg, parseError.Err)
+}
+
+// OTU is an OTU (collapsed to genus level) and its abundance in a sample
+type OTU struct {
+	Name      string
+	Abundance float64
+}
+
+// OTUTable holds the per-sample genus level abundances from an OTU table, along with the top N OTUs for each sample
+type OTUTable struct {
 	program  string
-	path     string
 	comments [][]byte
 	// the ordering of the outside slice of sampleNames, sampleData and topN are used to relate the data
-	sampleNames [][]byte
-	sampleData  []map[string]int
-	topN        [][]otu
-	totalOTUs   int
+	sampleNames  [][]byte
+	sampleData   []map[string]float64
+	topN         [][]OTU
+	totalOTUs    int
+	unclassified int
+	// relative is true if the table holds relative abundances rather than counts, which sets the abundance cap
+	relative bool
+	// the filter used when the OTUs are kept
+	filter Filter
+	// the deepest rank that the OTUs of each genus were classified to
+	depth map[string]Rank
+	// the number of go routines used to keep the top N OTUs
+	workers int
+	// the fraction of samples containing each genus, after filtering (set when the OTUs are kept)
+	prevalence map[string]float64
 	// the COLOURSKETCH map
 	ColourSketchStore colour.ColourSketchStore
 }
 
 // PrintComments returns the OTU table comments, formatted as a single string with newlines
-func (otuTable *otuTable) PrintComments() string {
+func (otuTable *OTUTable) PrintComments() string {
 	var comments string
 	for i := 0; i < len(otuTable.comments); i++ {
 		// comments where stored in order they were read (newlines were also kept)
@@ -51,188 +98,306 @@ func (otuTable *otuTable) PrintComments() string {
 }
 
 // GetNumSamples returns the number of samples found in the OTU table
-func (otuTable *otuTable) GetNumSamples() int {
+func (otuTable *OTUTable) GetNumSamples() int {
 	return len(otuTable.sampleNames)
 }
 
 // GetSampleName returns the string formatted sample name, give the index position
-func (otuTable *otuTable) GetSampleName(i int) (string, error) {
-	if i > len(otuTable.sampleNames) {
-		return "", fmt.Errorf("sample index position > number of samples!")
+func (otuTable *OTUTable) GetSampleName(i int) (string, error) {
+	if err := otuTable.checkIndex(i); err != nil {
+		return "", err
 	}
 	return string(otuTable.sampleNames[i]), nil
 }
 
+// GetSampleNames returns all the sample names, in the order they appear in the OTU table
+func (otuTable *OTUTable) GetSampleNames() []string {
+	names := make([]string, len(otuTable.sampleNames))
+	for i, name := range otuTable.sampleNames {
+		names[i] = string(name)
+	}
+	return names
+}
+
+// GetSampleIndex returns the index position of a sample, given the sample name
+func (otuTable *OTUTable) GetSampleIndex(name string) (int, error) {
+	for i, sample := range otuTable.sampleNames {
+		if string(sample) == name {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("sample not found in OTU table: %v", name)
+}
+
+// GetSampleData returns a copy of the genus level abundances for a sample, given the index position
+func (otuTable *OTUTable) GetSampleData(i int) (map[string]float64, error) {
+	if err := otuTable.checkIndex(i); err != nil {
+		return nil, err
+	}
+	data := make(map[string]float64, len(otuTable.sampleData[i]))
+	for genus, abundance := range otuTable.sampleData[i] {
+		data[genus] = abundance
+	}
+	return data, nil
+}
+
+// GetTopN returns a copy of the top N OTUs for a sample (in order of decreasing abundance), given the index position
+// the slice will be empty if KeepTopN has not been run
+func (otuTable *OTUTable) GetTopN(i int) ([]OTU, error) {
+	if err := otuTable.checkIndex(i); err != nil {
+		return nil, err
+	}
+	topN := make([]OTU, len(otuTable.topN[i]))
+	copy(topN, otuTable.topN[i])
+	return topN, nil
+}
+
+// ForEachSample is a method to call a function for each sample in the OTU table (in order), passing the sample index, name and top N OTUs
+// iteration stops at the first error returned by the function
+func (otuTable *OTUTable) ForEachSample(fn func(i int, sample string, topN []OTU) error) error {
+	for i := range otuTable.sampleNames {
+		topN, err := otuTable.GetTopN(i)
+		if err != nil {
+			return err
+		}
+		if err := fn(i, string(otuTable.sampleNames[i]), topN); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkIndex is a method to check a sample index position is in range
+func (otuTable *OTUTable) checkIndex(i int) error {
+	if i < 0 || i >= len(otuTable.sampleNames) {
+		return fmt.Errorf("sample index position out of range: %d (number of samples: %d)", i, len(otuTable.sampleNames))
+	}
+	return nil
+}
+
 // GetTotalGenusOTUs returns the total number of genus level OTUs in the original OTU table file
-func (otuTable *otuTable) GetTotalGenusOTUs() int {
+func (otuTable *OTUTable) GetTotalGenusOTUs() int {
 	return otuTable.totalOTUs
 }
 
-// KeepTopN is a method to keep only the top N most abundant OTUs in each sample
-// it clears the original sampleData and keeps the topN in a set of new slices
-func (otuTable *otuTable) KeepTopN(n int) error {
-	// make sure this method hasn't already been run
-	if len(otuTable.topN[0]) != 0 {
-		return fmt.Errorf("the KeepTopN method has already been run on this OTU table")
+// GetUnclassifiedOTUs returns the number of OTUs in the original OTU table file that could not be assigned a genus
+func (otuTable *OTUTable) GetUnclassifiedOTUs() int {
+	return otuTable.unclassified
+}
+
+// IsRelative returns true if the OTU table holds relative abundances rather than counts
+// this is a property of the format (e.g. MetaPhlAn profiles are relative, mothur shared files are counts),
+// or for formats that can hold either, true if every sample totals 1
+func (otuTable *OTUTable) IsRelative() bool {
+	return otuTable.relative
+}
+
+// SetRelative is a method to set whether the OTU table is scaled as relative abundances or as counts
+// this overrides what was found when the table was read, so that every table in a run can be scaled the same way
+func (otuTable *OTUTable) SetRelative(relative bool) {
+	otuTable.relative = relative
+}
+
+// KeepTopN is a method to keep only the top N most abundant OTUs in each sample, after applying the filter
+// if a sample has fewer than N OTUs, the remaining rows are marked as padding
+// the original sampleData is not changed, so KeepTopN can be run again (e.g. with a different N or filter)
+func (otuTable *OTUTable) KeepTopN(n int) error {
+	if n < 1 {
+		return fmt.Errorf("the number of top OTUs to keep must be at least 1")
 	}
-	// make sure n > num OTUs in table
-	if n > otuTable.GetTotalGenusOTUs() {
-		return fmt.Errorf("requested number of top OTUs is greater than the total number of OTUs")
+	otuTable.setPrevalence()
+	// filter and sort the samples using a bounded number of go routines, then update the top n otus
+	workers := otuTable.workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
 	}
-	// sort each sample in a separate go routine and then update the top n otus
-	var wg sync.WaitGroup
-	for i := 0; i < len(otuTable.sampleData); i++ {
-		wg.Add(1)
-		go sortOTUs(otuTable, i, n, &wg)
+	failures := pool.NewPool(workers).Run(len(otuTable.sampleData), func(i int) error {
+		otuTable.topN[i] = sortOTUs(otuTable.filterSample(i), n)
+		return nil
+	})
+	if len(failures) != 0 {
+		return failures[0].Err
 	}
-	wg.Wait()
 	return nil
 }
 
+// GetDepth returns the deepest rank that the OTUs of a genus were classified to in the table
+// genus is returned if this isn't known (e.g. for tables made from maps)
+func (otuTable *OTUTable) GetDepth(genus string) Rank {
+	if depth, ok := otuTable.depth[genus]; ok {
+		return depth
+	}
+	return GENUS
+}
+
+// setDepth is a method to record the rank an OTU of a genus was classified to, keeping the deepest rank for each genus
+func (otuTable *OTUTable) setDepth(genus string, depth Rank) {
+	if otuTable.depth == nil {
+		otuTable.depth = make(map[string]Rank)
+	}
+	if prev, ok := otuTable.depth[genus]; !ok || depth > prev {
+		otuTable.depth[genus] = depth
+	}
+}
+
+// SetWorkers is a method to set the number of go routines used to keep the top N OTUs (the number of CPUs if < 1)
+func (otuTable *OTUTable) SetWorkers(workers int) {
+	otuTable.workers = workers
+}
+
 // ColourTopN returns the corresponding coloursketches for the TopN otus
 // returns the sample ID, the slice of coloursketches and any error
-func (otuTable *otuTable) ColourTopN(colourStore colour.ColourSketchStore, pad bool) ([][][]color.RGBA, error) {
+func (otuTable *OTUTable) ColourTopN(colourStore colour.ColourSketchStore, pad bool) ([][][]color.RGBA, error) {
 	// attach the colour store
 	otuTable.ColourSketchStore = colourStore
 	// make the image template
 	rgbaLines := make([][][]color.RGBA, otuTable.GetNumSamples())
 	// perform for each sample in the OTU table
 	for i := range otuTable.sampleNames {
-		rgbaLines[i] = make([][]color.RGBA, len(otuTable.topN[i]))
-		// for each sample, range over the topN otus
-		for j, otu := range otuTable.topN[i] {
-			// if OTU is has 0 abundance, add row of padding pixels if requested, else skip this OTU
-			if otu.otu == PAD_LINE && !pad {
-				continue
-			}
-			// lookup the otu in the css
-			if cs, ok := otuTable.ColourSketchStore[otu.otu]; !ok {
-				// TODO: if the topN OTUs are not present in the REFSEQ db, this error will be raised - need to work on handling this event
-				continue
-				//return nil, fmt.Errorf("sample %v: the genus name `%v` (abundance: %d) could not be found in the coloursketches", string(otuTable.sampleNames[i]), otu.otu, otu.abundance)
-			} else {
-				// make a copy of the colour sketch
-				csCopy := cs.CopySketch()
-				// adjust the colour sketch so that the B slot corresponds to the OTU abundance
-				// first scale the abundance value to fit the uint8 slot
-				// TODO: set a customisable cap for abundance values
-				abunCap := 5000
-				var abunVal float32
-				if otu.abundance > abunCap {
-					abunVal = 255
-				} else {
-					abunVal = (float32(otu.abundance) / float32(abunCap)) * 255
-				}
-				// adjust the B slot
-				if err := csCopy.Adjust('B', uint8(abunVal)); err != nil {
-					return nil, err
-				}
-				// adjust the A slot so that it is set to visible
-				if err := csCopy.Adjust('A', 255); err != nil {
-					return nil, err
-				}
-				if rgba, err := csCopy.PrintPNGline(); err != nil {
-					return nil, err
-				} else {
-					rgbaLines[i][j] = rgba
-				}
-			}
+		lines, err := otuTable.ColourSample(i, colourStore, pad)
+		if err != nil {
+			return nil, err
 		}
+		rgbaLines[i] = lines
 	}
 	return rgbaLines, nil
 }
 
-// readQiimeTable will load a qiime file into the otuTable
-func (otuTable *otuTable) readQiimeTable() error {
-	// create a new reader
-	fh, err := os.Open(otuTable.path)
-	if err != nil {
-		return err
+// ColourSample returns the corresponding coloursketches for the TopN otus of a single sample
+// it does not modify the otuTable, so it can be called concurrently for different samples
+func (otuTable *OTUTable) ColourSample(i int, colourStore colour.ColourSketchStore, pad bool) ([][]color.RGBA, error) {
+	if err := otuTable.checkIndex(i); err != nil {
+		return nil, err
 	}
-	defer fh.Close()
-	r := bufio.NewReader(fh)
-	// slurp off the comments
-	for {
-		char, err := r.Peek(1)
-		if err != nil {
-			return err
+	rgbaLines := make([][]color.RGBA, len(otuTable.topN[i]))
+	// range over the topN otus
+	for j, otu := range otuTable.topN[i] {
+		// if OTU is has 0 abundance, add row of padding pixels if requested, else skip this OTU
+		if otu.Name == PAD_LINE && !pad {
+			continue
 		}
-		if char[0] == 35 {
-			// either a comment or the header line
-			line, err := r.ReadBytes('\n')
-			if err != nil {
-				return err
+		// lookup the otu in the css
+		if cs, ok := colourStore[otu.Name]; !ok {
+			// TODO: if the topN OTUs are not present in the REFSEQ db, this error will be raised - need to work on handling this event
+			continue
+			//return nil, fmt.Errorf("sample %v: the genus name `%v` (abundance: %v) could not be found in the coloursketches", string(otuTable.sampleNames[i]), otu.Name, otu.Abundance)
+		} else {
+			// make a copy of the colour sketch
+			csCopy := cs.CopySketch()
+			// adjust the colour sketch so that the B slot corresponds to the OTU abundance
+			// first scale the abundance value to fit the uint8 slot
+			// TODO: set a customisable cap for abundance values
+			abunVal := otuTable.scaleAbundance(otu.Abundance) * 255
+			// adjust the B slot
+			if err := csCopy.Adjust('B', uint8(abunVal)); err != nil {
+				return nil, err
+			}
+			// adjust the A slot so that it is set to visible
+			if err := csCopy.Adjust('A', 255); err != nil {
+				return nil, err
 			}
-			// add the comment and keep peeking
-			if string(line[0:4]) != "#OTU" {
-				otuTable.comments = append(otuTable.comments, line)
-				// or finish the slurping, add the samples from the header and start reading lines
+			if rgba, err := csCopy.PrintPNGline(); err != nil {
+				return nil, err
 			} else {
-				samples := bytes.Split(line, []byte("	"))
-				numSamples := (len(samples) - 2)
-				samples = samples[1 : 1+numSamples]
-				otuTable.sampleNames = make([][]byte, numSamples)
-				otuTable.sampleData = make([]map[string]int, numSamples)
-				otuTable.topN = make([][]otu, numSamples)
-				for i, sample := range samples {
-					otuTable.sampleNames[i] = sample
-					otuTable.sampleData[i] = make(map[string]int)
-				}
-				break
+				rgbaLines[j] = rgba
 			}
-		} else {
-			return fmt.Errorf("no comment or header lines found in Qiime OTU table")
 		}
 	}
-	var counter int
-	tsvReader := csv.NewReader(r)
-	tsvReader.Comma = '	'
-	for {
-		line, err := tsvReader.Read()
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			return err
-		}
-		// grab the consensus lineage, check for genus and keep it
-		consensusLineage := strings.Split(line[len(line)-1], ";g__")
-		if len(consensusLineage) != 2 {
+	return rgbaLines, nil
+}
+
+// ColourSample64 returns the 16-bit colour sketches for each of the kept OTUs in a sample, for drawing a 16 bits per channel PNG
+// the R slot holds the sketch value and the B slot holds the abundance, scaled to 65536 levels
+// like ColourSample, a line is nil if the genus is not in the colour sketches, or if it is padding and padding is not requested
+func (otuTable *OTUTable) ColourSample64(i int, colourStore colour.ColourSketchStore, pad bool) ([][]color.NRGBA64, error) {
+	if err := otuTable.checkIndex(i); err != nil {
+		return nil, err
+	}
+	lines := make([][]color.NRGBA64, len(otuTable.topN[i]))
+	for j, otu := range otuTable.topN[i] {
+		if otu.Name == PAD_LINE && !pad {
 			continue
 		}
-		if consensusLineage[1] == "" {
+		cs, ok := colourStore[otu.Name]
+		if !ok {
 			continue
 		}
-		// add the abundance values to the corresponding samples
-		for i := 1; i <= len(otuTable.sampleData); i++ {
-			value, err := strconv.Atoi(line[i])
-			if err != nil {
-				return err
-			}
-			if _, ok := otuTable.sampleData[i-1][consensusLineage[1]]; !ok {
-				otuTable.sampleData[i-1][consensusLineage[1]] = value
-			} else {
-				otuTable.sampleData[i-1][consensusLineage[1]] += value
-			}
+		line, err := cs.PrintPNGline64(uint16(otuTable.scaleAbundance(otu.Abundance) * math.MaxUint16))
+		if err != nil {
+			return nil, err
 		}
-		counter++
+		lines[j] = line
 	}
-	otuTable.totalOTUs = counter
-	return nil
+	return lines, nil
+}
+
+// TableOptions holds the options used when reading an OTU table
+type TableOptions struct {
+	Format   string   // the OTU table format
+	Name     string   // the name of the table (usually the file path), used when reporting errors
+	Taxonomy Taxonomy // assigns a genus to each OTU id, required if the table doesn't have a taxonomy column
+	Label    string   // the distance label to use from a mothur shared file (defaults to the first label)
+	Rank     string   // the rank to use from a taxonomic profile (defaults to genus)
+}
+
+// NewOTUtable is the OTUTable constructor, which reads an OTU table file (which can be gzip, bzip2 or zstd compressed)
+func NewOTUtable(path, prog string) (*OTUTable, error) {
+	return ReadOTUTable(path, TableOptions{Format: prog})
 }
 
-// NewOTUtable is the otuTable constructor
-func NewOTUtable(path, prog string) (*otuTable, error) {
-	table := &otuTable{
-		path:    path,
-		program: prog,
+// NewOTUTableFromReader is an OTUTable constructor, which reads an OTU table from an io.Reader
+// gzip, bzip2 and zstd compressed input is detected and decompressed transparently
+func NewOTUTableFromReader(r io.Reader, prog string) (*OTUTable, error) {
+	return NewOTUTableWithOptions(r, TableOptions{Format: prog})
+}
+
+// ReadOTUTable is an OTUTable constructor, which reads an OTU table file using the supplied options
+func ReadOTUTable(path string, opts TableOptions) (*OTUTable, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
 	}
-	// read in the file
-	var err error
+	defer fh.Close()
+	if opts.Name == "" {
+		opts.Name = path
+	}
+	return NewOTUTableWithOptions(fh, opts)
+}
+
+// NewOTUTableWithOptions is an OTUTable constructor, which reads an OTU table from an io.Reader using the supplied options
+// gzip, bzip2 and zstd compressed input is detected and decompressed transparently
+func NewOTUTableWithOptions(r io.Reader, opts TableOptions) (*OTUTable, error) {
+	if opts.Name == "" {
+		opts.Name = "<reader>"
+	}
+	table := &OTUTable{
+		program: opts.Format,
+	}
+	dr, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	defer dr.Close()
+	// read in the table
 	switch table.program {
 	case "qiime":
-		err = table.readQiimeTable()
+		err = table.readQiimeTable(dr, opts)
+	case "mothur":
+		err = table.readMothurShared(dr, opts)
+	case "kraken2":
+		err = table.readKrakenReport(dr, opts)
+	case "bracken":
+		err = table.readBracken(dr, opts)
+	case "metaphlan":
+		err = table.readMetaPhlAn(dr, opts)
+	case "dada2":
+		err = table.readDada2Seqtab(dr, opts)
+	case "biom":
+		err = table.readBiom(dr, opts)
+	case "qza":
+		err = table.readQZA(dr, opts)
 	default:
-		err = fmt.Errorf("unsupported OTU table format: %v", prog)
+		err = fmt.Errorf("unsupported OTU table format: %v", opts.Format)
 	}
 	if err != nil {
 		return nil, err
@@ -240,27 +405,79 @@ func NewOTUtable(path, prog string) (*otuTable, error) {
 	return table, nil
 }
 
-// sortOTUs is a function to sort the OTUs by decreasing abundance, keeping only the top N
-func sortOTUs(otuTable *otuTable, sampleID, n int, wg *sync.WaitGroup) {
-	defer wg.Done()
-	var topNotus []otu
+// NewOTUTableFromMaps is an OTUTable constructor, which builds an OTU table from in-memory data
+// samples maps each sample name to its genus level abundances; samples are ordered by name
+func NewOTUTableFromMaps(samples map[string]map[string]float64) (*OTUTable, error) {
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no samples supplied")
+	}
+	names := make([]string, 0, len(samples))
+	for name := range samples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	table := &OTUTable{
+		program:     "map",
+		sampleNames: make([][]byte, len(names)),
+		sampleData:  make([]map[string]float64, len(names)),
+		topN:        make([][]OTU, len(names)),
+	}
+	totals := make([]float64, len(names))
+	genera := make(map[string]struct{})
+	for i, name := range names {
+		table.sampleNames[i] = []byte(name)
+		table.sampleData[i] = make(map[string]float64, len(samples[name]))
+		for genus, abundance := range samples[name] {
+			if abundance < 0 || math.IsNaN(abundance) || math.IsInf(abundance, 0) {
+				return nil, fmt.Errorf("sample %v: invalid abundance for %v (%v)", name, genus, abundance)
+			}
+			totals[i] += abundance
+			table.sampleData[i][genus] = abundance
+			genera[genus] = struct{}{}
+		}
+	}
+	table.totalOTUs = len(genera)
+	table.relative = isRelative(totals)
+	return table, nil
+}
+
+// sortOTUs is a function to sort the OTUs by decreasing abundance (then by name), keeping only the top N
+// if there are fewer than N OTUs, padding OTUs are added
+func sortOTUs(sampleData map[string]float64, n int) []OTU {
+	topNotus := make([]OTU, 0, len(sampleData))
 	// put the otus into a slice
-	for k, v := range otuTable.sampleData[sampleID] {
-		topNotus = append(topNotus, otu{k, v})
+	for k, v := range sampleData {
+		topNotus = append(topNotus, OTU{k, v})
 	}
-	// sort
-	sort.Slice(topNotus, func(i, j int) bool {
-		return topNotus[i].abundance > topNotus[j].abundance
+	// sort by decreasing abundance, breaking ties by name so that the order doesn't depend on the map iteration order
+	sort.SliceStable(topNotus, func(i, j int) bool {
+		if topNotus[i].Abundance != topNotus[j].Abundance {
+			return topNotus[i].Abundance > topNotus[j].Abundance
+		}
+		return topNotus[i].Name < topNotus[j].Name
 	})
-	// update the OTUtable with the top n otus
-	otuTable.topN[sampleID] = topNotus[0:n]
-	// remove the full map for this sample
-	otuTable.sampleData[sampleID] = make(map[string]int)
-	// update any 0 abundance included in the topN to be marked as padding
-	for i := 0; i < n; i++ {
-		if otuTable.topN[sampleID][i].abundance == 0 {
-			otuTable.topN[sampleID][i].otu = PAD_LINE
+	// keep the top n otus, padding if there aren't enough
+	if len(topNotus) > n {
+		topNotus = topNotus[0:n]
+	}
+	for len(topNotus) < n {
+		topNotus = append(topNotus, OTU{PAD_LINE, 0})
+	}
+	return topNotus
+}
+
+// isRelative returns true if a table holds relative abundances, given the total of each sample (including unclassified OTUs)
+// every sample with any abundance must total 1, so sparse count and presence/absence tables are treated as counts
+func isRelative(totals []float64) bool {
+	var found bool
+	for _, total := range totals {
+		if total == 0 {
+			continue
+		}
+		if math.Abs(total-1) > RELATIVE_TOLERANCE {
+			return false
 		}
+		found = true
 	}
-	return
+	return found
 }
@@ -1,7 +1,10 @@
 package hammer
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/will-rowe/thor/src/colour"
 )
 
 var (
@@ -29,13 +32,137 @@ func TestTopN(t *testing.T) {
 	if err := table.KeepTopN(3); err != nil {
 		t.Fatal(err)
 	}
-	// make sure topN can't be called again
-	if err := table.KeepTopN(3); err == nil {
-		t.Fatal("at present, we only want to be able to call KeepTopN once")
+	// make sure topN can be run again without losing the data
+	if err := table.KeepTopN(2); err != nil {
+		t.Fatal(err)
+	}
+	if topN, _ := table.GetTopN(0); len(topN) != 2 || topN[0].Name != "Propionibacterium" {
+		t.Fatalf("KeepTopN not re-run correctly: %v", topN)
+	}
+	if data, _ := table.GetSampleData(0); len(data) != 4 {
+		t.Fatal("KeepTopN should not change the sample data")
+	}
+	// make sure n > num OTUs in table is padded
+	if err := table.KeepTopN(6); err != nil {
+		t.Fatal(err)
+	}
+	topN, _ := table.GetTopN(0)
+	if len(topN) != 6 || topN[2].Name != "Bacteroides" || topN[3].Name != PAD_LINE || topN[5].Name != PAD_LINE {
+		t.Fatalf("top OTUs not padded: %v", topN)
+	}
+	if err := table.KeepTopN(0); err == nil {
+		t.Fatal("n must be > 0")
+	}
+}
+
+// test the ColourSample method
+func TestColourSample(t *testing.T) {
+	table, _ := NewOTUtable(path, prog)
+	if err := table.KeepTopN(3); err != nil {
+		t.Fatal(err)
+	}
+	css := make(colour.ColourSketchStore)
+	css["Bacteroides"] = colour.NewColourSketch([]uint32{1, 2, 3}, "Bacteroides")
+	lines, err := table.ColourSample(0, css, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found int
+	for _, line := range lines {
+		if line != nil {
+			found++
+		}
+	}
+	if found != 1 {
+		t.Fatalf("expected 1 coloured OTU, got %d", found)
+	}
+	if _, err := table.ColourSample(1, css, false); err == nil {
+		t.Fatal("sample index out of range should raise an error")
 	}
-	// make sure the numOTUs check works
-	table2, _ := NewOTUtable(path, prog)
-	if err := table2.KeepTopN(5); err == nil {
-		t.Fatal("n must be < len(otu table)")
+}
+
+// test the ColourSample64 method
+func TestColourSample64(t *testing.T) {
+	table, err := NewOTUTableFromMaps(map[string]map[string]float64{"s1": {"Bacteroides": 2500, "Streptococcus": 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.KeepTopN(3); err != nil {
+		t.Fatal(err)
+	}
+	css := make(colour.ColourSketchStore)
+	css["Bacteroides"] = colour.NewColourSketch([]uint32{1, 258, 65535}, "Bacteroides")
+	lines, err := table.ColourSample64(0, css, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 3 || lines[0] == nil || lines[1] != nil || lines[2] != nil {
+		t.Fatalf("unexpected lines: %v", lines)
+	}
+	if lines[0][1].R != 258 || lines[0][2].R != 65535 || lines[0][0].B != 32767 {
+		t.Fatalf("incorrect 16-bit colours: %v", lines[0])
+	}
+	if _, err := table.ColourSample64(1, css, false); err == nil {
+		t.Fatal("sample index out of range should raise an error")
+	}
+}
+
+// test the OTUTable can be built from in-memory data and inspected
+func TestFromMaps(t *testing.T) {
+	table, err := NewOTUTableFromMaps(map[string]map[string]float64{
+		"sampleB": {"Bacteroides": 5, "Prevotella": 50},
+		"sampleA": {"Bacteroides": 20, "Prevotella": 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := table.GetSampleNames(); len(names) != 2 || names[0] != "sampleA" {
+		t.Fatal("samples should be ordered by name")
+	}
+	if i, err := table.GetSampleIndex("sampleB"); err != nil || i != 1 {
+		t.Fatal("could not get sample index")
+	}
+	if err := table.KeepTopN(1); err != nil {
+		t.Fatal(err)
+	}
+	var top []string
+	err = table.ForEachSample(func(i int, sample string, topN []OTU) error {
+		top = append(top, topN[0].Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if top[0] != "Bacteroides" || top[1] != "Prevotella" {
+		t.Fatalf("wrong top OTUs: %v", top)
+	}
+	if _, err := table.GetTopN(2); err == nil {
+		t.Fatal("sample index out of range should raise an error")
+	}
+	if _, err := NewOTUTableFromMaps(nil); err == nil {
+		t.Fatal("empty maps should raise an error")
+	}
+}
+
+// test that OTUs with equal abundance are always kept in the same order
+func TestTopNTies(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		table, err := NewOTUTableFromMaps(map[string]map[string]float64{
+			"sampleA": {"Prevotella": 5, "Bacteroides": 5, "Simonsiella": 5, "Alistipes": 10, "Dialister": 5},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := table.KeepTopN(4); err != nil {
+			t.Fatal(err)
+		}
+		topN, _ := table.GetTopN(0)
+		var names []string
+		for _, otu := range topN {
+			names = append(names, otu.Name)
+		}
+		if strings.Join(names, ",") != "Alistipes,Bacteroides,Dialister,Prevotella" {
+			t.Fatalf("ties not broken by name: %v", names)
+		}
 	}
 }
@@ -0,0 +1,89 @@
+package hammer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// readMothurShared will load a mothur shared file into the otuTable
+// shared files have a row per sample (label, Group, numOtus, then one column per OTU) and can hold several distance labels,
+// only the rows for opts.Label (or the first label in the file) are used, and the OTU genera come from opts.Taxonomy (e.g. a .cons.taxonomy file)
+// the values of a shared file are read counts
+func (otuTable *OTUTable) readMothurShared(fh io.Reader, opts TableOptions) error {
+	if opts.Taxonomy == nil {
+		return fmt.Errorf("mothur shared files need a taxonomy file (e.g. a .cons.taxonomy)")
+	}
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	var lineNum int
+	var header []string
+	var genera []string
+	label := opts.Label
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		// the first line is the header, which holds the OTU ids
+		if header == nil {
+			if len(fields) < 4 || !strings.EqualFold(fields[0], "label") || !strings.EqualFold(fields[1], "group") || !strings.EqualFold(fields[2], "numotus") {
+				return &ParseError{File: opts.Name, Line: lineNum, Err: fmt.Errorf("expected a mothur shared header (label, Group, numOtus, OTU ids)")}
+			}
+			header = fields
+			genera = make([]string, len(fields)-3)
+			for i, id := range fields[3:] {
+				lineage := opts.Taxonomy[strings.TrimSpace(id)]
+				genera[i] = lineage.Get(GENUS)
+				if genera[i] == "" {
+					otuTable.unclassified++
+				} else {
+					otuTable.totalOTUs++
+					otuTable.setDepth(genera[i], lineage.Depth())
+				}
+			}
+			continue
+		}
+		if len(fields) != len(header) {
+			return &ParseError{File: opts.Name, Line: lineNum, Err: fmt.Errorf("expected %d fields, found %d", len(header), len(fields))}
+		}
+		// use the first label if one wasn't requested, and skip the other labels
+		if label == "" {
+			label = fields[0]
+		}
+		if fields[0] != label {
+			continue
+		}
+		sample := strings.TrimSpace(fields[1])
+		if fields[2] != fmt.Sprintf("%d", len(genera)) {
+			return &ParseError{File: opts.Name, Line: lineNum, Column: 3, Sample: sample, Err: fmt.Errorf("numOtus (%v) does not match the number of OTU columns (%d)", fields[2], len(genera))}
+		}
+		sampleData := make(map[string]float64)
+		for i, genus := range genera {
+			value, err := parseAbundance(fields[i+3])
+			if err != nil {
+				return &ParseError{File: opts.Name, Line: lineNum, Column: i + 4, Sample: sample, Err: err}
+			}
+			if genus == "" {
+				continue
+			}
+			sampleData[genus] += value
+		}
+		otuTable.sampleNames = append(otuTable.sampleNames, []byte(sample))
+		otuTable.sampleData = append(otuTable.sampleData, sampleData)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if header == nil {
+		return &ParseError{File: opts.Name, Line: lineNum, Err: fmt.Errorf("no header found in mothur shared file")}
+	}
+	if len(otuTable.sampleNames) == 0 {
+		return &ParseError{File: opts.Name, Line: lineNum, Err: fmt.Errorf("no samples found for label: %v", label)}
+	}
+	otuTable.topN = make([][]OTU, len(otuTable.sampleNames))
+	return nil
+}
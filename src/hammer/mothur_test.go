@@ -0,0 +1,48 @@
+package hammer
+
+import (
+	"strings"
+	"testing"
+)
+
+var (
+	testShared   = "label\tGroup\tnumOtus\tOtu001\tOtu002\tOtu003\n0.03\tsampleA\t3\t10\t5\t1\n0.03\tsampleB\t3\t0\t20\t2\n0.05\tsampleA\t3\t15\t1\t0\n"
+	testConsTaxa = "OTU\tSize\tTaxonomy\nOtu001\t25\tBacteria(100);Bacteroidetes(100);Bacteroidia(100);Bacteroidales(100);Bacteroidaceae(100);Bacteroides(100);\nOtu002\t26\tBacteria(100);Bacteroidetes(100);Bacteroidia(100);Bacteroidales(100);Prevotellaceae(100);Prevotella(98);\nOtu003\t3\tBacteria(100);Firmicutes(100);Clostridia(100);Clostridiales(100);Lachnospiraceae(100);Lachnospiraceae_unclassified(100);\n"
+)
+
+// test the mothur shared reader
+func TestMothurShared(t *testing.T) {
+	taxonomy, err := ReadTaxonomy(strings.NewReader(testConsTaxa), "test.cons.taxonomy", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewOTUTableWithOptions(strings.NewReader(testShared), TableOptions{Format: "mothur"}); err == nil {
+		t.Fatal("shared files should need a taxonomy")
+	}
+	table, err := NewOTUTableWithOptions(strings.NewReader(testShared), TableOptions{Format: "mothur", Taxonomy: taxonomy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.GetNumSamples() != 2 || table.GetTotalGenusOTUs() != 2 || table.GetUnclassifiedOTUs() != 1 {
+		t.Fatal("shared file not parsed correctly")
+	}
+	data, _ := table.GetSampleData(1)
+	if data["Prevotella"] != 20 || len(data) != 2 {
+		t.Fatalf("wrong sample data: %v", data)
+	}
+	// choose a different label
+	table, err = NewOTUTableWithOptions(strings.NewReader(testShared), TableOptions{Format: "mothur", Taxonomy: taxonomy, Label: "0.05"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := table.GetSampleData(0); table.GetNumSamples() != 1 || data["Bacteroides"] != 15 {
+		t.Fatal("label not used")
+	}
+	if _, err := NewOTUTableWithOptions(strings.NewReader(testShared), TableOptions{Format: "mothur", Taxonomy: taxonomy, Label: "unique"}); err == nil {
+		t.Fatal("missing label should raise an error")
+	}
+	bad := strings.Replace(testShared, "sampleB\t3", "sampleB\t4", 1)
+	if _, err := NewOTUTableWithOptions(strings.NewReader(bad), TableOptions{Format: "mothur", Taxonomy: taxonomy}); err == nil {
+		t.Fatal("wrong numOtus should raise an error")
+	}
+}
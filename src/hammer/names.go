@@ -0,0 +1,164 @@
+package hammer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// candidatusPrefix matches the Candidatus (or Ca.) qualifier used for uncultured taxa
+var candidatusPrefix = regexp.MustCompile(`(?i)^(candidatus|ca\.)\s+`)
+
+// gtdbSuffix matches the alphabetic suffixes GTDB uses to split polyphyletic genera (e.g. Prevotella_A)
+var gtdbSuffix = regexp.MustCompile(`_[A-Z]+$`)
+
+// CanonicalName returns a canonical form of a taxon name, so that names from different taxonomies can be compared
+// the rank prefix, brackets, quotes, Candidatus qualifier and GTDB suffix are removed, underscores and runs of
+// whitespace become a single space, and the name is lower cased (e.g. `g__[Prevotella] ` and `Prevotella_A` become `prevotella`)
+func CanonicalName(name string) string {
+	name = strings.TrimSpace(name)
+	if _, trimmed, ok := splitRankPrefix(name); ok {
+		name = trimmed
+	}
+	name = strings.NewReplacer("[", "", "]", "", "'", "", "\"", "").Replace(name)
+	name = gtdbSuffix.ReplaceAllString(strings.TrimSpace(name), "")
+	name = strings.Replace(name, "_", " ", -1)
+	name = candidatusPrefix.ReplaceAllString(strings.TrimSpace(name), "")
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// NameMatcher matches the genus names in OTU tables to the names used by a reference set (e.g. the colour sketch store keys)
+// names are matched on their canonical form, after applying any synonyms and, if a taxdump is loaded, on their NCBI taxid
+type NameMatcher struct {
+	synonyms  map[string]string // canonical synonym -> canonical accepted name
+	taxdump   *Taxdump          // optional NCBI taxonomy, used to match names via their taxid
+	reference map[string]string // key -> reference name
+	exact     map[string]bool   // the reference names
+}
+
+// NewNameMatcher is the NameMatcher constructor
+func NewNameMatcher() *NameMatcher {
+	return &NameMatcher{
+		synonyms:  make(map[string]string),
+		reference: make(map[string]string),
+		exact:     make(map[string]bool),
+	}
+}
+
+// AddSynonym is a method to record that a name should be treated as another name
+func (nameMatcher *NameMatcher) AddSynonym(synonym, accepted string) {
+	nameMatcher.synonyms[CanonicalName(synonym)] = CanonicalName(accepted)
+}
+
+// LoadSynonyms is a method to load a tab separated synonym table (synonym, accepted name), lines starting with # are skipped
+func (nameMatcher *NameMatcher) LoadSynonyms(path string) error {
+	fh, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	scanner := bufio.NewScanner(fh)
+	var lineNum int
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			return &ParseError{File: path, Line: lineNum, Err: fmt.Errorf("expected a synonym and an accepted name")}
+		}
+		nameMatcher.AddSynonym(fields[0], fields[1])
+	}
+	return scanner.Err()
+}
+
+// SetTaxdump is a method to match names using their NCBI taxid
+func (nameMatcher *NameMatcher) SetTaxdump(taxdump *Taxdump) {
+	nameMatcher.taxdump = taxdump
+}
+
+// Key is a method to get the key used to match a name, which is the taxid (if known) or the canonical name
+func (nameMatcher *NameMatcher) Key(name string) string {
+	canonical := CanonicalName(name)
+	if accepted, ok := nameMatcher.synonyms[canonical]; ok {
+		canonical = accepted
+	}
+	if nameMatcher.taxdump != nil {
+		if taxid, ok := nameMatcher.taxdump.GetTaxid(canonical); ok {
+			return fmt.Sprintf("taxid:%d", taxid)
+		}
+	}
+	return canonical
+}
+
+// SetReference is a method to set the reference names that OTU table names are matched to
+// it returns the names that were dropped because they have the same key as another reference name (the first name in sort order is kept)
+func (nameMatcher *NameMatcher) SetReference(names []string) []string {
+	sorted := make([]string, len(names))
+	copy(sorted, names)
+	sort.Strings(sorted)
+	nameMatcher.reference = make(map[string]string, len(sorted))
+	nameMatcher.exact = make(map[string]bool, len(sorted))
+	var clashes []string
+	for _, name := range sorted {
+		nameMatcher.exact[name] = true
+		key := nameMatcher.Key(name)
+		if _, ok := nameMatcher.reference[key]; ok {
+			clashes = append(clashes, name)
+			continue
+		}
+		nameMatcher.reference[key] = name
+	}
+	return clashes
+}
+
+// Match is a method to get the reference name matching a name, returning false if there isn't one
+// exact matches are always preferred
+func (nameMatcher *NameMatcher) Match(name string) (string, bool) {
+	if nameMatcher.exact[name] {
+		return name, true
+	}
+	if ref, ok := nameMatcher.reference[nameMatcher.Key(name)]; ok {
+		return ref, true
+	}
+	return "", false
+}
+
+// HarmoniseNames is a method to rename the genera in the OTU table to their matching reference names
+// genera with the same reference name are merged, and genera without a match are left as they are
+// any OTUs that have already been kept are cleared, and it returns the number of genera that were renamed
+func (otuTable *OTUTable) HarmoniseNames(nameMatcher *NameMatcher) (int, error) {
+	if nameMatcher == nil {
+		return 0, fmt.Errorf("no name matcher supplied")
+	}
+	renamed := make(map[string]struct{})
+	for i, sampleData := range otuTable.sampleData {
+		harmonised := make(map[string]float64, len(sampleData))
+		for genus, abundance := range sampleData {
+			name := genus
+			if ref, ok := nameMatcher.Match(genus); ok && ref != genus {
+				name = ref
+				renamed[genus] = struct{}{}
+			}
+			harmonised[name] += abundance
+		}
+		otuTable.sampleData[i] = harmonised
+		otuTable.topN[i] = nil
+	}
+	// renamed genera keep the deepest rank of the genera they were merged from
+	depths := otuTable.depth
+	otuTable.depth = nil
+	for genus, depth := range depths {
+		if ref, ok := nameMatcher.Match(genus); ok {
+			genus = ref
+		}
+		otuTable.setDepth(genus, depth)
+	}
+	otuTable.prevalence = nil
+	return len(renamed), nil
+}
@@ -0,0 +1,57 @@
+package hammer
+
+import (
+	"testing"
+)
+
+// test the name canonicalisation
+func TestCanonicalName(t *testing.T) {
+	tests := map[string]string{
+		"g__[Prevotella]":             "prevotella",
+		"Prevotella ":                 "prevotella",
+		"Prevotella_A":                "prevotella",
+		"Candidatus Saccharimonas":    "saccharimonas",
+		"g__Candidatus_Saccharimonas": "saccharimonas",
+		"'Ruminococcus'  gnavus":      "ruminococcus gnavus",
+	}
+	for name, canonical := range tests {
+		if got := CanonicalName(name); got != canonical {
+			t.Fatalf("wrong canonical name for %q: got %q, expected %q", name, got, canonical)
+		}
+	}
+}
+
+// test the OTU table names can be harmonised with a set of reference names
+func TestHarmoniseNames(t *testing.T) {
+	table, err := NewOTUTableFromMaps(map[string]map[string]float64{
+		"sampleA": {"[Prevotella]": 5, "Prevotella": 10, "Candidatus Saccharimonas": 2, "Lachnoclostridium": 1, "Unknown": 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	matcher := NewNameMatcher()
+	matcher.AddSynonym("Lachnoclostridium", "Clostridium")
+	if clashes := matcher.SetReference([]string{"Prevotella", "Saccharimonas", "Clostridium"}); len(clashes) != 0 {
+		t.Fatalf("unexpected reference name clashes: %v", clashes)
+	}
+	renamed, err := table.HarmoniseNames(matcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renamed != 3 {
+		t.Fatalf("expected 3 genera to be renamed, got %d", renamed)
+	}
+	data, _ := table.GetSampleData(0)
+	if data["Prevotella"] != 15 || data["Saccharimonas"] != 2 || data["Clostridium"] != 1 || data["Unknown"] != 3 {
+		t.Fatalf("names not harmonised correctly: %v", data)
+	}
+	if err := table.KeepTopN(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := table.HarmoniseNames(matcher); err != nil {
+		t.Fatal(err)
+	}
+	if topN, _ := table.GetTopN(0); len(topN) != 0 {
+		t.Fatal("harmonising names should clear the kept OTUs")
+	}
+}
@@ -0,0 +1,193 @@
+package hammer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/will-rowe/thor/src/colour"
+	"github.com/will-rowe/thor/src/newick"
+)
+
+// the supported row orderings
+const (
+	ORDER_ABUNDANCE = "abundance" // rows ordered by decreasing abundance in each sample
+	ORDER_STORE     = "store"     // a fixed order, using the sorted colour sketch store keys
+	ORDER_MEAN      = "mean"      // a fixed order, by decreasing mean relative abundance across the dataset
+	ORDER_FIXED     = "fixed"     // a fixed order, read from a file
+	ORDER_PHYLOGENY = "phylogeny" // a fixed order, using the leaf order of a tree
+)
+
+// RowOrderings are the supported row orderings
+var RowOrderings = []string{ORDER_ABUNDANCE, ORDER_STORE, ORDER_MEAN, ORDER_FIXED, ORDER_PHYLOGENY}
+
+// KeepOrder is a method to keep the OTUs in a fixed order, so that each genus occupies the same row in every sample
+// only the first n genera in the order are kept, and genera that are absent from a sample (or removed by the filter) are marked as padding
+// like KeepTopN, the original sampleData is not changed so the OTUs can be kept again
+func (otuTable *OTUTable) KeepOrder(order []string, n int) error {
+	if len(order) == 0 {
+		return fmt.Errorf("no genera in the row order")
+	}
+	if n > len(order) {
+		n = len(order)
+	}
+	otuTable.setPrevalence()
+	for i := range otuTable.sampleData {
+		sampleData := otuTable.filterSample(i)
+		otuTable.topN[i] = make([]OTU, n)
+		for j, genus := range order[:n] {
+			abundance := sampleData[genus]
+			if abundance == 0 {
+				genus = PAD_LINE
+			}
+			otuTable.topN[i][j] = OTU{genus, abundance}
+		}
+	}
+	return nil
+}
+
+// StoreOrder returns the colour sketch store keys in sorted order (excluding the padding line)
+func StoreOrder(colourStore colour.ColourSketchStore) []string {
+	order := make([]string, 0, len(colourStore))
+	for genus := range colourStore {
+		if genus != PAD_LINE {
+			order = append(order, genus)
+		}
+	}
+	sort.Strings(order)
+	return order
+}
+
+// MeanAbundanceOrder returns the genera in order of decreasing mean relative abundance across all samples in a set of OTU tables
+// relative abundances are used so that samples with different sequencing depths contribute equally, and ties are broken by name
+// the unfiltered abundances are used, so the order is the same whenever it is called and whatever filter is set
+func MeanAbundanceOrder(otuTables []*OTUTable) []string {
+	sums := NewAbundanceSums()
+	for _, otuTable := range otuTables {
+		sums.Add(otuTable)
+	}
+	return sums.Order()
+}
+
+// AbundanceSums holds the summed relative abundance of each genus over a set of samples
+// it lets the mean abundance order be found one table at a time, so the tables don't need to be held in memory together
+type AbundanceSums struct {
+	sums       map[string]float64
+	numSamples int
+}
+
+// NewAbundanceSums is the AbundanceSums constructor
+func NewAbundanceSums() *AbundanceSums {
+	return &AbundanceSums{sums: make(map[string]float64)}
+}
+
+// Add is a method to add the relative abundances of every sample in an OTU table to the sums
+func (abundanceSums *AbundanceSums) Add(otuTable *OTUTable) {
+	for _, sampleData := range otuTable.sampleData {
+		abundanceSums.numSamples++
+		total := sampleTotal(sampleData)
+		if total == 0 {
+			continue
+		}
+		for genus, abundance := range sampleData {
+			abundanceSums.sums[genus] += abundance / total
+		}
+	}
+}
+
+// Merge is a method to add the sums from another AbundanceSums (e.g. one made from a different table)
+func (abundanceSums *AbundanceSums) Merge(other *AbundanceSums) {
+	abundanceSums.numSamples += other.numSamples
+	for genus, sum := range other.sums {
+		abundanceSums.sums[genus] += sum
+	}
+}
+
+// Order is a method to get the genera in order of decreasing mean relative abundance, ties are broken by name
+func (abundanceSums *AbundanceSums) Order() []string {
+	means := make(map[string]float64, len(abundanceSums.sums))
+	order := make([]string, 0, len(abundanceSums.sums))
+	for genus, sum := range abundanceSums.sums {
+		means[genus] = sum / float64(abundanceSums.numSamples)
+		order = append(order, genus)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		if means[order[i]] != means[order[j]] {
+			return means[order[i]] > means[order[j]]
+		}
+		return order[i] < order[j]
+	})
+	return order
+}
+
+// PhylogenyOrder returns the reference names (e.g. the colour sketch store keys) in the leaf order of a tree, so related genera sit on neighbouring rows
+// the leaves are matched to the reference names using MatchOrder
+func PhylogenyOrder(tree *newick.Node, nameMatcher *NameMatcher) ([]string, []string) {
+	return MatchOrder(tree.Leaves(), nameMatcher)
+}
+
+// MatchOrder returns the reference names for a list of names (e.g. tree leaves or an order file), keeping their order
+// names are matched using the NameMatcher, falling back to the first word of the name (the genus of a species name),
+// only the first name for each reference name is used and the names that could not be matched are also returned
+func MatchOrder(names []string, nameMatcher *NameMatcher) ([]string, []string) {
+	var order, unmatched []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		ref, ok := nameMatcher.Match(name)
+		if !ok {
+			if fields := strings.Fields(name); len(fields) > 1 {
+				ref, ok = nameMatcher.Match(fields[0])
+			}
+		}
+		if !ok {
+			unmatched = append(unmatched, name)
+			continue
+		}
+		if !seen[ref] {
+			seen[ref] = true
+			order = append(order, ref)
+		}
+	}
+	return order, unmatched
+}
+
+// LoadOrder reads a row order from a file, with one genus per line (blank lines and lines starting with # are skipped)
+func LoadOrder(path string) ([]string, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	var order []string
+	seen := make(map[string]int)
+	scanner := bufio.NewScanner(fh)
+	var lineNum int
+	for scanner.Scan() {
+		lineNum++
+		genus := strings.TrimSpace(scanner.Text())
+		if genus == "" || genus[0] == '#' {
+			continue
+		}
+		if prev, ok := seen[genus]; ok {
+			return nil, &ParseError{File: path, Line: lineNum, Err: fmt.Errorf("genus already in the order at line %d: %v", prev, genus)}
+		}
+		seen[genus] = lineNum
+		order = append(order, genus)
+	}
+	return order, scanner.Err()
+}
+
+// FilterOrder returns the genera in the order that are present in the colour sketch store, along with those that are missing
+func FilterOrder(order []string, colourStore colour.ColourSketchStore) ([]string, []string) {
+	var kept, missing []string
+	for _, genus := range order {
+		if _, ok := colourStore[genus]; ok && genus != PAD_LINE {
+			kept = append(kept, genus)
+		} else {
+			missing = append(missing, genus)
+		}
+	}
+	return kept, missing
+}
@@ -0,0 +1,111 @@
+package hammer
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/will-rowe/thor/src/colour"
+	"github.com/will-rowe/thor/src/newick"
+)
+
+// test the fixed row orderings
+func TestKeepOrder(t *testing.T) {
+	table, err := NewOTUTableFromMaps(map[string]map[string]float64{
+		"sampleA": {"Bacteroides": 90, "Prevotella": 10},
+		"sampleB": {"Prevotella": 1, "Simonsiella": 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := MeanAbundanceOrder([]*OTUTable{table})
+	if len(order) != 3 || order[0] != "Bacteroides" || order[1] != "Prevotella" || order[2] != "Simonsiella" {
+		t.Fatalf("wrong mean abundance order: %v", order)
+	}
+	// the sums from separate tables can be merged, giving the same order as the tables together
+	other, err := NewOTUTableFromMaps(map[string]map[string]float64{"sampleC": {"Simonsiella": 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sums, otherSums := NewAbundanceSums(), NewAbundanceSums()
+	sums.Add(table)
+	otherSums.Add(other)
+	sums.Merge(otherSums)
+	merged := sums.Order()
+	if together := MeanAbundanceOrder([]*OTUTable{table, other}); strings.Join(merged, ",") != "Simonsiella,Bacteroides,Prevotella" || strings.Join(merged, ",") != strings.Join(together, ",") {
+		t.Fatalf("merged mean abundance order (%v) doesn't match the order of the tables together (%v)", merged, together)
+	}
+	if err := table.KeepOrder(order, 5); err != nil {
+		t.Fatal(err)
+	}
+	topN, _ := table.GetTopN(1)
+	if len(topN) != 3 || topN[0].Name != PAD_LINE || topN[1].Name != "Prevotella" || topN[2].Name != "Simonsiella" {
+		t.Fatalf("genera should be kept in order, with absent genera as padding: %v", topN)
+	}
+	if err := table.KeepOrder(nil, 5); err == nil {
+		t.Fatal("an empty order should raise an error")
+	}
+}
+
+// test the store order, order file and filtering
+func TestOrderFromStore(t *testing.T) {
+	css := make(colour.ColourSketchStore)
+	for _, genus := range []string{"Prevotella", "Bacteroides", PAD_LINE} {
+		css[genus] = colour.NewColourSketch([]uint32{1, 2, 3}, genus)
+	}
+	if order := StoreOrder(css); len(order) != 2 || order[0] != "Bacteroides" {
+		t.Fatalf("wrong store order: %v", order)
+	}
+	fh, err := ioutil.TempFile("", "thor-order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fh.Name())
+	fh.WriteString("# order\nPrevotella\n\nSimonsiella\nBacteroides\n")
+	fh.Close()
+	order, err := LoadOrder(fh.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, missing := FilterOrder(order, css)
+	if len(kept) != 2 || kept[0] != "Prevotella" || len(missing) != 1 || missing[0] != "Simonsiella" {
+		t.Fatalf("order not filtered correctly: %v %v", kept, missing)
+	}
+	if err := ioutil.WriteFile(fh.Name(), []byte("Prevotella\nPrevotella\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadOrder(fh.Name()); err == nil {
+		t.Fatal("duplicate genera should raise an error")
+	}
+}
+
+// test the phylogeny order
+func TestPhylogenyOrder(t *testing.T) {
+	tree, err := newick.Parse("((Bacteroides_fragilis,'g__[Prevotella]'),(Streptococcus,(Bacteroides,Unknown)));")
+	if err != nil {
+		t.Fatal(err)
+	}
+	matcher := NewNameMatcher()
+	matcher.SetReference([]string{"Streptococcus", "Prevotella", "Bacteroides", "Simonsiella"})
+	order, unmatched := PhylogenyOrder(tree, matcher)
+	if len(order) != 3 || order[0] != "Bacteroides" || order[1] != "Prevotella" || order[2] != "Streptococcus" {
+		t.Fatalf("wrong phylogeny order: %v", order)
+	}
+	if len(unmatched) != 1 || unmatched[0] != "Unknown" {
+		t.Fatalf("wrong unmatched leaves: %v", unmatched)
+	}
+}
+
+// test that order file names are matched to the reference names
+func TestMatchOrder(t *testing.T) {
+	matcher := NewNameMatcher()
+	matcher.SetReference([]string{"Streptococcus", "Prevotella", "Bacteroides"})
+	order, unmatched := MatchOrder([]string{"g__[Prevotella]", "Bacteroides fragilis", "Bacteroides", "Unknown"}, matcher)
+	if len(order) != 2 || order[0] != "Prevotella" || order[1] != "Bacteroides" {
+		t.Fatalf("wrong matched order: %v", order)
+	}
+	if len(unmatched) != 1 || unmatched[0] != "Unknown" {
+		t.Fatalf("wrong unmatched names: %v", unmatched)
+	}
+}
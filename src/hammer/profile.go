@@ -0,0 +1,297 @@
+package hammer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// rankNames are the names used to choose a rank for taxonomic profiles
+var rankNames = map[string]Rank{
+	"domain":  DOMAIN,
+	"kingdom": DOMAIN,
+	"phylum":  PHYLUM,
+	"class":   CLASS,
+	"order":   ORDER,
+	"family":  FAMILY,
+	"genus":   GENUS,
+	"species": SPECIES,
+}
+
+// krakenRankCodes are the rank codes used by Kraken2 and Bracken
+var krakenRankCodes = map[Rank][]string{
+	DOMAIN:  {"D", "K"},
+	PHYLUM:  {"P"},
+	CLASS:   {"C"},
+	ORDER:   {"O"},
+	FAMILY:  {"F"},
+	GENUS:   {"G"},
+	SPECIES: {"S"},
+}
+
+// ProfileFormats are the formats that hold a taxonomic profile for a single sample
+var ProfileFormats = []string{"kraken2", "bracken", "metaphlan"}
+
+// IsProfileFormat returns true if the format holds a taxonomic profile for a single sample
+func IsProfileFormat(format string) bool {
+	for _, profileFormat := range ProfileFormats {
+		if format == profileFormat {
+			return true
+		}
+	}
+	return false
+}
+
+// profileExtensions are removed from profile file names to get the sample name
+var profileExtensions = []string{".gz", ".bz2", ".zst", ".txt", ".tsv", ".report", ".kreport", ".kreport2", ".kraken", ".kraken2", ".bracken", ".profile", ".metaphlan", ".mpa"}
+
+// ParseRank returns the Rank for a rank name (e.g. genus), an empty name is treated as genus
+func ParseRank(name string) (Rank, error) {
+	if name == "" {
+		return GENUS, nil
+	}
+	rank, ok := rankNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return 0, fmt.Errorf("unsupported rank: %v", name)
+	}
+	return rank, nil
+}
+
+// profileSampleName returns the sample name for a single sample profile, which is the file name without its extensions
+func profileSampleName(path string) string {
+	name := filepath.Base(path)
+	for trimmed := true; trimmed; {
+		trimmed = false
+		for _, ext := range profileExtensions {
+			if strings.HasSuffix(strings.ToLower(name), ext) && len(name) > len(ext) {
+				name = name[:len(name)-len(ext)]
+				trimmed = true
+			}
+		}
+	}
+	return name
+}
+
+// setProfile is a method to set up the otuTable to hold a single sample profile of the taxa at a rank
+// relative is true for profile formats that hold relative abundances rather than read counts
+func (otuTable *OTUTable) setProfile(opts TableOptions, sampleData map[string]float64, rank Rank, relative bool) error {
+	if len(sampleData) == 0 {
+		return &ParseError{File: opts.Name, Err: fmt.Errorf("no taxa found at the requested rank")}
+	}
+	otuTable.sampleNames = [][]byte{[]byte(profileSampleName(opts.Name))}
+	otuTable.sampleData = []map[string]float64{sampleData}
+	otuTable.topN = make([][]OTU, 1)
+	otuTable.totalOTUs = len(sampleData)
+	otuTable.relative = relative
+	for taxon := range sampleData {
+		otuTable.setDepth(taxon, rank)
+	}
+	return nil
+}
+
+// readKrakenReport will load a Kraken2 (or Bracken) report for a single sample into the otuTable
+// the clade read counts of the taxa at the requested rank are used, and reports with minimizer data are supported
+func (otuTable *OTUTable) readKrakenReport(fh io.Reader, opts TableOptions) error {
+	rank, err := ParseRank(opts.Rank)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(fh)
+	sampleData := make(map[string]float64)
+	var lineNum int
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		// percent, clade reads, taxon reads, [minimizers, distinct minimizers,] rank code, taxid, name
+		var rankCol int
+		switch len(fields) {
+		case 6:
+			rankCol = 3
+		case 8:
+			rankCol = 5
+		default:
+			return &ParseError{File: opts.Name, Line: lineNum, Err: fmt.Errorf("expected 6 or 8 fields in Kraken report, found %d", len(fields))}
+		}
+		if !checkRankCode(fields[rankCol], rank) {
+			continue
+		}
+		reads, err := parseAbundance(fields[1])
+		if err != nil {
+			return &ParseError{File: opts.Name, Line: lineNum, Column: 2, Err: err}
+		}
+		name := strings.TrimSpace(fields[rankCol+2])
+		if name == "" || reads == 0 {
+			continue
+		}
+		sampleData[name] += reads
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return otuTable.setProfile(opts, sampleData, rank, false)
+}
+
+// readBracken will load a Bracken abundance table for a single sample into the otuTable
+// the re-estimated read counts (new_est_reads) of the taxa at the requested rank are used
+func (otuTable *OTUTable) readBracken(fh io.Reader, opts TableOptions) error {
+	rank, err := ParseRank(opts.Rank)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(fh)
+	sampleData := make(map[string]float64)
+	var lineNum int
+	nameCol, levelCol, readsCol := -1, -1, -1
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if nameCol == -1 {
+			nameCol, levelCol, readsCol = findColumn(fields, []string{"name"}, -1), findColumn(fields, []string{"taxonomy_lvl"}, -1), findColumn(fields, []string{"new_est_reads"}, -1)
+			if nameCol == -1 || levelCol == -1 || readsCol == -1 {
+				return &ParseError{File: opts.Name, Line: lineNum, Err: fmt.Errorf("expected a Bracken header (name, taxonomy_lvl, new_est_reads)")}
+			}
+			continue
+		}
+		if len(fields) <= readsCol || len(fields) <= levelCol || len(fields) <= nameCol {
+			return &ParseError{File: opts.Name, Line: lineNum, Err: fmt.Errorf("too few fields in Bracken table: %d", len(fields))}
+		}
+		if !checkRankCode(fields[levelCol], rank) {
+			continue
+		}
+		reads, err := parseAbundance(fields[readsCol])
+		if err != nil {
+			return &ParseError{File: opts.Name, Line: lineNum, Column: readsCol + 1, Err: err}
+		}
+		if reads != 0 {
+			sampleData[strings.TrimSpace(fields[nameCol])] += reads
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if nameCol == -1 {
+		return &ParseError{File: opts.Name, Line: lineNum, Err: fmt.Errorf("no header found in Bracken table")}
+	}
+	return otuTable.setProfile(opts, sampleData, rank, false)
+}
+
+// readMetaPhlAn will load a MetaPhlAn (v2, v3 or v4) profile for a single sample into the otuTable
+// the relative abundances (percentages) of the clades at the requested rank are converted to fractions
+func (otuTable *OTUTable) readMetaPhlAn(fh io.Reader, opts TableOptions) error {
+	rank, err := ParseRank(opts.Rank)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(fh)
+	sampleData := make(map[string]float64)
+	var lineNum int
+	abundanceCol := -1
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		// the column header gives the position of the relative abundance
+		if line[0] == '#' {
+			if strings.HasPrefix(strings.ToLower(fields[0]), "#clade_name") {
+				abundanceCol = findColumn(fields, []string{"relative_abundance"}, -1)
+			}
+			continue
+		}
+		col := abundanceCol
+		if col == -1 {
+			// MetaPhlAn2 profiles have no column header and only two columns, later versions have a taxid column
+			col = 1
+			if len(fields) > 2 {
+				col = 2
+			}
+		}
+		if len(fields) <= col {
+			return &ParseError{File: opts.Name, Line: lineNum, Err: fmt.Errorf("too few fields in MetaPhlAn profile: %d", len(fields))}
+		}
+		// only use clades that end at the requested rank
+		clades := strings.Split(fields[0], "|")
+		cladeRank, name, ok := splitRankPrefix(clades[len(clades)-1])
+		if !ok || cladeRank != rank || name == "" {
+			continue
+		}
+		percent, err := parseAbundance(fields[col])
+		if err != nil {
+			return &ParseError{File: opts.Name, Line: lineNum, Column: col + 1, Err: err}
+		}
+		if percent > 100 {
+			return &ParseError{File: opts.Name, Line: lineNum, Column: col + 1, Err: fmt.Errorf("relative abundance is greater than 100: %v", percent)}
+		}
+		if percent != 0 {
+			sampleData[strings.Replace(name, "_", " ", -1)] += percent / 100
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return otuTable.setProfile(opts, sampleData, rank, true)
+}
+
+// checkRankCode checks if a Kraken/Bracken rank code is for the requested rank (sub-ranks such as G1 are not used)
+func checkRankCode(code string, rank Rank) bool {
+	code = strings.TrimSpace(code)
+	for _, c := range krakenRankCodes[rank] {
+		if code == c {
+			return true
+		}
+	}
+	return false
+}
+
+// MergeOTUTables combines the samples from a set of OTU tables (e.g. single sample profiles) into one OTU table
+// sample names must be unique, and the abundances must all be counts or all be relative
+func MergeOTUTables(otuTables []*OTUTable) (*OTUTable, error) {
+	if len(otuTables) == 0 {
+		return nil, fmt.Errorf("no OTU tables to merge")
+	}
+	merged := &OTUTable{
+		program:  otuTables[0].program,
+		relative: otuTables[0].relative,
+		filter:   otuTables[0].filter,
+	}
+	seen := make(map[string]bool)
+	genera := make(map[string]bool)
+	for _, otuTable := range otuTables {
+		if otuTable.IsRelative() != merged.IsRelative() {
+			return nil, fmt.Errorf("can't merge OTU tables of relative abundances with OTU tables of counts")
+		}
+		for i, name := range otuTable.sampleNames {
+			if seen[string(name)] {
+				return nil, fmt.Errorf("sample name found more than once: %v", string(name))
+			}
+			seen[string(name)] = true
+			sampleData := make(map[string]float64, len(otuTable.sampleData[i]))
+			for genus, abundance := range otuTable.sampleData[i] {
+				sampleData[genus] = abundance
+				genera[genus] = true
+			}
+			merged.sampleNames = append(merged.sampleNames, name)
+			merged.sampleData = append(merged.sampleData, sampleData)
+		}
+		for genus, depth := range otuTable.depth {
+			merged.setDepth(genus, depth)
+		}
+		merged.comments = append(merged.comments, otuTable.comments...)
+		merged.unclassified += otuTable.unclassified
+	}
+	merged.topN = make([][]OTU, len(merged.sampleNames))
+	merged.totalOTUs = len(genera)
+	return merged, nil
+}
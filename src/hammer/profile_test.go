@@ -0,0 +1,82 @@
+package hammer
+
+import (
+	"strings"
+	"testing"
+)
+
+var (
+	testKraken    = "  5.00\t50\t50\tU\t0\tunclassified\n 95.00\t950\t0\tR\t1\troot\n 90.00\t900\t10\tD\t2\t    Bacteria\n 60.00\t600\t600\tG\t816\t            Bacteroides\n  2.00\t20\t20\tG1\t9999\t              Bacteroides subgroup\n 29.00\t290\t0\tG\t838\t            Prevotella\n 29.00\t290\t290\tS\t165179\t              Prevotella copri\n"
+	testBracken   = "name\ttaxonomy_id\ttaxonomy_lvl\tkraken_assigned_reads\tadded_reads\tnew_est_reads\tfraction_total_reads\nBacteroides\t816\tG\t600\t20\t620\t0.68\nPrevotella\t838\tG\t290\t0\t290\t0.32\n"
+	testMetaPhlAn = "#mpa_v30_CHOCOPhlAn_201901\n#/usr/bin/metaphlan sample.fastq\n#SampleID\tMetaphlan_Analysis\n#clade_name\tNCBI_tax_id\trelative_abundance\tadditional_species\nk__Bacteria\t2\t100.0\t\nk__Bacteria|p__Bacteroidetes|c__Bacteroidia|o__Bacteroidales|f__Bacteroidaceae|g__Bacteroides\t2|976|200643|171549|815|816\t70.0\t\nk__Bacteria|p__Bacteroidetes|c__Bacteroidia|o__Bacteroidales|f__Prevotellaceae|g__Prevotella\t2|976|200643|171549|171552|838\t30.0\t\nk__Bacteria|p__Bacteroidetes|c__Bacteroidia|o__Bacteroidales|f__Prevotellaceae|g__Prevotella|s__Prevotella_copri\t2|976|200643|171549|171552|838|165179\t30.0\t\n"
+)
+
+// test the Kraken2, Bracken and MetaPhlAn readers
+func TestProfiles(t *testing.T) {
+	tests := []struct {
+		format, data string
+		bacteroides  float64
+	}{
+		{"kraken2", testKraken, 600},
+		{"bracken", testBracken, 620},
+		{"metaphlan", testMetaPhlAn, 0.7},
+	}
+	for _, test := range tests {
+		table, err := NewOTUTableWithOptions(strings.NewReader(test.data), TableOptions{Format: test.format, Name: "/data/sampleA." + test.format + ".txt.gz"})
+		if err != nil {
+			t.Fatalf("%v: %v", test.format, err)
+		}
+		if name, _ := table.GetSampleName(0); name != "sampleA" {
+			t.Fatalf("%v: wrong sample name: %v", test.format, name)
+		}
+		data, _ := table.GetSampleData(0)
+		if len(data) != 2 || data["Bacteroides"] != test.bacteroides {
+			t.Fatalf("%v: wrong sample data: %v", test.format, data)
+		}
+	}
+	// use a different rank
+	table, err := NewOTUTableWithOptions(strings.NewReader(testMetaPhlAn), TableOptions{Format: "metaphlan", Name: "sampleA", Rank: "species"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := table.GetSampleData(0); data["Prevotella copri"] != 0.3 || !table.IsRelative() {
+		t.Fatalf("wrong species data: %v", data)
+	}
+	if _, err := NewOTUTableWithOptions(strings.NewReader(testKraken), TableOptions{Format: "kraken2", Rank: "strain"}); err == nil {
+		t.Fatal("unsupported rank should raise an error")
+	}
+	if _, err := NewOTUTableWithOptions(strings.NewReader("50\tG\tBacteroides\n"), TableOptions{Format: "kraken2"}); err == nil {
+		t.Fatal("malformed report should raise an error")
+	}
+}
+
+// test merging single sample profiles
+func TestMergeOTUTables(t *testing.T) {
+	var tables []*OTUTable
+	for _, name := range []string{"sampleA.kreport", "sampleB.kreport"} {
+		table, err := NewOTUTableWithOptions(strings.NewReader(testKraken), TableOptions{Format: "kraken2", Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, table)
+	}
+	// a shallow report with a single read for each genus still holds counts
+	shallow, err := NewOTUTableWithOptions(strings.NewReader("100.00\t2\t0\tR\t1\troot\n 50.00\t1\t1\tG\t816\t  Bacteroides\n 50.00\t1\t1\tG\t838\t  Prevotella\n"), TableOptions{Format: "kraken2", Name: "sampleC.kreport"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shallow.IsRelative() {
+		t.Fatal("kraken2 reports should always hold counts")
+	}
+	tables = append(tables, shallow)
+	merged, err := MergeOTUTables(tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.GetNumSamples() != 3 || merged.GetTotalGenusOTUs() != 2 {
+		t.Fatal("tables not merged correctly")
+	}
+	if _, err := MergeOTUTables([]*OTUTable{tables[0], tables[0]}); err == nil {
+		t.Fatal("duplicate sample names should raise an error")
+	}
+}
@@ -0,0 +1,138 @@
+package hammer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// the header names used by QIIME for the taxonomy column
+var qiimeTaxonomyHeaders = []string{"consensus lineage", "consensuslineage", "taxonomy"}
+
+// readQiimeTable will load a qiime file into the otuTable
+// the taxonomy column is optional if a Taxonomy is supplied, abundances can be counts or relative values (if every sample totals 1)
+func (otuTable *OTUTable) readQiimeTable(fh io.Reader, opts TableOptions) error {
+	// create a new reader
+	r := bufio.NewReader(fh)
+	// slurp off the comments
+	var lineNum int
+	var header []string
+	for header == nil {
+		line, err := r.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			if err == io.EOF {
+				return &ParseError{File: opts.Name, Line: lineNum, Err: fmt.Errorf("no #OTU header line found in Qiime OTU table")}
+			}
+			return err
+		}
+		lineNum++
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] != '#' {
+			return &ParseError{File: opts.Name, Line: lineNum, Err: fmt.Errorf("no comment or header lines found in Qiime OTU table")}
+		}
+		// add the comment (keeping a newline) and keep reading
+		if !bytes.HasPrefix(line, []byte("#OTU")) {
+			otuTable.comments = append(otuTable.comments, append(line, '\n'))
+			continue
+		}
+		// or finish the slurping and add the samples from the header
+		header = strings.Split(string(line), "\t")
+	}
+	// work out if there is a taxonomy column
+	taxColumn := -1
+	if checkHeader(header[len(header)-1], qiimeTaxonomyHeaders) {
+		taxColumn = len(header) - 1
+	} else if opts.Taxonomy == nil {
+		return &ParseError{File: opts.Name, Line: lineNum, Err: fmt.Errorf("no taxonomy column found in header, a taxonomy file is needed for this table")}
+	}
+	numSamples := len(header) - 1
+	if taxColumn != -1 {
+		numSamples--
+	}
+	if numSamples < 1 {
+		return &ParseError{File: opts.Name, Line: lineNum, Err: fmt.Errorf("no samples found in header")}
+	}
+	otuTable.sampleNames = make([][]byte, numSamples)
+	otuTable.sampleData = make([]map[string]float64, numSamples)
+	otuTable.topN = make([][]OTU, numSamples)
+	for i := 0; i < numSamples; i++ {
+		otuTable.sampleNames[i] = []byte(strings.TrimSpace(header[i+1]))
+		otuTable.sampleData[i] = make(map[string]float64)
+	}
+	// read the OTUs
+	var counter int
+	totals := make([]float64, numSamples)
+	tsvReader := csv.NewReader(r)
+	tsvReader.Comma = '\t'
+	tsvReader.Comment = '#'
+	tsvReader.LazyQuotes = true
+	tsvReader.FieldsPerRecord = len(header)
+	for {
+		line, err := tsvReader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			if csvErr, ok := err.(*csv.ParseError); ok {
+				return &ParseError{File: opts.Name, Line: lineNum + csvErr.Line, Column: csvErr.Column, Err: csvErr.Err}
+			}
+			return err
+		}
+		recordLine, _ := tsvReader.FieldPos(0)
+		// get the genus for this OTU, either from the consensus lineage or the taxonomy file
+		var lineage Lineage
+		if taxColumn != -1 {
+			lineage = ParseLineage(line[taxColumn])
+		} else {
+			lineage = opts.Taxonomy[strings.TrimSpace(line[0])]
+		}
+		genus := lineage.Get(GENUS)
+		// add the abundance values to the corresponding samples, unclassified OTUs only count towards the sample totals
+		for i := 1; i <= numSamples; i++ {
+			value, err := parseAbundance(line[i])
+			if err != nil {
+				return &ParseError{File: opts.Name, Line: lineNum + recordLine, Column: i + 1, Sample: string(otuTable.sampleNames[i-1]), Err: err}
+			}
+			totals[i-1] += value
+			if genus != "" {
+				otuTable.sampleData[i-1][genus] += value
+			}
+		}
+		if genus == "" {
+			otuTable.unclassified++
+			continue
+		}
+		otuTable.setDepth(genus, lineage.Depth())
+		counter++
+	}
+	otuTable.totalOTUs = counter
+	otuTable.relative = isRelative(totals)
+	return nil
+}
+
+// parseAbundance parses an abundance value, which must be a finite, non-negative number
+func parseAbundance(field string) (float64, error) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+	if err == nil && (value < 0 || math.IsNaN(value) || math.IsInf(value, 0)) {
+		err = fmt.Errorf("invalid abundance value: %v", field)
+	}
+	return value, err
+}
+
+// checkHeader checks if a column header matches one of a set of names (ignoring case and surrounding whitespace)
+func checkHeader(header string, names []string) bool {
+	header = strings.ToLower(strings.TrimSpace(header))
+	for _, name := range names {
+		if header == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package hammer
+
+import (
+	"strings"
+	"testing"
+)
+
+// test that CRLF line endings, blank lines and short comments are handled
+func TestQiimeCRLF(t *testing.T) {
+	data := "#\r\n# a comment\r\n\r\n#OTU ID\ts1\ts2\tConsensus Lineage\r\nOTU_1\t1\t2\tRoot;g__Bacteroides\r\nOTU_2\t3\t4\tRoot;g__Bacteroides \r\n"
+	table, err := NewOTUTableWithOptions(strings.NewReader(data), TableOptions{Format: prog})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.GetNumSamples() != 2 {
+		t.Fatal("wrong number of samples")
+	}
+	sampleData, _ := table.GetSampleData(1)
+	if sampleData["Bacteroides"] != 6 {
+		t.Fatalf("OTUs not aggregated by genus: %v", sampleData)
+	}
+	if len(table.comments) != 2 {
+		t.Fatalf("expected 2 comment lines, got %d", len(table.comments))
+	}
+}
+
+// test that relative abundances are parsed and scaled accordingly
+func TestQiimeRelative(t *testing.T) {
+	data := "#OTU ID\ts1\ttaxonomy\nOTU_1\t0.25\tRoot;g__Bacteroides\nOTU_2\t0.75\tRoot;g__Prevotella\nOTU_3\t0\tRoot;f__Unknown\n"
+	table, err := NewOTUTableWithOptions(strings.NewReader(data), TableOptions{Format: prog})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !table.IsRelative() {
+		t.Fatal("table should be recognised as relative abundance")
+	}
+	if table.GetTotalGenusOTUs() != 2 || table.GetUnclassifiedOTUs() != 1 {
+		t.Fatal("unclassified OTUs not counted correctly")
+	}
+	// unclassified OTUs count towards the sample totals
+	data = "#OTU ID\ts1\ts2\ttaxonomy\nOTU_1\t0.5\t0.2\tRoot;g__Bacteroides\nOTU_2\t0.5\t0.8\tRoot;f__Unknown\n"
+	if table, err = NewOTUTableWithOptions(strings.NewReader(data), TableOptions{Format: prog}); err != nil {
+		t.Fatal(err)
+	}
+	if !table.IsRelative() || table.GetAbundanceCap() != RELATIVE_ABUNDANCE_CAP {
+		t.Fatal("table with unclassified OTUs should be recognised as relative abundance")
+	}
+	// sparse counts and presence/absence tables never exceed 1, but are not relative
+	data = "#OTU ID\ts1\ts2\ttaxonomy\nOTU_1\t1\t0\tRoot;g__Bacteroides\nOTU_2\t1\t1\tRoot;g__Prevotella\n"
+	if table, err = NewOTUTableWithOptions(strings.NewReader(data), TableOptions{Format: prog}); err != nil {
+		t.Fatal(err)
+	}
+	if table.IsRelative() || table.GetAbundanceCap() != COUNT_ABUNDANCE_CAP {
+		t.Fatal("presence/absence table should be recognised as counts")
+	}
+	table.SetRelative(true)
+	if table.GetAbundanceCap() != RELATIVE_ABUNDANCE_CAP {
+		t.Fatal("relative abundance cap not used after SetRelative")
+	}
+}
+
+// test that a table without a taxonomy column uses the supplied taxonomy
+func TestQiimeNoTaxonomyColumn(t *testing.T) {
+	data := "#OTU ID\ts1\ts2\nASV_1\t1\t2\nASV_2\t3\t4\nASV_3\t5\t6\n"
+	if _, err := NewOTUTableWithOptions(strings.NewReader(data), TableOptions{Format: prog}); err == nil {
+		t.Fatal("a table without a taxonomy column should need a taxonomy")
+	}
+	taxonomy := Taxonomy{"ASV_1": ParseLineage("g__Bacteroides"), "ASV_2": ParseLineage("g__Prevotella")}
+	table, err := NewOTUTableWithOptions(strings.NewReader(data), TableOptions{Format: prog, Taxonomy: taxonomy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.GetTotalGenusOTUs() != 2 || table.GetUnclassifiedOTUs() != 1 {
+		t.Fatal("taxonomy not joined to table")
+	}
+}
+
+// test that parse errors report where the problem is
+func TestQiimeParseError(t *testing.T) {
+	data := "# comment\n#OTU ID\ts1\t s2 \tConsensus Lineage\nOTU_1\t1\t2\tRoot;g__Bacteroides\nOTU_2\t3\tfoo\tRoot;g__Prevotella\n"
+	_, err := NewOTUTableWithOptions(strings.NewReader(data), TableOptions{Format: prog, Name: "table.txt"})
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected a ParseError, got %v", err)
+	}
+	if parseErr.File != "table.txt" || parseErr.Line != 4 || parseErr.Column != 3 || parseErr.Sample != "s2" {
+		t.Fatalf("wrong error position: %v", parseErr)
+	}
+	// short rows and negative values should also error
+	for _, data := range []string{
+		"#OTU ID\ts1\tConsensus Lineage\nOTU_1\t1\n",
+		"#OTU ID\ts1\tConsensus Lineage\nOTU_1\t-1\tRoot;g__Bacteroides\n",
+		"#OTU ID\tConsensus Lineage\nOTU_1\tRoot;g__Bacteroides\n",
+		"OTU_1\t1\tRoot;g__Bacteroides\n",
+		"",
+	} {
+		if _, err := NewOTUTableWithOptions(strings.NewReader(data), TableOptions{Format: prog}); err == nil {
+			t.Fatalf("expected an error for table: %q", data)
+		}
+	}
+}
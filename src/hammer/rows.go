@@ -0,0 +1,62 @@
+package hammer
+
+import (
+	"github.com/will-rowe/thor/src/colour"
+)
+
+// the status of each kept OTU row
+const (
+	ROW_DRAWN   = "drawn"   // the genus was drawn as a row of the image
+	ROW_MISSING = "missing" // the genus is not in the colour sketches, so it was not drawn
+	ROW_PADDING = "padding" // the row is padding (an absent genus or too few OTUs), it is only drawn if padding is requested
+)
+
+// Row describes a kept OTU and where it was drawn in a sample's image
+type Row struct {
+	Index     int     // the image row, or -1 if the OTU was not drawn
+	Genus     string  // the genus (empty for padding rows)
+	Abundance float64 // the abundance in the OTU table
+	Scaled    float64 // the abundance scaled by the abundance cap, to between 0 and 1
+	Status    string  // one of ROW_DRAWN, ROW_MISSING or ROW_PADDING
+}
+
+// GetRows is a method to describe each of the kept OTUs for a sample, using the same rules as ColourSample to decide which are drawn
+func (otuTable *OTUTable) GetRows(i int, colourStore colour.ColourSketchStore, pad bool) ([]Row, error) {
+	if err := otuTable.checkIndex(i); err != nil {
+		return nil, err
+	}
+	rows := make([]Row, len(otuTable.topN[i]))
+	var index int
+	for j, otu := range otuTable.topN[i] {
+		row := Row{
+			Index:     -1,
+			Genus:     otu.Name,
+			Abundance: otu.Abundance,
+			Scaled:    otuTable.scaleAbundance(otu.Abundance),
+			Status:    ROW_DRAWN,
+		}
+		if otu.Name == PAD_LINE {
+			row.Genus = ""
+			row.Status = ROW_PADDING
+		}
+		_, ok := colourStore[otu.Name]
+		switch {
+		case row.Status == ROW_PADDING && (!pad || !ok):
+		case !ok:
+			row.Status = ROW_MISSING
+		default:
+			row.Index = index
+			index++
+		}
+		rows[j] = row
+	}
+	return rows, nil
+}
+
+// GetAbundanceCap is a method to get the abundance that is scaled to the maximum pixel value
+func (otuTable *OTUTable) GetAbundanceCap() float64 {
+	if otuTable.relative {
+		return RELATIVE_ABUNDANCE_CAP
+	}
+	return COUNT_ABUNDANCE_CAP
+}
@@ -0,0 +1,56 @@
+package hammer
+
+import (
+	"testing"
+
+	"github.com/will-rowe/thor/src/colour"
+)
+
+// test the GetRows method matches the rows drawn by ColourSample
+func TestGetRows(t *testing.T) {
+	table, err := NewOTUTableFromMaps(map[string]map[string]float64{"s1": {"Bacteroides": 2500, "Streptococcus": 10, "Simonsiella": 5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.KeepTopN(4); err != nil {
+		t.Fatal(err)
+	}
+	css := make(colour.ColourSketchStore)
+	css["Bacteroides"] = colour.NewColourSketch([]uint32{1, 2, 3, 4}, "Bacteroides")
+	css["Simonsiella"] = colour.NewColourSketch([]uint32{1, 2, 3, 4}, "Simonsiella")
+	css[PAD_LINE] = colour.NewColourSketch(make([]uint32, 4), PAD_LINE)
+	for _, pad := range []bool{false, true} {
+		rows, err := table.GetRows(0, css, pad)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines, err := table.ColourSample(0, css, pad)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var drawn int
+		for j, row := range rows {
+			if (row.Index != -1) != (lines[j] != nil) {
+				t.Fatalf("row %d does not match the coloured sample (padding %t): %+v", j, pad, row)
+			}
+			if row.Index != -1 {
+				if row.Index != drawn {
+					t.Fatalf("incorrect image row: %+v", row)
+				}
+				drawn++
+			}
+		}
+		if rows[0].Genus != "Bacteroides" || rows[0].Scaled != 0.5 || rows[0].Status != ROW_DRAWN {
+			t.Fatalf("incorrect row: %+v", rows[0])
+		}
+		if rows[1].Genus != "Streptococcus" || rows[1].Status != ROW_MISSING {
+			t.Fatalf("incorrect row: %+v", rows[1])
+		}
+		if rows[3].Genus != "" || rows[3].Status != ROW_PADDING {
+			t.Fatalf("incorrect row: %+v", rows[3])
+		}
+	}
+	if _, err := table.GetRows(1, css, false); err == nil {
+		t.Fatal("sample index out of range should raise an error")
+	}
+}
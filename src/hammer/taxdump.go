@@ -0,0 +1,132 @@
+package hammer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// the NCBI taxdump files used to look up taxids
+const (
+	TAXDUMP_NAMES = "names.dmp"
+	TAXDUMP_NODES = "nodes.dmp"
+)
+
+// the names.dmp name classes that are used to find a taxid
+var taxdumpNameClasses = map[string]bool{
+	"scientific name":  true,
+	"synonym":          true,
+	"equivalent name":  true,
+	"genbank synonym":  true,
+	"includes":         true,
+	"misspelling":      true,
+	"misnomer":         true,
+	"anamorph":         true,
+	"teleomorph":       true,
+	"genbank anamorph": true,
+}
+
+// Taxdump holds the genus level names from an NCBI taxdump (names.dmp and nodes.dmp), keyed by canonical name
+type Taxdump struct {
+	taxids map[string]int // canonical name -> taxid (-1 if the name is ambiguous)
+}
+
+// GetTaxid is a method to get the taxid of a genus name, returning false if the name isn't found or is ambiguous
+func (taxdump *Taxdump) GetTaxid(name string) (int, bool) {
+	taxid, ok := taxdump.taxids[CanonicalName(name)]
+	if !ok || taxid == -1 {
+		return 0, false
+	}
+	return taxid, true
+}
+
+// LoadTaxdump reads the names.dmp and nodes.dmp files from an NCBI taxdump directory, keeping only genus level names
+func LoadTaxdump(dir string) (*Taxdump, error) {
+	nodes, err := os.Open(filepath.Join(dir, TAXDUMP_NODES))
+	if err != nil {
+		return nil, err
+	}
+	defer nodes.Close()
+	names, err := os.Open(filepath.Join(dir, TAXDUMP_NAMES))
+	if err != nil {
+		return nil, err
+	}
+	defer names.Close()
+	return ReadTaxdump(names, nodes)
+}
+
+// ReadTaxdump reads NCBI taxdump names and nodes from a pair of io.Readers, keeping only genus level names
+// scientific names take priority over synonyms, and names shared by more than one genus are treated as ambiguous
+func ReadTaxdump(names, nodes io.Reader) (*Taxdump, error) {
+	// get the genus level taxids from the nodes
+	genera := make(map[int]bool)
+	err := readDmp(nodes, TAXDUMP_NODES, func(fields []string) error {
+		if len(fields) < 3 {
+			return fmt.Errorf("expected at least 3 fields")
+		}
+		if strings.TrimSpace(fields[2]) != "genus" {
+			return nil
+		}
+		taxid, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil {
+			return err
+		}
+		genera[taxid] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// get the names for the genera
+	taxdump := &Taxdump{taxids: make(map[string]int)}
+	scientific := make(map[string]bool)
+	err = readDmp(names, TAXDUMP_NAMES, func(fields []string) error {
+		if len(fields) < 4 {
+			return fmt.Errorf("expected at least 4 fields")
+		}
+		taxid, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil {
+			return err
+		}
+		class := strings.TrimSpace(fields[3])
+		if !genera[taxid] || !taxdumpNameClasses[class] {
+			return nil
+		}
+		name := CanonicalName(fields[1])
+		isScientific := class == "scientific name"
+		prev, ok := taxdump.taxids[name]
+		switch {
+		case !ok, isScientific && !scientific[name]:
+			taxdump.taxids[name] = taxid
+			scientific[name] = isScientific
+		case prev != taxid && isScientific == scientific[name]:
+			taxdump.taxids[name] = -1
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return taxdump, nil
+}
+
+// readDmp reads a taxdump .dmp file (fields separated by \t|\t, lines ending \t|), calling fn with the fields of each line
+func readDmp(r io.Reader, name string, fn func(fields []string) error) error {
+	scanner := bufio.NewScanner(r)
+	var lineNum int
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSuffix(strings.TrimRight(scanner.Text(), "\r"), "\t|")
+		if line == "" {
+			continue
+		}
+		if err := fn(strings.Split(line, "\t|\t")); err != nil {
+			return &ParseError{File: name, Line: lineNum, Err: err}
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,40 @@
+package hammer
+
+import (
+	"strings"
+	"testing"
+)
+
+var (
+	testNodes = "838\t|\t171552\t|\tgenus\t|\n1485\t|\t31979\t|\tgenus\t|\n2\t|\t131567\t|\tsuperkingdom\t|\n9999\t|\t1\t|\tgenus\t|\n"
+	testNames = "838\t|\tPrevotella\t|\t\t|\tscientific name\t|\n838\t|\tXylanibacter\t|\t\t|\tsynonym\t|\n1485\t|\tClostridium\t|\tClostridium <bacteria>\t|\tscientific name\t|\n9999\t|\tClostridium\t|\tClostridium <plant>\t|\tscientific name\t|\n2\t|\tBacteria\t|\t\t|\tscientific name\t|\n"
+)
+
+// test the taxdump reader and matching on taxids
+func TestTaxdump(t *testing.T) {
+	taxdump, err := ReadTaxdump(strings.NewReader(testNames), strings.NewReader(testNodes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taxid, ok := taxdump.GetTaxid("g__[Prevotella]"); !ok || taxid != 838 {
+		t.Fatal("could not get taxid for genus")
+	}
+	if taxid, ok := taxdump.GetTaxid("Xylanibacter"); !ok || taxid != 838 {
+		t.Fatal("could not get taxid for synonym")
+	}
+	if _, ok := taxdump.GetTaxid("Clostridium"); ok {
+		t.Fatal("ambiguous names should not have a taxid")
+	}
+	if _, ok := taxdump.GetTaxid("Bacteria"); ok {
+		t.Fatal("only genus level names should have a taxid")
+	}
+	matcher := NewNameMatcher()
+	matcher.SetTaxdump(taxdump)
+	matcher.SetReference([]string{"Prevotella"})
+	if ref, ok := matcher.Match("Xylanibacter"); !ok || ref != "Prevotella" {
+		t.Fatal("names should be matched on taxid")
+	}
+	if _, err := ReadTaxdump(strings.NewReader("838\t|\n"), strings.NewReader(testNodes)); err == nil {
+		t.Fatal("a malformed names file should raise an error")
+	}
+}
@@ -0,0 +1,285 @@
+package hammer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Rank is a taxonomic rank in a lineage
+type Rank int
+
+// the ranks that are recorded for each lineage
+const (
+	DOMAIN Rank = iota
+	PHYLUM
+	CLASS
+	ORDER
+	FAMILY
+	GENUS
+	SPECIES
+	NUM_RANKS
+)
+
+// rankPrefixes maps the Greengenes/GTDB style rank prefixes (e.g. g__) to ranks
+var rankPrefixes = map[byte]Rank{
+	'k': DOMAIN,
+	'd': DOMAIN,
+	'p': PHYLUM,
+	'c': CLASS,
+	'o': ORDER,
+	'f': FAMILY,
+	'g': GENUS,
+	's': SPECIES,
+}
+
+// silvaPrefix matches the level prefixes used by older SILVA releases (e.g. D_5__)
+var silvaPrefix = regexp.MustCompile(`^D_(\d+)__`)
+
+// confidenceSuffix matches the bootstrap values that some classifiers append to each rank (e.g. Bacteroides(100))
+var confidenceSuffix = regexp.MustCompile(`\(\d+(\.\d+)?\)$`)
+
+// the header names used for the taxonomy and confidence columns of a taxonomy file
+var (
+	taxonHeaders      = []string{"taxon", "taxonomy", "consensus lineage", "consensuslineage", "lineage"}
+	confidenceHeaders = []string{"confidence", "consensus", "bootstrap"}
+	idHeaders         = []string{"feature id", "featureid", "#otu id", "otu id", "#otuid", "otuid", "otu", "id", "#id"}
+)
+
+// Lineage holds the name at each rank of a lineage (empty if the rank is not assigned)
+type Lineage [NUM_RANKS]string
+
+// Get is a method to get the name at a given rank
+func (lineage Lineage) Get(rank Rank) string {
+	if rank < 0 || rank >= NUM_RANKS {
+		return ""
+	}
+	return lineage[rank]
+}
+
+// Depth is a method to get the deepest rank that is assigned in a lineage, returning -1 if no rank is assigned
+func (lineage Lineage) Depth() Rank {
+	for rank := NUM_RANKS - 1; rank >= 0; rank-- {
+		if lineage[rank] != "" {
+			return rank
+		}
+	}
+	return -1
+}
+
+// ParseLineage parses a lineage string into its ranks
+// it handles Greengenes (k__...; g__...), GTDB and QIIME2 (d__...;s__...), older SILVA (D_0__...;D_5__...) and
+// unprefixed SILVA and mothur style (Bacteria;Firmicutes;...) lineages, along with per-rank confidence values (e.g. Bacteroides(100))
+func ParseLineage(lineageString string) Lineage {
+	var lineage Lineage
+	fields := strings.Split(lineageString, ";")
+	// remove the whitespace and confidences, and drop any QIIME Root
+	elements := make([]string, 0, len(fields))
+	for i, field := range fields {
+		field = strings.TrimSpace(confidenceSuffix.ReplaceAllString(strings.TrimSpace(field), ""))
+		if field == "" || (i == 0 && strings.EqualFold(field, "root")) {
+			continue
+		}
+		// mothur marks ranks it couldn't assign as unclassified (e.g. Lachnospiraceae_unclassified)
+		if lower := strings.ToLower(field); lower == "unclassified" || strings.HasSuffix(lower, "_unclassified") {
+			field = ""
+		}
+		elements = append(elements, field)
+	}
+	// use the prefixes if there are any, otherwise assign ranks by position
+	var prefixed bool
+	for _, element := range elements {
+		if rank, name, ok := splitRankPrefix(element); ok {
+			prefixed = true
+			if rank < NUM_RANKS {
+				lineage[rank] = name
+			}
+		}
+	}
+	if prefixed {
+		return lineage
+	}
+	for i, element := range elements {
+		if i == int(NUM_RANKS) {
+			break
+		}
+		lineage[i] = element
+	}
+	return lineage
+}
+
+// splitRankPrefix splits a lineage element into its rank and name, returning false if it has no rank prefix
+func splitRankPrefix(element string) (Rank, string, bool) {
+	if match := silvaPrefix.FindStringSubmatch(element); match != nil {
+		level, err := strconv.Atoi(match[1])
+		if err != nil {
+			return 0, "", false
+		}
+		return Rank(level), strings.TrimSpace(element[len(match[0]):]), true
+	}
+	if len(element) >= 3 && element[1:3] == "__" {
+		if rank, ok := rankPrefixes[element[0]]; ok {
+			return rank, strings.TrimSpace(element[3:]), true
+		}
+	}
+	return 0, "", false
+}
+
+// GetGenus returns the genus from a lineage string (e.g. Root;p__Firmicutes;...;g__Streptococcus)
+// an empty string is returned if the lineage has no genus
+func GetGenus(lineage string) string {
+	return ParseLineage(lineage).Get(GENUS)
+}
+
+// Taxonomy assigns a lineage to each OTU (or feature) id, for tables that don't have a taxonomy column
+type Taxonomy map[string]Lineage
+
+// GetGenus is a method to get the genus assigned to an OTU id, returning an empty string if there isn't one
+func (taxonomy Taxonomy) GetGenus(id string) string {
+	return taxonomy[id].Get(GENUS)
+}
+
+// LoadTaxonomy reads a taxonomy file (which can be gzip, bzip2 or zstd compressed), see ReadTaxonomy
+func LoadTaxonomy(path string, minConfidence float64) (Taxonomy, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	return ReadTaxonomy(fh, path, minConfidence)
+}
+
+// ReadTaxonomy reads a taxonomy of OTU ids and lineages from an io.Reader
+// this can be a QIIME2 taxonomy.tsv (Feature ID, Taxon, Confidence) or taxonomy .qza artifact, a headerless id/lineage file (SILVA, Greengenes, GTDB),
+// a mothur .cons.taxonomy or a DADA2 assignTaxonomy table (sequence, then a column per rank)
+// lines starting with # are skipped, and lineages with a confidence below minConfidence are not assigned a genus
+// name is used when reporting errors
+func ReadTaxonomy(r io.Reader, name string, minConfidence float64) (Taxonomy, error) {
+	dr, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	defer dr.Close()
+	br := bufio.NewReader(dr)
+	// QIIME2 artifacts are zip files, with the taxonomy in the data directory
+	if isZip(br) {
+		data, err := ioutil.ReadAll(br)
+		if err != nil {
+			return nil, err
+		}
+		tsv, tsvName, err := openArtifactData(data, name, "taxonomy.tsv")
+		if err != nil {
+			return nil, err
+		}
+		defer tsv.Close()
+		return readTaxonomyTable(tsv, tsvName, minConfidence)
+	}
+	return readTaxonomyTable(br, name, minConfidence)
+}
+
+// readTaxonomyTable reads a taxonomy from a tab separated id/lineage table, or a comma separated DADA2 table
+func readTaxonomyTable(r io.Reader, name string, minConfidence float64) (Taxonomy, error) {
+	taxonomy := make(Taxonomy)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var lineNum int
+	taxonColumn, confidenceColumn := -1, -1
+	var rankColumns map[int]Rank
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		// DADA2 tables are comma separated, with a column for each rank
+		if taxonColumn == -1 && rankColumns == nil && !strings.Contains(line, "\t") {
+			if header, err := splitCSVLine(line); err == nil {
+				rankColumns = findRankColumns(header)
+				if len(rankColumns) != 0 {
+					continue
+				}
+				rankColumns = nil
+			}
+		}
+		var fields []string
+		var lineage Lineage
+		if rankColumns != nil {
+			var err error
+			if fields, err = splitCSVLine(line); err != nil {
+				return nil, &ParseError{File: name, Line: lineNum, Err: err}
+			}
+			for column, rank := range rankColumns {
+				if column >= len(fields) {
+					return nil, &ParseError{File: name, Line: lineNum, Err: fmt.Errorf("expected a sequence and %d ranks", len(rankColumns))}
+				}
+				if value := strings.TrimSpace(fields[column]); value != "NA" {
+					lineage[rank] = value
+				}
+			}
+		} else {
+			fields = strings.Split(line, "\t")
+			// the first line can be a header, otherwise the lineage is in the second column and any confidence in the third
+			if taxonColumn == -1 && checkHeader(fields[0], idHeaders) {
+				taxonColumn, confidenceColumn = findColumn(fields, taxonHeaders, 1), findColumn(fields, confidenceHeaders, -1)
+				continue
+			}
+			if line[0] == '#' {
+				continue
+			}
+			if taxonColumn == -1 {
+				taxonColumn, confidenceColumn = 1, 2
+			}
+			if len(fields) <= taxonColumn {
+				return nil, &ParseError{File: name, Line: lineNum, Err: fmt.Errorf("expected an id and a lineage")}
+			}
+			lineage = ParseLineage(fields[taxonColumn])
+			// check the confidence if there is one
+			if confidenceColumn != -1 && confidenceColumn < len(fields) && confidenceColumn != taxonColumn {
+				confidence, err := strconv.ParseFloat(strings.TrimSpace(fields[confidenceColumn]), 64)
+				if err == nil && confidence < minConfidence {
+					lineage[GENUS], lineage[SPECIES] = "", ""
+				}
+			}
+		}
+		id := strings.TrimSpace(fields[0])
+		if _, ok := taxonomy[id]; ok {
+			return nil, &ParseError{File: name, Line: lineNum, Err: fmt.Errorf("duplicate id: %v", id)}
+		}
+		taxonomy[id] = lineage
+	}
+	return taxonomy, scanner.Err()
+}
+
+// findRankColumns returns the columns of a header that are named after ranks (e.g. Kingdom, Genus)
+func findRankColumns(header []string) map[int]Rank {
+	rankColumns := make(map[int]Rank)
+	for i, column := range header {
+		if rank, ok := rankNames[strings.ToLower(strings.TrimSpace(column))]; ok && i != 0 {
+			rankColumns[i] = rank
+		}
+	}
+	return rankColumns
+}
+
+// splitCSVLine splits a single line of comma separated values
+func splitCSVLine(line string) ([]string, error) {
+	r := csv.NewReader(strings.NewReader(line))
+	r.LazyQuotes = true
+	return r.Read()
+}
+
+// findColumn returns the index of the first header matching one of the names, or def if there isn't one
+func findColumn(header []string, names []string, def int) int {
+	for i, column := range header {
+		if checkHeader(column, names) {
+			return i
+		}
+	}
+	return def
+}
@@ -0,0 +1,59 @@
+package hammer
+
+import (
+	"strings"
+	"testing"
+)
+
+// test the lineage parser with the different taxonomy styles
+func TestParseLineage(t *testing.T) {
+	tests := map[string]string{
+		"Root;p__Firmicutes;c__Bacilli;o__Lactobacillales;f__Streptococcaceae;g__Streptococcus":                         "Streptococcus",
+		"k__Bacteria; p__Bacteroidetes; c__Bacteroidia; o__Bacteroidales; f__Prevotellaceae; g__Prevotella; s__copri":   "Prevotella",
+		"d__Bacteria;p__Firmicutes;c__Bacilli;o__Lactobacillales;f__Streptococcaceae;g__Streptococcus;s__Streptococcus": "Streptococcus",
+		"D_0__Bacteria;D_1__Firmicutes;D_2__Bacilli;D_3__Lactobacillales;D_4__Streptococcaceae;D_5__Streptococcus":      "Streptococcus",
+		"Bacteria;Firmicutes;Bacilli;Lactobacillales;Streptococcaceae;Streptococcus;":                                   "Streptococcus",
+		"Bacteria(100);Bacteroidetes(100);Bacteroidia(100);Bacteroidales(100);Bacteroidaceae(99);Bacteroides(98);":      "Bacteroides",
+		"k__Bacteria; p__Firmicutes; c__Clostridia; o__Clostridiales; f__; g__; s__":                                    "",
+		"Unassigned": "",
+	}
+	for lineage, genus := range tests {
+		if got := GetGenus(lineage); got != genus {
+			t.Fatalf("wrong genus for %v: got %q, expected %q", lineage, got, genus)
+		}
+	}
+	if species := ParseLineage("d__Bacteria;g__Streptococcus;s__Streptococcus pneumoniae").Get(SPECIES); species != "Streptococcus pneumoniae" {
+		t.Fatalf("wrong species: %v", species)
+	}
+}
+
+// test the taxonomy reader
+func TestReadTaxonomy(t *testing.T) {
+	// QIIME2 taxonomy.tsv
+	data := "Feature ID\tTaxon\tConfidence\r\nASV_1\td__Bacteria; p__Bacteroidota; g__Bacteroides\t0.99\r\nASV_2\td__Bacteria; p__Bacteroidota\t0.9\r\nASV_3\td__Bacteria; g__Prevotella\t0.5\r\n"
+	taxonomy, err := ReadTaxonomy(strings.NewReader(data), "taxonomy.tsv", 0.7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taxonomy.GetGenus("ASV_1") != "Bacteroides" || taxonomy.GetGenus("ASV_2") != "" {
+		t.Fatalf("taxonomy not parsed correctly: %v", taxonomy)
+	}
+	if taxonomy.GetGenus("ASV_3") != "" {
+		t.Fatal("low confidence assignments should not have a genus")
+	}
+	// headerless SILVA style file
+	data = "# SILVA\nAB001\tBacteria;Firmicutes;Bacilli;Lactobacillales;Streptococcaceae;Streptococcus;\n"
+	taxonomy, err = ReadTaxonomy(strings.NewReader(data), "silva.txt", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taxonomy.GetGenus("AB001") != "Streptococcus" {
+		t.Fatalf("taxonomy not parsed correctly: %v", taxonomy)
+	}
+	if _, err := ReadTaxonomy(strings.NewReader("ASV_1\n"), "taxonomy.tsv", 0); err == nil {
+		t.Fatal("a line without a lineage should raise an error")
+	}
+	if _, err := ReadTaxonomy(strings.NewReader("ASV_1\tg__A\nASV_1\tg__B\n"), "taxonomy.tsv", 0); err == nil {
+		t.Fatal("a duplicate id should raise an error")
+	}
+}
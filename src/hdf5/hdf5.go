@@ -0,0 +1,79 @@
+// hdf5 contains the types/methods/functions to write and read a subset of the HDF5 file format (enough for tensors and BIOM 2.x tables)
+//
+// files are written with the version 0 superblock, version 1 object headers and symbol table groups, which are readable by all versions of the HDF5 library
+// the reader supports the same structures, plus the deflate, shuffle and fletcher32 filters, fixed and variable length strings and integer and float datasets
+
+package hdf5
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// signature is the start of the HDF5 superblock
+var signature = []byte("\x89HDF\r\n\x1a\n")
+
+// undefinedAddress marks an address that has not been allocated
+const undefinedAddress = ^uint64(0)
+
+// the object header message types used
+const (
+	msgNil          = 0x00
+	msgDataspace    = 0x01
+	msgDatatype     = 0x03
+	msgFillValue    = 0x05
+	msgLayout       = 0x08
+	msgFilters      = 0x0B
+	msgContinuation = 0x10
+	msgSymbolTable  = 0x11
+)
+
+// the datatype classes used
+const (
+	classFixed  = 0
+	classFloat  = 1
+	classString = 3
+	classVlen   = 9
+)
+
+// the storage layout classes
+const (
+	layoutCompact    = 0
+	layoutContiguous = 1
+	layoutChunked    = 2
+)
+
+// the filters that can be read
+const (
+	filterDeflate    = 1
+	filterShuffle    = 2
+	filterFletcher32 = 3
+)
+
+// the B-tree node types and the K values written to the superblock
+const (
+	btreeGroup     = 0
+	btreeChunk     = 1
+	groupLeafK     = 4
+	groupInternalK = 16
+	chunkK         = 32
+)
+
+// le is the byte order used for all the file metadata
+var le = binary.LittleEndian
+
+// splitPath splits an HDF5 path into its names, ignoring leading, trailing and repeated slashes
+func splitPath(path string) []string {
+	var names []string
+	for _, name := range strings.Split(path, "/") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// pad8 rounds n up to a multiple of 8
+func pad8(n int) int {
+	return (n + 7) &^ 7
+}
@@ -0,0 +1,770 @@
+package hdf5
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+)
+
+// File is an HDF5 file opened for reading
+type File struct {
+	r          io.ReaderAt
+	size       int64
+	base       uint64
+	offsetSize int
+	lengthSize int
+	root       uint64
+	heaps      map[uint64]map[uint16][]byte
+}
+
+// Dataset is a dataset of an HDF5 file, the values are read when they are asked for
+type Dataset struct {
+	Path    string
+	Shape   []int
+	file    *File
+	dtype   datatype
+	layout  []byte
+	filters []filter
+}
+
+// datatype is a decoded datatype
+type datatype struct {
+	class     int
+	size      int
+	signed    bool
+	bigEndian bool
+	vlenText  bool
+	padding   int
+}
+
+// filter is a filter in a dataset's pipeline
+type filter struct {
+	id       uint16
+	optional bool
+	values   []uint32
+}
+
+// Open reads the superblock of an HDF5 file, which can follow a user block of 512, 1024, 2048... bytes
+// superblock versions 0 and 1 are supported, these are written by the HDF5 library unless the latest file format is asked for
+func Open(r io.ReaderAt, size int64) (*File, error) {
+	for offset := int64(0); offset+int64(len(signature)) <= size; offset = nextUserBlock(offset) {
+		b := make([]byte, len(signature))
+		if _, err := r.ReadAt(b, offset); err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(b, signature) {
+			continue
+		}
+		return openSuperblock(r, size, offset)
+	}
+	return nil, fmt.Errorf("not an HDF5 file")
+}
+
+// nextUserBlock returns the next place a superblock can start
+func nextUserBlock(offset int64) int64 {
+	if offset == 0 {
+		return 512
+	}
+	return offset * 2
+}
+
+// openSuperblock reads the superblock at offset
+func openSuperblock(r io.ReaderAt, size, offset int64) (*File, error) {
+	b := make([]byte, 24)
+	if _, err := r.ReadAt(b, offset); err != nil {
+		return nil, fmt.Errorf("could not read HDF5 superblock: %v", err)
+	}
+	version := b[8]
+	if version > 1 {
+		return nil, fmt.Errorf("HDF5 superblock version %d is not supported (only files written with the earliest file format can be read)", version)
+	}
+	file := &File{r: r, size: size, offsetSize: int(b[13]), lengthSize: int(b[14]), heaps: make(map[uint64]map[uint16][]byte)}
+	for _, n := range []int{file.offsetSize, file.lengthSize} {
+		if n != 2 && n != 4 && n != 8 {
+			return nil, fmt.Errorf("invalid HDF5 address size: %d", n)
+		}
+	}
+	// skip the indexed storage K of version 1
+	pos := int64(24)
+	if version == 1 {
+		pos += 4
+	}
+	b = make([]byte, 4*file.offsetSize+2*file.offsetSize+8+16)
+	if _, err := r.ReadAt(b, offset+pos); err != nil {
+		return nil, fmt.Errorf("could not read HDF5 superblock: %v", err)
+	}
+	file.base = file.uint(b, file.offsetSize)
+	// the root symbol table entry follows the base, free space, end of file and driver addresses
+	file.root = file.uint(b[4*file.offsetSize+file.offsetSize:], file.offsetSize)
+	return file, nil
+}
+
+// uint decodes a little endian unsigned integer of n bytes
+func (file *File) uint(b []byte, n int) uint64 {
+	switch n {
+	case 1:
+		return uint64(b[0])
+	case 2:
+		return uint64(le.Uint16(b))
+	case 4:
+		return uint64(le.Uint32(b))
+	}
+	return le.Uint64(b)
+}
+
+// undefined checks if an address is the undefined address
+func (file *File) undefined(addr uint64) bool {
+	return addr == undefinedAddress>>(64-8*uint(file.offsetSize))
+}
+
+// read is a method to read n bytes at an address
+func (file *File) read(addr uint64, n uint64) ([]byte, error) {
+	if file.undefined(addr) || addr+file.base > uint64(file.size) || n > uint64(file.size)-addr-file.base {
+		return nil, fmt.Errorf("HDF5 read of %d bytes at %d is outside the file", n, addr)
+	}
+	b := make([]byte, n)
+	if _, err := file.r.ReadAt(b, int64(addr+file.base)); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// readHeader is a method to read the messages of a version 1 object header, following any continuation messages
+func (file *File) readHeader(addr uint64) ([]message, error) {
+	prefix, err := file.read(addr, 16)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(prefix[:4], []byte("OHDR")) || prefix[0] != 1 {
+		return nil, fmt.Errorf("HDF5 object header at %d is not version 1, which is the only version supported", addr)
+	}
+	remaining := int(le.Uint16(prefix[2:]))
+	type block struct{ addr, size uint64 }
+	blocks := []block{{addr + 16, uint64(le.Uint32(prefix[8:]))}}
+	var messages []message
+	for len(blocks) != 0 && remaining > 0 {
+		b, err := file.read(blocks[0].addr, blocks[0].size)
+		if err != nil {
+			return nil, err
+		}
+		blocks = blocks[1:]
+		for pos := 0; pos+8 <= len(b) && remaining > 0; remaining-- {
+			msg := message{kind: le.Uint16(b[pos:]), flags: b[pos+4]}
+			size := int(le.Uint16(b[pos+2:]))
+			pos += 8
+			if pos+size > len(b) {
+				return nil, fmt.Errorf("HDF5 object header message at %d runs past its block", addr)
+			}
+			msg.data = b[pos : pos+size]
+			pos += size
+			switch msg.kind {
+			case msgNil:
+			case msgContinuation:
+				if len(msg.data) < file.offsetSize+file.lengthSize {
+					return nil, fmt.Errorf("invalid HDF5 continuation message")
+				}
+				blocks = append(blocks, block{file.uint(msg.data, file.offsetSize), file.uint(msg.data[file.offsetSize:], file.lengthSize)})
+			default:
+				messages = append(messages, msg)
+			}
+		}
+	}
+	return messages, nil
+}
+
+// lookup is a method to get the object header address of a path
+func (file *File) lookup(path string) (uint64, error) {
+	addr := file.root
+	for _, name := range splitPath(path) {
+		children, err := file.children(addr)
+		if err != nil {
+			return 0, fmt.Errorf("%v: %v", path, err)
+		}
+		var ok bool
+		if addr, ok = children[name]; !ok {
+			return 0, fmt.Errorf("not found in HDF5 file: %v", path)
+		}
+	}
+	return addr, nil
+}
+
+// children is a method to get the object header addresses of the members of a group
+func (file *File) children(addr uint64) (map[string]uint64, error) {
+	messages, err := file.readHeader(addr)
+	if err != nil {
+		return nil, err
+	}
+	for _, msg := range messages {
+		if msg.kind != msgSymbolTable {
+			continue
+		}
+		if len(msg.data) < 2*file.offsetSize {
+			return nil, fmt.Errorf("invalid HDF5 symbol table message")
+		}
+		heap, err := file.readLocalHeap(file.uint(msg.data[file.offsetSize:], file.offsetSize))
+		if err != nil {
+			return nil, err
+		}
+		children := make(map[string]uint64)
+		return children, file.walkGroup(file.uint(msg.data, file.offsetSize), heap, children, 0)
+	}
+	return nil, fmt.Errorf("not a group, or a group without a symbol table (only groups written with the earliest file format can be read)")
+}
+
+// readLocalHeap is a method to read the data segment of a local heap
+func (file *File) readLocalHeap(addr uint64) ([]byte, error) {
+	b, err := file.read(addr, uint64(8+2*file.lengthSize+file.offsetSize))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(b[:4], []byte("HEAP")) {
+		return nil, fmt.Errorf("invalid HDF5 local heap at %d", addr)
+	}
+	size := file.uint(b[8:], file.lengthSize)
+	return file.read(file.uint(b[8+2*file.lengthSize:], file.offsetSize), size)
+}
+
+// readNode is a method to read a version 1 B-tree node, returning its level, keys and children
+func (file *File) readNode(addr uint64, kind byte, keySize int) (int, [][]byte, []uint64, error) {
+	b, err := file.read(addr, uint64(8+2*file.offsetSize))
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if !bytes.Equal(b[:4], []byte("TREE")) || b[4] != kind {
+		return 0, nil, nil, fmt.Errorf("invalid HDF5 B-tree node at %d", addr)
+	}
+	level, entries := int(b[5]), int(le.Uint16(b[6:]))
+	b, err = file.read(addr+uint64(len(b)), uint64(entries*(keySize+file.offsetSize)+keySize))
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	keys := make([][]byte, entries+1)
+	children := make([]uint64, entries)
+	pos := 0
+	for i := 0; i < entries; i++ {
+		keys[i] = b[pos : pos+keySize]
+		children[i] = file.uint(b[pos+keySize:], file.offsetSize)
+		pos += keySize + file.offsetSize
+	}
+	keys[entries] = b[pos : pos+keySize]
+	return level, keys, children, nil
+}
+
+// walkGroup is a method to collect the entries of the symbol table nodes under a group B-tree node
+func (file *File) walkGroup(addr uint64, heap []byte, children map[string]uint64, depth int) error {
+	if depth > 64 {
+		return fmt.Errorf("HDF5 group B-tree is too deep")
+	}
+	level, _, nodes, err := file.readNode(addr, btreeGroup, file.lengthSize)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if level > 0 {
+			if err := file.walkGroup(node, heap, children, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+		b, err := file.read(node, 8)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(b[:4], []byte("SNOD")) {
+			return fmt.Errorf("invalid HDF5 symbol table node at %d", node)
+		}
+		entrySize := 2*file.offsetSize + 24
+		b, err = file.read(node+8, uint64(int(le.Uint16(b[6:]))*entrySize))
+		if err != nil {
+			return err
+		}
+		for pos := 0; pos < len(b); pos += entrySize {
+			offset := file.uint(b[pos:], file.offsetSize)
+			if offset >= uint64(len(heap)) {
+				return fmt.Errorf("HDF5 symbol name is outside the local heap")
+			}
+			name := heap[offset:]
+			if end := bytes.IndexByte(name, 0); end != -1 {
+				name = name[:end]
+			}
+			children[string(name)] = file.uint(b[pos+file.offsetSize:], file.offsetSize)
+		}
+	}
+	return nil
+}
+
+// List is a method to get the sorted names of the members of a group
+func (file *File) List(path string) ([]string, error) {
+	addr, err := file.lookup(path)
+	if err != nil {
+		return nil, err
+	}
+	children, err := file.children(addr)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	names := make([]string, 0, len(children))
+	for name := range children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Dataset is a method to open a dataset, its values are read by the Dataset methods
+func (file *File) Dataset(path string) (*Dataset, error) {
+	addr, err := file.lookup(path)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := file.readHeader(addr)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	ds := &Dataset{Path: path, file: file}
+	found := 0
+	for _, msg := range messages {
+		switch msg.kind {
+		case msgDataspace:
+			ds.Shape, err = file.decodeDataspace(msg.data)
+			found |= 1
+		case msgDatatype:
+			ds.dtype, _, err = decodeDatatype(msg.data)
+			found |= 2
+		case msgLayout:
+			ds.layout = msg.data
+			found |= 4
+		case msgFilters:
+			ds.filters, err = decodeFilters(msg.data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", path, err)
+		}
+	}
+	if found != 7 {
+		return nil, fmt.Errorf("not an HDF5 dataset: %v", path)
+	}
+	return ds, nil
+}
+
+// decodeDataspace is a method to get the shape from a dataspace message
+func (file *File) decodeDataspace(b []byte) ([]int, error) {
+	if len(b) < 8 || b[0] < 1 || b[0] > 2 {
+		return nil, fmt.Errorf("unsupported dataspace")
+	}
+	rank := int(b[1])
+	pos := 8
+	if b[0] == 2 {
+		pos = 4
+		if b[3] == 2 {
+			return nil, fmt.Errorf("null dataspaces are not supported")
+		}
+	}
+	if len(b) < pos+rank*file.lengthSize {
+		return nil, fmt.Errorf("invalid dataspace")
+	}
+	shape := make([]int, rank)
+	for i := range shape {
+		dim := file.uint(b[pos+i*file.lengthSize:], file.lengthSize)
+		if dim > math.MaxInt32 {
+			return nil, fmt.Errorf("dataspace dimension is too large: %d", dim)
+		}
+		shape[i] = int(dim)
+	}
+	return shape, nil
+}
+
+// decodeDatatype decodes a datatype message, returning the datatype and the length of the encoding
+func decodeDatatype(b []byte) (datatype, int, error) {
+	if len(b) < 8 {
+		return datatype{}, 0, fmt.Errorf("invalid datatype")
+	}
+	dtype := datatype{class: int(b[0] & 0x0f), size: int(le.Uint32(b[4:]))}
+	switch dtype.class {
+	case classFixed:
+		dtype.bigEndian, dtype.signed = b[1]&0x01 != 0, b[1]&0x08 != 0
+		if dtype.size != 1 && dtype.size != 2 && dtype.size != 4 && dtype.size != 8 {
+			return dtype, 0, fmt.Errorf("unsupported integer size: %d", dtype.size)
+		}
+		return dtype, 12, nil
+	case classFloat:
+		dtype.bigEndian = b[1]&0x01 != 0
+		if b[1]&0x40 != 0 || (dtype.size != 4 && dtype.size != 8) {
+			return dtype, 0, fmt.Errorf("unsupported float type")
+		}
+		return dtype, 20, nil
+	case classString:
+		dtype.padding = int(b[1] & 0x0f)
+		return dtype, 8, nil
+	case classVlen:
+		base, n, err := decodeDatatype(b[8:])
+		if err != nil {
+			return dtype, 0, err
+		}
+		if b[1]&0x0f != 1 || base.size != 1 {
+			return dtype, 0, fmt.Errorf("only variable length strings are supported, not variable length sequences")
+		}
+		dtype.vlenText, dtype.padding = true, int(b[1]>>4)
+		return dtype, 8 + n, nil
+	}
+	return dtype, 0, fmt.Errorf("unsupported datatype class: %d", dtype.class)
+}
+
+// decodeFilters decodes a filter pipeline message
+func decodeFilters(b []byte) ([]filter, error) {
+	if len(b) < 2 || b[0] < 1 || b[0] > 2 {
+		return nil, fmt.Errorf("unsupported filter pipeline")
+	}
+	version, n := b[0], int(b[1])
+	pos := 8
+	if version == 2 {
+		pos = 2
+	}
+	filters := make([]filter, n)
+	for i := range filters {
+		if pos+2 > len(b) {
+			return nil, fmt.Errorf("invalid filter pipeline")
+		}
+		f := &filters[i]
+		f.id = le.Uint16(b[pos:])
+		pos += 2
+		nameLength := 0
+		if version == 1 || f.id >= 256 {
+			nameLength = int(le.Uint16(b[pos:]))
+			pos += 2
+		}
+		if pos+4 > len(b) {
+			return nil, fmt.Errorf("invalid filter pipeline")
+		}
+		f.optional = le.Uint16(b[pos:])&1 != 0
+		values := int(le.Uint16(b[pos+2:]))
+		pos += 4
+		if version == 1 {
+			nameLength = pad8(nameLength)
+		}
+		pos += nameLength
+		if pos+4*values > len(b) {
+			return nil, fmt.Errorf("invalid filter pipeline")
+		}
+		for j := 0; j < values; j++ {
+			f.values = append(f.values, le.Uint32(b[pos:]))
+			pos += 4
+		}
+		if version == 1 && values%2 == 1 {
+			pos += 4
+		}
+		if f.id != filterDeflate && f.id != filterShuffle && f.id != filterFletcher32 && !f.optional {
+			return nil, fmt.Errorf("unsupported filter: %d", f.id)
+		}
+	}
+	return filters, nil
+}
+
+// length returns the number of elements in the dataset
+func (ds *Dataset) length() int {
+	n := 1
+	for _, dim := range ds.Shape {
+		n *= dim
+	}
+	return n
+}
+
+// raw is a method to read the elements of the dataset, in row major order
+func (ds *Dataset) raw() ([]byte, error) {
+	file, b := ds.file, ds.layout
+	size := ds.length() * ds.dtype.size
+	if len(b) < 2 || b[0] != 3 {
+		return nil, fmt.Errorf("%v: only version 3 data layouts are supported", ds.Path)
+	}
+	switch b[1] {
+	case layoutCompact:
+		if len(b) < 4 || len(b) < 4+int(le.Uint16(b[2:])) || int(le.Uint16(b[2:])) < size {
+			return nil, fmt.Errorf("%v: invalid compact data", ds.Path)
+		}
+		return b[4 : 4+size], nil
+	case layoutContiguous:
+		if len(b) < 2+file.offsetSize+file.lengthSize {
+			return nil, fmt.Errorf("%v: invalid data layout", ds.Path)
+		}
+		addr := file.uint(b[2:], file.offsetSize)
+		if file.undefined(addr) {
+			return make([]byte, size), nil
+		}
+		if file.uint(b[2+file.offsetSize:], file.lengthSize) < uint64(size) {
+			return nil, fmt.Errorf("%v: contiguous data is smaller than the dataspace", ds.Path)
+		}
+		data, err := file.read(addr, uint64(size))
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", ds.Path, err)
+		}
+		return data, nil
+	case layoutChunked:
+		data, err := ds.readChunks(size)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", ds.Path, err)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("%v: unsupported data layout: %d", ds.Path, b[1])
+}
+
+// readChunks is a method to read the chunks of a dataset into a buffer of its elements
+func (ds *Dataset) readChunks(size int) ([]byte, error) {
+	file, b := ds.file, ds.layout
+	rank := len(ds.Shape)
+	if len(b) < 3 || int(b[2]) != rank+1 || len(b) < 3+file.offsetSize+4*(rank+1) {
+		return nil, fmt.Errorf("invalid chunked data layout")
+	}
+	pos := 3 + file.offsetSize
+	chunkDims := make([]int, rank)
+	chunkLength := int(le.Uint32(b[pos+4*rank:]))
+	if chunkLength != ds.dtype.size {
+		return nil, fmt.Errorf("chunk element size does not match the datatype")
+	}
+	for i := range chunkDims {
+		chunkDims[i] = int(le.Uint32(b[pos+4*i:]))
+		if chunkDims[i] < 1 {
+			return nil, fmt.Errorf("invalid chunk dimensions")
+		}
+		chunkLength *= chunkDims[i]
+	}
+	data := make([]byte, size)
+	return data, ds.walkChunks(file.uint(b[3:], file.offsetSize), data, chunkDims, chunkLength, 0)
+}
+
+// walkChunks is a method to read the chunks under a chunk B-tree node into the dataset data
+func (ds *Dataset) walkChunks(addr uint64, data []byte, chunkDims []int, chunkLength, depth int) error {
+	file := ds.file
+	if depth > 64 {
+		return fmt.Errorf("chunk B-tree is too deep")
+	}
+	if file.undefined(addr) {
+		return nil
+	}
+	rank := len(ds.Shape)
+	level, keys, children, err := file.readNode(addr, btreeChunk, 8+8*(rank+1))
+	if err != nil {
+		return err
+	}
+	offset := make([]int, rank)
+	for i, child := range children {
+		if level > 0 {
+			if err := ds.walkChunks(child, data, chunkDims, chunkLength, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+		key := keys[i]
+		for j := range offset {
+			o := le.Uint64(key[8+8*j:])
+			if o%uint64(chunkDims[j]) != 0 || o >= uint64(ds.Shape[j]) {
+				return fmt.Errorf("invalid chunk offset")
+			}
+			offset[j] = int(o)
+		}
+		chunk, err := file.read(child, uint64(le.Uint32(key)))
+		if err != nil {
+			return err
+		}
+		if chunk, err = ds.unfilter(chunk, le.Uint32(key[4:])); err != nil {
+			return err
+		}
+		if len(chunk) != chunkLength {
+			return fmt.Errorf("chunk has %d bytes, expected %d", len(chunk), chunkLength)
+		}
+		copyChunk(chunk, data, ds.Shape, chunkDims, offset, ds.dtype.size, false)
+	}
+	return nil
+}
+
+// unfilter is a method to reverse the filter pipeline on a chunk, mask has a bit set for each filter that was skipped
+func (ds *Dataset) unfilter(chunk []byte, mask uint32) ([]byte, error) {
+	for i := len(ds.filters) - 1; i >= 0; i-- {
+		if mask&(1<<uint(i)) != 0 {
+			continue
+		}
+		switch ds.filters[i].id {
+		case filterDeflate:
+			zr, err := zlib.NewReader(bytes.NewReader(chunk))
+			if err != nil {
+				return nil, fmt.Errorf("could not decompress chunk: %v", err)
+			}
+			if chunk, err = ioutil.ReadAll(zr); err != nil {
+				return nil, fmt.Errorf("could not decompress chunk: %v", err)
+			}
+		case filterShuffle:
+			chunk = unshuffle(chunk, ds.dtype.size)
+		case filterFletcher32:
+			if len(chunk) < 4 {
+				return nil, fmt.Errorf("chunk is too short for its checksum")
+			}
+			chunk = chunk[:len(chunk)-4]
+		default:
+			return nil, fmt.Errorf("chunk needs an unsupported filter: %d", ds.filters[i].id)
+		}
+	}
+	return chunk, nil
+}
+
+// unshuffle reverses the shuffle filter, which groups the nth byte of every element together
+func unshuffle(chunk []byte, size int) []byte {
+	n := len(chunk) / size
+	if size < 2 || n < 2 {
+		return chunk
+	}
+	out := make([]byte, len(chunk))
+	for i := 0; i < n; i++ {
+		for j := 0; j < size; j++ {
+			out[i*size+j] = chunk[j*n+i]
+		}
+	}
+	copy(out[n*size:], chunk[n*size:])
+	return out
+}
+
+// Floats is a method to read an integer or float dataset as float64 values
+func (ds *Dataset) Floats() ([]float64, error) {
+	if ds.dtype.class != classFixed && ds.dtype.class != classFloat {
+		return nil, fmt.Errorf("%v: not a numeric dataset", ds.Path)
+	}
+	data, err := ds.raw()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]float64, ds.length())
+	for i := range values {
+		values[i] = ds.dtype.float(data[i*ds.dtype.size:])
+	}
+	return values, nil
+}
+
+// Ints is a method to read an integer dataset as int64 values
+func (ds *Dataset) Ints() ([]int64, error) {
+	if ds.dtype.class != classFixed {
+		return nil, fmt.Errorf("%v: not an integer dataset", ds.Path)
+	}
+	data, err := ds.raw()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]int64, ds.length())
+	for i := range values {
+		values[i] = ds.dtype.int(data[i*ds.dtype.size:])
+	}
+	return values, nil
+}
+
+// Strings is a method to read a fixed or variable length string dataset
+func (ds *Dataset) Strings() ([]string, error) {
+	if ds.dtype.class != classString && !ds.dtype.vlenText {
+		return nil, fmt.Errorf("%v: not a string dataset", ds.Path)
+	}
+	data, err := ds.raw()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, ds.length())
+	file, size := ds.file, ds.dtype.size
+	for i := range values {
+		value := data[i*size : (i+1)*size]
+		if ds.dtype.vlenText {
+			if len(value) < 8+file.offsetSize {
+				return nil, fmt.Errorf("%v: invalid variable length string", ds.Path)
+			}
+			length := le.Uint32(value)
+			if length == 0 {
+				continue
+			}
+			if value, err = file.globalHeapObject(file.uint(value[4:], file.offsetSize), le.Uint32(value[4+file.offsetSize:])); err != nil {
+				return nil, fmt.Errorf("%v: %v", ds.Path, err)
+			}
+			if uint32(len(value)) < length {
+				return nil, fmt.Errorf("%v: variable length string is longer than its heap object", ds.Path)
+			}
+			value = value[:length]
+		}
+		// null terminated and null padded strings end at the first null, space padded strings lose their trailing spaces
+		if end := bytes.IndexByte(value, 0); end != -1 && ds.dtype.padding != 2 {
+			value = value[:end]
+		}
+		values[i] = string(value)
+		if ds.dtype.padding == 2 {
+			values[i] = strings.TrimRight(values[i], " ")
+		}
+	}
+	return values, nil
+}
+
+// globalHeapObject is a method to get an object from a global heap collection, each collection is read once
+func (file *File) globalHeapObject(addr uint64, index uint32) ([]byte, error) {
+	objects, ok := file.heaps[addr]
+	if !ok {
+		b, err := file.read(addr, uint64(8+file.lengthSize))
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(b[:4], []byte("GCOL")) {
+			return nil, fmt.Errorf("invalid global heap collection at %d", addr)
+		}
+		if b, err = file.read(addr, file.uint(b[8:], file.lengthSize)); err != nil {
+			return nil, err
+		}
+		objects = make(map[uint16][]byte)
+		headerSize := 8 + file.lengthSize
+		for pos := 8 + file.lengthSize; pos+headerSize <= len(b); {
+			id := le.Uint16(b[pos:])
+			if id == 0 {
+				break
+			}
+			size := file.uint(b[pos+8:], file.lengthSize)
+			pos += headerSize
+			if size > uint64(len(b)-pos) {
+				return nil, fmt.Errorf("global heap object runs past its collection at %d", addr)
+			}
+			objects[id] = b[pos : pos+int(size)]
+			pos += pad8(int(size))
+		}
+		file.heaps[addr] = objects
+	}
+	object, ok := objects[uint16(index)]
+	if !ok || index > math.MaxUint16 {
+		return nil, fmt.Errorf("global heap object %d not found in the collection at %d", index, addr)
+	}
+	return object, nil
+}
+
+// int decodes an integer element
+func (dtype datatype) int(b []byte) int64 {
+	var u uint64
+	for i := 0; i < dtype.size; i++ {
+		j := i
+		if dtype.bigEndian {
+			j = dtype.size - 1 - i
+		}
+		u |= uint64(b[j]) << (8 * uint(i))
+	}
+	if dtype.signed && dtype.size < 8 && u&(1<<(8*uint(dtype.size)-1)) != 0 {
+		u |= ^uint64(0) << (8 * uint(dtype.size))
+	}
+	return int64(u)
+}
+
+// float decodes an integer or float element as a float64
+func (dtype datatype) float(b []byte) float64 {
+	if dtype.class == classFixed {
+		if !dtype.signed && dtype.size == 8 {
+			return float64(uint64(datatype{size: 8, bigEndian: dtype.bigEndian}.int(b)))
+		}
+		return float64(dtype.int(b))
+	}
+	u := uint64(datatype{size: dtype.size, bigEndian: dtype.bigEndian}.int(b))
+	if dtype.size == 4 {
+		return float64(math.Float32frombits(uint32(u)))
+	}
+	return math.Float64frombits(u)
+}
@@ -0,0 +1,102 @@
+package hdf5
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOpen(t *testing.T) {
+	writer := NewWriter()
+	if err := writer.Add("x", []int{2}, []float32{1, 2}, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	writer.WriteTo(&buf)
+	// a superblock after a user block is found, addresses are relative to it
+	data := append(make([]byte, 512), buf.Bytes()...)
+	le.PutUint64(data[512+24:], 512)
+	file, err := Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds, err := file.Dataset("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values, err := ds.Floats(); err != nil || values[1] != 2 {
+		t.Fatalf("values not read after a user block: %v %v", values, err)
+	}
+	if _, err := Open(bytes.NewReader([]byte("{\"id\": 1}")), 9); err == nil {
+		t.Fatal("opened a file that is not HDF5")
+	}
+	// the latest file format is not supported
+	data = append([]byte(nil), buf.Bytes()...)
+	data[8] = 2
+	if _, err := Open(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Fatal("opened an unsupported superblock version")
+	}
+	// truncated files return errors
+	data = buf.Bytes()[:buf.Len()-64]
+	if file, err = Open(bytes.NewReader(data), int64(len(data))); err == nil {
+		_, err = file.Dataset("x")
+	}
+	if err == nil {
+		t.Fatal("read a truncated file")
+	}
+}
+
+func TestDatatypes(t *testing.T) {
+	// big endian signed 16 bit integers
+	dtype, n, err := decodeDatatype([]byte{0x10, 0x09, 0, 0, 2, 0, 0, 0, 0, 0, 16, 0})
+	if err != nil || n != 12 {
+		t.Fatal(err)
+	}
+	if value := dtype.int([]byte{0xff, 0xfe}); value != -2 {
+		t.Fatalf("incorrect big endian integer: %v", value)
+	}
+	if value := dtype.float([]byte{0x01, 0x00}); value != 256 {
+		t.Fatalf("incorrect big endian integer: %v", value)
+	}
+	// space padded fixed length strings
+	if dtype, _, err = decodeDatatype([]byte{0x13, 0x02, 0, 0, 8, 0, 0, 0}); err != nil || dtype.padding != 2 {
+		t.Fatalf("could not decode a string datatype: %v", err)
+	}
+	// compound datatypes and variable length sequences are not supported
+	if _, _, err := decodeDatatype([]byte{0x16, 0x01, 0, 0, 8, 0, 0, 0}); err == nil {
+		t.Fatal("decoded an unsupported datatype")
+	}
+	sequence := append([]byte{0x19, 0, 0, 0, 16, 0, 0, 0}, floatType(4)...)
+	if _, _, err := decodeDatatype(sequence); err == nil {
+		t.Fatal("decoded a variable length sequence")
+	}
+}
+
+func TestFilters(t *testing.T) {
+	// a version 2 pipeline of shuffle, deflate and an optional unknown filter
+	pipeline := []byte{2, 3,
+		2, 0, 0, 0, 1, 0, 2, 0, 0, 0,
+		1, 0, 0, 0, 1, 0, 6, 0, 0, 0,
+		0x2c, 0x01, 4, 0, 1, 0, 0, 0, 'x', 'y', 'z', 0}
+	filters, err := decodeFilters(pipeline)
+	if err != nil || len(filters) != 3 || filters[0].id != filterShuffle || filters[1].values[0] != 6 || !filters[2].optional {
+		t.Fatalf("could not decode the filter pipeline: %+v %v", filters, err)
+	}
+	pipeline[26] = 0
+	if _, err := decodeFilters(pipeline); err == nil {
+		t.Fatal("decoded a pipeline with an unsupported filter")
+	}
+	// shuffle groups the nth byte of each element, any trailing bytes are left as they are
+	shuffled := []byte{1, 3, 5, 2, 4, 6, 7}
+	if got := unshuffle(shuffled, 2); !bytes.Equal(got, []byte{1, 2, 3, 4, 5, 6, 7}) {
+		t.Fatalf("incorrect unshuffle: %v", got)
+	}
+	ds := &Dataset{dtype: datatype{size: 2}, filters: []filter{{id: filterShuffle}, {id: filterFletcher32}}}
+	got, err := ds.unfilter([]byte{1, 3, 5, 2, 4, 6, 9, 9, 9, 9}, 0)
+	if err != nil || !bytes.Equal(got, []byte{1, 2, 3, 4, 5, 6}) {
+		t.Fatalf("incorrect unfilter: %v %v", got, err)
+	}
+	// a filter mask skips filters
+	if got, err = ds.unfilter([]byte{1, 3, 5, 2, 4, 6}, 2); err != nil || !bytes.Equal(got, []byte{1, 2, 3, 4, 5, 6}) {
+		t.Fatalf("incorrect unfilter with a mask: %v %v", got, err)
+	}
+}
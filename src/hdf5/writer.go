@@ -0,0 +1,565 @@
+package hdf5
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// the size of the version 0 superblock, and of a symbol table entry
+const (
+	superblockSize = 96
+	symbolSize     = 40
+)
+
+// the size of the global heap collections that variable length strings are written to
+const (
+	globalHeapMinSize    = 4096
+	globalHeapMaxObjects = 65535
+)
+
+// Options sets how a dataset is stored
+type Options struct {
+	Chunks  []int // the chunk shape, the dataset is stored contiguously if this is empty
+	Deflate int   // the zlib level (1-9) used to compress each chunk, 0 leaves the chunks uncompressed
+}
+
+// Writer builds an HDF5 file, the groups in each dataset path are created as needed
+type Writer struct {
+	root *node
+}
+
+// node is a group (children is not nil) or a dataset of the file being written
+type node struct {
+	children map[string]*node
+	dataset  *dataset
+}
+
+// dataset is a dataset waiting to be written
+type dataset struct {
+	shape   []int
+	dtype   []byte   // the encoded datatype
+	size    int      // the element size
+	data    []byte   // the little endian elements
+	strings []string // the values of a variable length string dataset
+	opts    Options
+}
+
+// NewWriter is the Writer constructor
+func NewWriter() *Writer {
+	return &Writer{root: &node{children: make(map[string]*node)}}
+}
+
+// Add is a method to add a dataset to the file, the data is a []float32, []float64, []int32, []int64 or []string (written as variable length UTF-8 strings)
+// the shape is row major and must match the length of the data
+func (writer *Writer) Add(path string, shape []int, data interface{}, opts Options) error {
+	names := splitPath(path)
+	if len(names) == 0 {
+		return fmt.Errorf("no dataset name given")
+	}
+	length := 1
+	for _, dim := range shape {
+		if dim < 0 {
+			return fmt.Errorf("dataset dimensions can't be negative: %v %v", path, shape)
+		}
+		length *= dim
+	}
+	ds := &dataset{shape: append([]int(nil), shape...), opts: opts}
+	switch values := data.(type) {
+	case []float32:
+		ds.dtype, ds.size = floatType(4), 4
+		ds.data = make([]byte, 4*len(values))
+		for i, value := range values {
+			le.PutUint32(ds.data[4*i:], math.Float32bits(value))
+		}
+		length -= len(values)
+	case []float64:
+		ds.dtype, ds.size = floatType(8), 8
+		ds.data = make([]byte, 8*len(values))
+		for i, value := range values {
+			le.PutUint64(ds.data[8*i:], math.Float64bits(value))
+		}
+		length -= len(values)
+	case []int32:
+		ds.dtype, ds.size = fixedType(4, true), 4
+		ds.data = make([]byte, 4*len(values))
+		for i, value := range values {
+			le.PutUint32(ds.data[4*i:], uint32(value))
+		}
+		length -= len(values)
+	case []int64:
+		ds.dtype, ds.size = fixedType(8, true), 8
+		ds.data = make([]byte, 8*len(values))
+		for i, value := range values {
+			le.PutUint64(ds.data[8*i:], uint64(value))
+		}
+		length -= len(values)
+	case []string:
+		ds.dtype, ds.size = vlenStringType(), 16
+		ds.strings = append([]string(nil), values...)
+		length -= len(values)
+	default:
+		return fmt.Errorf("unsupported dataset type: %T", data)
+	}
+	if length != 0 {
+		return fmt.Errorf("dataset shape %v does not match the data length: %v", shape, path)
+	}
+	if len(opts.Chunks) != 0 {
+		if len(opts.Chunks) != len(shape) {
+			return fmt.Errorf("chunk shape %v does not match the dataset shape %v: %v", opts.Chunks, shape, path)
+		}
+		for _, dim := range opts.Chunks {
+			if dim < 1 {
+				return fmt.Errorf("chunk dimensions must be at least 1: %v %v", path, opts.Chunks)
+			}
+		}
+	} else if opts.Deflate != 0 {
+		return fmt.Errorf("only chunked datasets can be compressed: %v", path)
+	}
+	if opts.Deflate < 0 || opts.Deflate > 9 {
+		return fmt.Errorf("deflate level must be 0-9: %v", opts.Deflate)
+	}
+	group := writer.root
+	for _, name := range names[:len(names)-1] {
+		child, ok := group.children[name]
+		if !ok {
+			child = &node{children: make(map[string]*node)}
+			group.children[name] = child
+		}
+		if child.children == nil {
+			return fmt.Errorf("%v is a dataset, not a group: %v", name, path)
+		}
+		group = child
+	}
+	name := names[len(names)-1]
+	if _, ok := group.children[name]; ok {
+		return fmt.Errorf("already in file: %v", path)
+	}
+	group.children[name] = &node{dataset: ds}
+	return nil
+}
+
+// WriteTo is a method to write the HDF5 file, it satisfies the io.WriterTo interface
+func (writer *Writer) WriteTo(w io.Writer) (int64, error) {
+	file := &fileBuilder{buf: make([]byte, superblockSize)}
+	root := file.writeGroup(writer.root)
+	file.writeSuperblock(root)
+	n, err := w.Write(file.buf)
+	return int64(n), err
+}
+
+// fileBuilder lays out the file in memory, each object is appended to the buffer
+type fileBuilder struct {
+	buf []byte
+}
+
+// symbol is a symbol table entry, groups cache the addresses of their B-tree and local heap
+type symbol struct {
+	name, header, btree, heap uint64
+	group                     bool
+}
+
+// encode returns the symbol table entry
+func (s symbol) encode() []byte {
+	b := make([]byte, symbolSize)
+	le.PutUint64(b, s.name)
+	le.PutUint64(b[8:], s.header)
+	if s.group {
+		le.PutUint32(b[16:], 1)
+		le.PutUint64(b[24:], s.btree)
+		le.PutUint64(b[32:], s.heap)
+	}
+	return b
+}
+
+// btreeChild is a child of a B-tree node, with the keys either side of it
+type btreeChild struct {
+	addr        uint64
+	left, right []byte
+}
+
+// message is an object header message
+type message struct {
+	kind  uint16
+	flags uint8
+	data  []byte
+}
+
+// alloc is a method to append data to the file, 8 byte aligned, returning its address
+func (file *fileBuilder) alloc(data []byte) uint64 {
+	addr := uint64(len(file.buf))
+	file.buf = append(file.buf, data...)
+	file.buf = append(file.buf, make([]byte, pad8(len(data))-len(data))...)
+	return addr
+}
+
+// writeSuperblock is a method to fill in the version 0 superblock, once the rest of the file is written
+func (file *fileBuilder) writeSuperblock(root symbol) {
+	b := file.buf[:superblockSize]
+	copy(b, signature)
+	b[13], b[14] = 8, 8
+	le.PutUint16(b[16:], groupLeafK)
+	le.PutUint16(b[18:], groupInternalK)
+	le.PutUint64(b[32:], undefinedAddress)
+	le.PutUint64(b[40:], uint64(len(file.buf)))
+	le.PutUint64(b[48:], undefinedAddress)
+	copy(b[56:], root.encode())
+}
+
+// writeHeader is a method to write a version 1 object header holding the messages
+func (file *fileBuilder) writeHeader(messages []message) uint64 {
+	size := 0
+	for _, msg := range messages {
+		size += 8 + pad8(len(msg.data))
+	}
+	b := make([]byte, 16+size)
+	b[0] = 1
+	le.PutUint16(b[2:], uint16(len(messages)))
+	le.PutUint32(b[4:], 1)
+	le.PutUint32(b[8:], uint32(size))
+	pos := 16
+	for _, msg := range messages {
+		le.PutUint16(b[pos:], msg.kind)
+		le.PutUint16(b[pos+2:], uint16(pad8(len(msg.data))))
+		b[pos+4] = msg.flags
+		copy(b[pos+8:], msg.data)
+		pos += 8 + pad8(len(msg.data))
+	}
+	return file.alloc(b)
+}
+
+// writeBtree is a method to write a version 1 B-tree over the children, adding levels until there is a single root node
+func (file *fileBuilder) writeBtree(kind byte, k, keySize int, children []btreeChild) uint64 {
+	nodeSize := 24 + 2*k*8 + (2*k+1)*keySize
+	for level := 0; ; level++ {
+		count := (len(children) + 2*k - 1) / (2 * k)
+		if count == 0 {
+			count = 1
+		}
+		// the nodes of a level are written together, so the siblings are known
+		base := uint64(len(file.buf))
+		parents := make([]btreeChild, count)
+		for i := range parents {
+			start, end := i*2*k, (i+1)*2*k
+			if end > len(children) {
+				end = len(children)
+			}
+			b := make([]byte, nodeSize)
+			copy(b, "TREE")
+			b[4], b[5] = kind, byte(level)
+			le.PutUint16(b[6:], uint16(end-start))
+			le.PutUint64(b[8:], undefinedAddress)
+			le.PutUint64(b[16:], undefinedAddress)
+			if i > 0 {
+				le.PutUint64(b[8:], base+uint64((i-1)*nodeSize))
+			}
+			if i < count-1 {
+				le.PutUint64(b[16:], base+uint64((i+1)*nodeSize))
+			}
+			pos := 24
+			for _, child := range children[start:end] {
+				copy(b[pos:], child.left)
+				le.PutUint64(b[pos+keySize:], child.addr)
+				pos += keySize + 8
+			}
+			if end > start {
+				copy(b[pos:], children[end-1].right)
+				parents[i].left, parents[i].right = children[start].left, children[end-1].right
+			}
+			parents[i].addr = file.alloc(b)
+		}
+		if count == 1 {
+			return parents[0].addr
+		}
+		children = parents
+	}
+}
+
+// writeGroup is a method to write a group and everything in it, returning the symbol table entry for the group
+func (file *fileBuilder) writeGroup(group *node) symbol {
+	names := make([]string, 0, len(group.children))
+	for name := range group.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// the local heap holds the names, offset 0 is the empty string
+	heapData := make([]byte, 8)
+	symbols := make([]symbol, len(names))
+	for i, name := range names {
+		child := group.children[name]
+		if child.children != nil {
+			symbols[i] = file.writeGroup(child)
+		} else {
+			symbols[i].header = file.writeDataset(child.dataset)
+		}
+		symbols[i].name = uint64(len(heapData))
+		heapData = append(heapData, make([]byte, pad8(len(name)+1))...)
+		copy(heapData[symbols[i].name:], name)
+	}
+	heap := make([]byte, 32)
+	copy(heap, "HEAP")
+	le.PutUint64(heap[8:], uint64(len(heapData)))
+	le.PutUint64(heap[16:], 1)
+	le.PutUint64(heap[24:], file.alloc(heapData))
+	heapAddr := file.alloc(heap)
+	// each symbol table node holds up to 2K entries, the B-tree keys are the heap offsets of the last name in each node
+	var nodes []btreeChild
+	for start := 0; start < len(names); start += 2 * groupLeafK {
+		end := start + 2*groupLeafK
+		if end > len(names) {
+			end = len(names)
+		}
+		b := make([]byte, 8+2*groupLeafK*symbolSize)
+		copy(b, "SNOD")
+		b[4] = 1
+		le.PutUint16(b[6:], uint16(end-start))
+		for i, s := range symbols[start:end] {
+			copy(b[8+i*symbolSize:], s.encode())
+		}
+		left, right := make([]byte, 8), make([]byte, 8)
+		if start > 0 {
+			le.PutUint64(left, symbols[start-1].name)
+		}
+		le.PutUint64(right, symbols[end-1].name)
+		nodes = append(nodes, btreeChild{addr: file.alloc(b), left: left, right: right})
+	}
+	btree := file.writeBtree(btreeGroup, groupInternalK, 8, nodes)
+	stab := make([]byte, 16)
+	le.PutUint64(stab, btree)
+	le.PutUint64(stab[8:], heapAddr)
+	header := file.writeHeader([]message{{kind: msgSymbolTable, data: stab}})
+	return symbol{header: header, btree: btree, heap: heapAddr, group: true}
+}
+
+// writeDataset is a method to write a dataset, returning the address of its object header
+func (file *fileBuilder) writeDataset(ds *dataset) uint64 {
+	data := ds.data
+	if ds.strings != nil {
+		data = file.writeStrings(ds.strings)
+	}
+	rank := len(ds.shape)
+	dataspace := make([]byte, 8+8*rank)
+	dataspace[0], dataspace[1] = 1, byte(rank)
+	for i, dim := range ds.shape {
+		le.PutUint64(dataspace[8+8*i:], uint64(dim))
+	}
+	messages := []message{
+		{kind: msgDataspace, data: dataspace},
+		{kind: msgDatatype, flags: 1, data: ds.dtype},
+	}
+	if len(ds.opts.Chunks) == 0 {
+		layout := make([]byte, 18)
+		layout[0], layout[1] = 3, layoutContiguous
+		le.PutUint64(layout[2:], undefinedAddress)
+		if len(data) != 0 {
+			le.PutUint64(layout[2:], file.alloc(data))
+		}
+		le.PutUint64(layout[10:], uint64(len(data)))
+		messages = append(messages,
+			message{kind: msgFillValue, flags: 1, data: []byte{2, 2, 2, 0}},
+			message{kind: msgLayout, data: layout})
+	} else {
+		layout := make([]byte, 11+4*(rank+1))
+		layout[0], layout[1], layout[2] = 3, layoutChunked, byte(rank+1)
+		le.PutUint64(layout[3:], file.writeChunks(ds, data))
+		for i, dim := range ds.opts.Chunks {
+			le.PutUint32(layout[11+4*i:], uint32(dim))
+		}
+		le.PutUint32(layout[11+4*rank:], uint32(ds.size))
+		messages = append(messages,
+			message{kind: msgFillValue, flags: 1, data: []byte{2, 3, 2, 0}},
+			message{kind: msgLayout, data: layout})
+		if ds.opts.Deflate != 0 {
+			filters := make([]byte, 32)
+			filters[0], filters[1] = 1, 1
+			le.PutUint16(filters[8:], filterDeflate)
+			le.PutUint16(filters[10:], 8)
+			le.PutUint16(filters[12:], 1)
+			le.PutUint16(filters[14:], 1)
+			copy(filters[16:], "deflate")
+			le.PutUint32(filters[24:], uint32(ds.opts.Deflate))
+			messages = append(messages, message{kind: msgFilters, data: filters})
+		}
+	}
+	return file.writeHeader(messages)
+}
+
+// writeChunks is a method to write the chunks of a dataset and the B-tree that indexes them, returning the B-tree address
+func (file *fileBuilder) writeChunks(ds *dataset, data []byte) uint64 {
+	rank := len(ds.shape)
+	keySize := 8 + 8*(rank+1)
+	grid := make([]int, rank)
+	count, chunkLength := 1, ds.size
+	for i, dim := range ds.shape {
+		grid[i] = (dim + ds.opts.Chunks[i] - 1) / ds.opts.Chunks[i]
+		count *= grid[i]
+		chunkLength *= ds.opts.Chunks[i]
+	}
+	chunks := make([]btreeChild, 0, count)
+	offset := make([]int, rank)
+	for c := 0; c < count; c++ {
+		for i, j := rank-1, c; i >= 0; i-- {
+			offset[i] = (j % grid[i]) * ds.opts.Chunks[i]
+			j /= grid[i]
+		}
+		chunk := make([]byte, chunkLength)
+		copyChunk(chunk, data, ds.shape, ds.opts.Chunks, offset, ds.size, true)
+		if ds.opts.Deflate != 0 {
+			var buf bytes.Buffer
+			zw, _ := zlib.NewWriterLevel(&buf, ds.opts.Deflate)
+			zw.Write(chunk)
+			zw.Close()
+			chunk = buf.Bytes()
+		}
+		left, right := make([]byte, keySize), make([]byte, keySize)
+		le.PutUint32(left, uint32(len(chunk)))
+		for i := range offset {
+			le.PutUint64(left[8+8*i:], uint64(offset[i]))
+			le.PutUint64(right[8+8*i:], uint64(offset[i]+ds.opts.Chunks[i]))
+		}
+		le.PutUint64(right[8+8*rank:], uint64(ds.size))
+		chunks = append(chunks, btreeChild{addr: file.alloc(chunk), left: left, right: right})
+	}
+	return file.writeBtree(btreeChunk, chunkK, keySize, chunks)
+}
+
+// writeStrings is a method to write variable length strings to global heap collections, returning the heap references
+func (file *fileBuilder) writeStrings(values []string) []byte {
+	refs := make([]byte, 16*len(values))
+	var collection []byte
+	var pending []int
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		// the rest of the collection is a free space object
+		size := pad8(len(collection) + 16)
+		if size < globalHeapMinSize {
+			size = globalHeapMinSize
+		}
+		free := len(collection)
+		collection = append(collection, make([]byte, size-len(collection))...)
+		le.PutUint64(collection[free+8:], uint64(size-free))
+		copy(collection, "GCOL")
+		collection[4] = 1
+		le.PutUint64(collection[8:], uint64(size))
+		addr := file.alloc(collection)
+		for _, i := range pending {
+			le.PutUint64(refs[16*i+4:], addr)
+		}
+		collection, pending = nil, nil
+	}
+	for i, value := range values {
+		if value == "" {
+			continue
+		}
+		if len(pending) == globalHeapMaxObjects {
+			flush()
+		}
+		if collection == nil {
+			collection = make([]byte, 16)
+		}
+		pending = append(pending, i)
+		object := make([]byte, 16+pad8(len(value)))
+		le.PutUint16(object, uint16(len(pending)))
+		le.PutUint64(object[8:], uint64(len(value)))
+		copy(object[16:], value)
+		collection = append(collection, object...)
+		le.PutUint32(refs[16*i:], uint32(len(value)))
+		le.PutUint32(refs[16*i+12:], uint32(len(pending)))
+	}
+	flush()
+	return refs
+}
+
+// copyChunk copies the elements of the chunk at offset to (toChunk) or from the row major dataset data
+// edge chunks extend past the dataset shape, those elements are skipped
+func copyChunk(chunk, data []byte, shape, chunkDims, offset []int, size int, toChunk bool) {
+	rank := len(shape)
+	last := rank - 1
+	run := chunkDims[last]
+	if offset[last]+run > shape[last] {
+		run = shape[last] - offset[last]
+	}
+	if run <= 0 {
+		return
+	}
+	index := make([]int, rank)
+	for {
+		inside := true
+		c, d := 0, 0
+		for i := 0; i < rank; i++ {
+			if offset[i]+index[i] >= shape[i] {
+				inside = false
+				break
+			}
+			c = c*chunkDims[i] + index[i]
+			d = d*shape[i] + offset[i] + index[i]
+		}
+		if inside {
+			c, d = c*size, d*size
+			if toChunk {
+				copy(chunk[c:c+run*size], data[d:d+run*size])
+			} else {
+				copy(data[d:d+run*size], chunk[c:c+run*size])
+			}
+		}
+		// move on to the next row of the chunk
+		i := last - 1
+		for ; i >= 0; i-- {
+			index[i]++
+			if index[i] < chunkDims[i] {
+				break
+			}
+			index[i] = 0
+		}
+		if i < 0 {
+			return
+		}
+	}
+}
+
+// fixedType returns an encoded little endian integer datatype
+func fixedType(size int, signed bool) []byte {
+	b := make([]byte, 12)
+	b[0] = 1<<4 | classFixed
+	if signed {
+		b[1] = 0x08
+	}
+	le.PutUint32(b[4:], uint32(size))
+	le.PutUint16(b[10:], uint16(8*size))
+	return b
+}
+
+// floatType returns an encoded little endian IEEE float datatype (size 4 or 8)
+func floatType(size int) []byte {
+	b := make([]byte, 20)
+	b[0] = 1<<4 | classFloat
+	// implied mantissa normalisation, with the sign bit position in the next byte
+	b[1] = 0x20
+	le.PutUint32(b[4:], uint32(size))
+	if size == 4 {
+		b[2] = 31
+		le.PutUint16(b[10:], 32)
+		b[12], b[13], b[14], b[15] = 23, 8, 0, 23
+		le.PutUint32(b[16:], 127)
+	} else {
+		b[2] = 63
+		le.PutUint16(b[10:], 64)
+		b[12], b[13], b[14], b[15] = 52, 11, 0, 52
+		le.PutUint32(b[16:], 1023)
+	}
+	return b
+}
+
+// vlenStringType returns an encoded variable length, null terminated UTF-8 string datatype
+func vlenStringType() []byte {
+	b := make([]byte, 8)
+	b[0] = 1<<4 | classVlen
+	b[1], b[2] = 1, 1
+	le.PutUint32(b[4:], 16)
+	return append(b, fixedType(1, false)...)
+}
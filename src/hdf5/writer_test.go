@@ -0,0 +1,188 @@
+package hdf5
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// write is a helper to write a file and open it for reading
+func write(t *testing.T, writer *Writer) (*File, []byte) {
+	var buf bytes.Buffer
+	if _, err := writer.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	file, err := Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file, buf.Bytes()
+}
+
+func TestWriter(t *testing.T) {
+	writer := NewWriter()
+	tensor := []float32{0, 1.5, -2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+	if err := writer.Add("tensor", []int{2, 3, 2}, tensor, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Add("channels", []int{2}, []string{"colour", ""}, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Add("/matrix/indptr", []int{3}, []int32{0, 2, -3}, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Add("/matrix/empty", []int{0}, []float64{}, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	file, data := write(t, writer)
+	if !bytes.Equal(data[:8], signature) || le.Uint64(data[40:]) != uint64(len(data)) {
+		t.Fatal("superblock does not have the signature and end of file address")
+	}
+	ds, err := file.Dataset("tensor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ds.Shape) != "[2 3 2]" {
+		t.Fatalf("incorrect shape: %v", ds.Shape)
+	}
+	values, err := ds.Floats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, value := range tensor {
+		if values[i] != float64(value) {
+			t.Fatalf("value %d not recovered: %v != %v", i, values[i], value)
+		}
+	}
+	if _, err := ds.Strings(); err == nil {
+		t.Fatal("a float dataset can't be read as strings")
+	}
+	if ds, err = file.Dataset("channels"); err != nil {
+		t.Fatal(err)
+	}
+	if names, err := ds.Strings(); err != nil || len(names) != 2 || names[0] != "colour" || names[1] != "" {
+		t.Fatalf("strings not recovered: %q %v", names, err)
+	}
+	if ds, err = file.Dataset("matrix/indptr"); err != nil {
+		t.Fatal(err)
+	}
+	if ints, err := ds.Ints(); err != nil || fmt.Sprint(ints) != "[0 2 -3]" {
+		t.Fatalf("ints not recovered: %v %v", ints, err)
+	}
+	if ds, err = file.Dataset("matrix/empty"); err != nil {
+		t.Fatal(err)
+	}
+	if values, err := ds.Floats(); err != nil || len(values) != 0 {
+		t.Fatalf("empty dataset not recovered: %v %v", values, err)
+	}
+	if names, err := file.List("/"); err != nil || fmt.Sprint(names) != "[channels matrix tensor]" {
+		t.Fatalf("incorrect root group: %v %v", names, err)
+	}
+	if _, err := file.Dataset("matrix"); err == nil {
+		t.Fatal("a group is not a dataset")
+	}
+	if _, err := file.Dataset("matrix/data"); err == nil {
+		t.Fatal("missing dataset opened")
+	}
+	if _, err := file.List("tensor"); err == nil {
+		t.Fatal("a dataset is not a group")
+	}
+}
+
+func TestWriterChunks(t *testing.T) {
+	// enough chunks and group members for the B-trees to need more than one level
+	writer := NewWriter()
+	shape := []int{45, 37}
+	values := make([]float64, 45*37)
+	for i := range values {
+		values[i] = float64(i) / 4
+	}
+	for _, level := range []int{0, 6} {
+		if err := writer.Add(fmt.Sprintf("chunks/deflate%d", level), shape, values, Options{Chunks: []int{4, 3}, Deflate: level}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := make([]string, 70000)
+	for i := range names {
+		names[i] = fmt.Sprintf("OTU_%d", i)
+	}
+	if err := writer.Add("ids", []int{70000}, names, Options{Chunks: []int{1000}, Deflate: 1}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 300; i++ {
+		if err := writer.Add(fmt.Sprintf("members/m%03d", i), []int{1}, []int64{int64(i)}, Options{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file, _ := write(t, writer)
+	for _, level := range []int{0, 6} {
+		ds, err := file.Dataset(fmt.Sprintf("chunks/deflate%d", level))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ds.Floats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, value := range values {
+			if got[i] != value {
+				t.Fatalf("value %d not recovered: %v != %v", i, got[i], value)
+			}
+		}
+	}
+	ds, err := file.Dataset("ids")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ds.Strings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range names {
+		if got[i] != name {
+			t.Fatalf("string %d not recovered: %v != %v", i, got[i], name)
+		}
+	}
+	members, err := file.List("members")
+	if err != nil || len(members) != 300 {
+		t.Fatalf("group members not recovered: %d %v", len(members), err)
+	}
+	for _, i := range []int{0, 8, 255, 256, 299} {
+		ds, err := file.Dataset(fmt.Sprintf("members/m%03d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ints, err := ds.Ints(); err != nil || ints[0] != int64(i) {
+			t.Fatalf("member %d not recovered: %v %v", i, ints, err)
+		}
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	writer := NewWriter()
+	if err := writer.Add("a/b", []int{1}, []float32{1}, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path  string
+		shape []int
+		data  interface{}
+		opts  Options
+	}{
+		{"", []int{1}, []float32{1}, Options{}},
+		{"c", []int{2}, []float32{1}, Options{}},
+		{"c", []int{-1}, []float32{}, Options{}},
+		{"c", []int{1}, []uint8{1}, Options{}},
+		{"c", []int{1}, []float32{1}, Options{Deflate: 4}},
+		{"c", []int{1}, []float32{1}, Options{Chunks: []int{1}, Deflate: 10}},
+		{"c", []int{1}, []float32{1}, Options{Chunks: []int{1, 1}}},
+		{"c", []int{1}, []float32{1}, Options{Chunks: []int{0}}},
+		{"a/b", []int{1}, []float32{1}, Options{}},
+		{"a/b/c", []int{1}, []float32{1}, Options{}},
+	}
+	for _, test := range tests {
+		if err := writer.Add(test.path, test.shape, test.data, test.opts); err == nil {
+			t.Fatalf("bad dataset was added: %+v", test)
+		}
+	}
+}
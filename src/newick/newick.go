@@ -0,0 +1,215 @@
+// newick contains the types/methods/functions to read a phylogenetic tree in Newick format
+
+package newick
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Node is a node in a tree, leaves have no children
+type Node struct {
+	Name     string
+	Length   float64
+	Children []*Node
+}
+
+// IsLeaf is a method to check if a node is a leaf
+func (node *Node) IsLeaf() bool {
+	return len(node.Children) == 0
+}
+
+// Leaves is a method to get the names of the leaves below a node, in depth first (left to right) order
+func (node *Node) Leaves() []string {
+	var leaves []string
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if n.IsLeaf() {
+			leaves = append(leaves, n.Name)
+			return
+		}
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	walk(node)
+	return leaves
+}
+
+// ParseError records where a Newick tree could not be parsed
+type ParseError struct {
+	Offset int
+	Err    error
+}
+
+// Error is a method to satisfy the error interface
+func (parseError *ParseError) Error() string {
+	return fmt.Sprintf("newick: character %d: %v", parseError.Offset, parseError.Err)
+}
+
+// Load reads a Newick tree from a file
+func Load(path string) (*Node, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	return Read(fh)
+}
+
+// Read reads a single Newick tree from an io.Reader
+func Read(r io.Reader) (*Node, error) {
+	data, err := ioutil.ReadAll(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+	return Parse(string(data))
+}
+
+// Parse parses a single Newick tree (e.g. ((A:0.1,B:0.2)AB:0.3,C);)
+// quoted labels, branch lengths and [comments] are supported, and underscores in unquoted labels become spaces
+func Parse(tree string) (*Node, error) {
+	p := &parser{input: tree}
+	root, err := p.parseNode()
+	if err != nil {
+		return nil, err
+	}
+	p.skip()
+	if p.pos >= len(p.input) || p.input[p.pos] != ';' {
+		return nil, p.errorf("expected ; at end of tree")
+	}
+	p.pos++
+	p.skip()
+	if p.pos != len(p.input) {
+		return nil, p.errorf("unexpected characters after end of tree")
+	}
+	return root, nil
+}
+
+// parser is a recursive descent parser for a Newick tree
+type parser struct {
+	input string
+	pos   int
+}
+
+// errorf returns a ParseError for the current position
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &ParseError{Offset: p.pos + 1, Err: fmt.Errorf(format, args...)}
+}
+
+// skip moves past any whitespace and comments
+func (p *parser) skip() error {
+	for p.pos < len(p.input) {
+		switch p.input[p.pos] {
+		case ' ', '\t', '\r', '\n':
+			p.pos++
+		case '[':
+			end := strings.IndexByte(p.input[p.pos:], ']')
+			if end == -1 {
+				return p.errorf("unterminated comment")
+			}
+			p.pos += end + 1
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// parseNode parses a node, along with any children, label and branch length
+func (p *parser) parseNode() (*Node, error) {
+	node := &Node{}
+	if err := p.skip(); err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.input) && p.input[p.pos] == '(' {
+		p.pos++
+		for {
+			child, err := p.parseNode()
+			if err != nil {
+				return nil, err
+			}
+			node.Children = append(node.Children, child)
+			if err := p.skip(); err != nil {
+				return nil, err
+			}
+			if p.pos >= len(p.input) {
+				return nil, p.errorf("unexpected end of tree, expected , or )")
+			}
+			if p.input[p.pos] == ',' {
+				p.pos++
+				continue
+			}
+			if p.input[p.pos] == ')' {
+				p.pos++
+				break
+			}
+			return nil, p.errorf("unexpected character %q, expected , or )", p.input[p.pos])
+		}
+	}
+	name, err := p.parseLabel()
+	if err != nil {
+		return nil, err
+	}
+	node.Name = name
+	if err := p.skip(); err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.input) && p.input[p.pos] == ':' {
+		p.pos++
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		start := p.pos
+		for p.pos < len(p.input) && !strings.ContainsRune(",();[ \t\r\n", rune(p.input[p.pos])) {
+			p.pos++
+		}
+		value := p.input[start:p.pos]
+		length, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("invalid branch length: %q", value)
+		}
+		node.Length = length
+	}
+	return node, nil
+}
+
+// parseLabel parses a quoted or unquoted node label (which can be empty)
+func (p *parser) parseLabel() (string, error) {
+	if err := p.skip(); err != nil {
+		return "", err
+	}
+	if p.pos < len(p.input) && p.input[p.pos] == '\'' {
+		var label strings.Builder
+		p.pos++
+		for {
+			if p.pos >= len(p.input) {
+				return "", p.errorf("unterminated quoted label")
+			}
+			c := p.input[p.pos]
+			p.pos++
+			if c == '\'' {
+				// a doubled quote is an escaped quote
+				if p.pos < len(p.input) && p.input[p.pos] == '\'' {
+					label.WriteByte('\'')
+					p.pos++
+					continue
+				}
+				return label.String(), nil
+			}
+			label.WriteByte(c)
+		}
+	}
+	start := p.pos
+	for p.pos < len(p.input) && !strings.ContainsRune(",():;[", rune(p.input[p.pos])) {
+		p.pos++
+	}
+	label := strings.TrimSpace(p.input[start:p.pos])
+	return strings.Replace(label, "_", " ", -1), nil
+}
@@ -0,0 +1,46 @@
+package newick
+
+import (
+	"strings"
+	"testing"
+)
+
+// test the Newick parser
+func TestParse(t *testing.T) {
+	tree, err := Parse("((Bacteroides:0.1,Prevotella_copri:0.2)Bacteroidales:0.3, [a comment] ('Strepto''coccus',Simonsiella)1.0:0.4)root;\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Name != "root" || len(tree.Children) != 2 {
+		t.Fatal("root node not parsed correctly")
+	}
+	if tree.Children[0].Name != "Bacteroidales" || tree.Children[0].Length != 0.3 {
+		t.Fatal("internal node not parsed correctly")
+	}
+	leaves := tree.Leaves()
+	expected := []string{"Bacteroides", "Prevotella copri", "Strepto'coccus", "Simonsiella"}
+	if strings.Join(leaves, ",") != strings.Join(expected, ",") {
+		t.Fatalf("wrong leaf order: %v", leaves)
+	}
+}
+
+// test that malformed trees raise errors
+func TestParseErrors(t *testing.T) {
+	for _, tree := range []string{
+		"(A,B)",
+		"(A,B;",
+		"(A,B));",
+		"(A:x,B);",
+		"('A,B);",
+		"(A,B)[comment;",
+		"(A,B); (C,D);",
+	} {
+		if _, err := Parse(tree); err == nil {
+			t.Fatalf("expected an error for tree: %v", tree)
+		}
+	}
+	_, err := Parse("(A,B)C:1.0 x;")
+	if parseErr, ok := err.(*ParseError); !ok || parseErr.Offset != 12 {
+		t.Fatalf("wrong error offset: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+// pool contains the types/methods/functions to run jobs using a bounded number of workers
+
+package pool
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Pool runs jobs using a bounded number of go routines
+type Pool struct {
+	workers  int
+	progress func(done, total int)
+}
+
+// Failure records the error raised by a job
+type Failure struct {
+	Index int
+	Err   error
+}
+
+// NewPool is the Pool constructor
+// if workers < 1, a single worker is used
+func NewPool(workers int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Pool{workers: workers}
+}
+
+// SetProgress is a method to set a function that is called each time a job finishes
+// it is never called concurrently, so it doesn't need to be safe for concurrent use
+func (pool *Pool) SetProgress(progress func(done, total int)) {
+	pool.progress = progress
+}
+
+// GetWorkers returns the number of workers used by the pool
+func (pool *Pool) GetWorkers() int {
+	return pool.workers
+}
+
+// Run is a method to run n jobs, passing each job its index
+// jobs that return an error or panic don't stop the other jobs, the failures are returned in index order
+func (pool *Pool) Run(n int, job func(i int) error) []Failure {
+	errs := make([]error, n)
+	indices := make(chan int)
+	finished := make(chan int)
+	// start the workers
+	var wg sync.WaitGroup
+	workers := pool.workers
+	if workers > n {
+		workers = n
+	}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				errs[i] = runJob(job, i)
+				finished <- i
+			}
+		}()
+	}
+	// feed the jobs and report progress as they finish
+	go func() {
+		for i := 0; i < n; i++ {
+			indices <- i
+		}
+		close(indices)
+		wg.Wait()
+		close(finished)
+	}()
+	var done int
+	for range finished {
+		done++
+		if pool.progress != nil {
+			pool.progress(done, n)
+		}
+	}
+	// collect the failures
+	failures := []Failure{}
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, Failure{i, err})
+		}
+	}
+	return failures
+}
+
+// runJob runs a single job, converting a panic into an error
+func runJob(job func(i int) error, i int) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return job(i)
+}
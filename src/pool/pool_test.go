@@ -0,0 +1,58 @@
+package pool
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// test the pool runs every job and collects the failures
+func TestRun(t *testing.T) {
+	p := NewPool(3)
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	var progress []int
+	p.SetProgress(func(done, total int) {
+		progress = append(progress, done)
+	})
+	failures := p.Run(10, func(i int) error {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+		switch i {
+		case 3:
+			return fmt.Errorf("job %d failed", i)
+		case 7:
+			panic("job panicked")
+		}
+		return nil
+	})
+	if maxRunning > 3 {
+		t.Fatalf("pool used %d workers, expected no more than 3", maxRunning)
+	}
+	if len(progress) != 10 || progress[9] != 10 {
+		t.Fatal("progress not reported for every job")
+	}
+	if len(failures) != 2 || failures[0].Index != 3 || failures[1].Index != 7 {
+		t.Fatalf("failures not collected correctly: %v", failures)
+	}
+}
+
+// test the pool handles no jobs and bad worker counts
+func TestEmpty(t *testing.T) {
+	p := NewPool(0)
+	if p.GetWorkers() != 1 {
+		t.Fatal("pool should use at least one worker")
+	}
+	if failures := p.Run(0, func(i int) error { return nil }); len(failures) != 0 {
+		t.Fatal("no jobs should give no failures")
+	}
+}
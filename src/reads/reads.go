@@ -0,0 +1,119 @@
+// reads contains the types/methods/functions to prepare sequencing reads for sketching
+
+package reads
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/will-rowe/hulk/src/seqio"
+)
+
+// PHRED_OFFSET is the ASCII offset used to encode base qualities (Sanger / Illumina 1.8+)
+const PHRED_OFFSET = 33
+
+// mateRegex is used to identify R1/R2 files and capture the parts needed to find the mate
+var mateRegex = regexp.MustCompile(`^(.+?)([._])(R?)([12])((?:_\d+)?\.(?:fastq|fq)(?:\.gz)?)$`)
+
+// Sample groups the read files that will be sketched together
+type Sample struct {
+	Name  string
+	Files []string
+}
+
+// QualityTrimmer is a pipeline process to trim low quality bases from the ends of reads
+// reads which are shorter than MinReadLength after trimming are dropped
+type QualityTrimmer struct {
+	Input         chan seqio.FASTQread
+	Output        chan seqio.FASTQread
+	MinQual       int
+	MinReadLength int
+}
+
+// NewQualityTrimmer is the constructor
+func NewQualityTrimmer() *QualityTrimmer {
+	return &QualityTrimmer{Output: make(chan seqio.FASTQread)}
+}
+
+// Run is the method to run this process, which satisfies the pipeline interface
+func (proc *QualityTrimmer) Run() {
+	defer close(proc.Output)
+	for read := range proc.Input {
+		start, end := TrimPositions(read.Qual, proc.MinQual)
+		if (end - start) < proc.MinReadLength {
+			continue
+		}
+		read.Seq = read.Seq[start:end]
+		read.Qual = read.Qual[start:end]
+		proc.Output <- read
+	}
+}
+
+// TrimPositions returns the start and end positions of a read once bases with a phred score below minQual have been trimmed from either end
+func TrimPositions(qual []byte, minQual int) (int, int) {
+	start, end := 0, len(qual)
+	for start < end && int(qual[start])-PHRED_OFFSET < minQual {
+		start++
+	}
+	for end > start && int(qual[end-1])-PHRED_OFFSET < minQual {
+		end--
+	}
+	return start, end
+}
+
+// PairFiles groups a set of FASTQ files into R1/R2 pairs, named by the shared prefix of each pair
+// it returns an error if a file can't be identified as a mate or if a mate is missing
+func PairFiles(files []string) ([]Sample, error) {
+	lookup := make(map[string]bool, len(files))
+	for _, file := range files {
+		lookup[file] = true
+	}
+	used := make(map[string]bool, len(files))
+	samples := []Sample{}
+	for _, file := range files {
+		if used[file] {
+			continue
+		}
+		parts := mateRegex.FindStringSubmatch(file)
+		if parts == nil {
+			return nil, fmt.Errorf("can't identify R1/R2 from filename: %v", file)
+		}
+		if parts[4] != "1" {
+			continue
+		}
+		mate := parts[1] + parts[2] + parts[3] + "2" + parts[5]
+		if !lookup[mate] {
+			return nil, fmt.Errorf("no R2 file found for: %v", file)
+		}
+		used[file], used[mate] = true, true
+		samples = append(samples, Sample{
+			Name:  filepath.Base(parts[1]),
+			Files: []string{file, mate},
+		})
+	}
+	// make sure every R2 was matched to an R1
+	for _, file := range files {
+		if !used[file] {
+			return nil, fmt.Errorf("no R1 file found for: %v", file)
+		}
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].Files[0] < samples[j].Files[0]
+	})
+	return samples, nil
+}
+
+// SingleFiles creates one sample per file, named by the file basename
+func SingleFiles(files []string) []Sample {
+	samples := make([]Sample, len(files))
+	for i, file := range files {
+		samples[i] = Sample{
+			Name:  strings.TrimSuffix(filepath.Base(file), ".gz"),
+			Files: []string{file},
+		}
+	}
+	return samples
+}
@@ -0,0 +1,45 @@
+package reads
+
+import (
+	"testing"
+)
+
+var (
+	pairedFiles = []string{"./data/sampleA_R1.fastq.gz", "./data/sampleA_R2.fastq.gz", "./data/sampleB_1.fq", "./data/sampleB_2.fq"}
+	orphanFiles = []string{"./data/sampleA_R1.fastq.gz", "./data/sampleB_2.fq"}
+)
+
+// test the quality trimming
+func TestTrimPositions(t *testing.T) {
+	// qualities: 2, 2, 40, 40, 40, 10
+	qual := []byte{35, 35, 73, 73, 73, 43}
+	start, end := TrimPositions(qual, 20)
+	if start != 2 || end != 5 {
+		t.Fatalf("expected trim positions 2:5, got %d:%d", start, end)
+	}
+	// a read that is entirely low quality should be trimmed to nothing
+	start, end = TrimPositions([]byte{35, 35, 35}, 20)
+	if start != end {
+		t.Fatal("low quality read should be fully trimmed")
+	}
+}
+
+// test the R1/R2 pairing
+func TestPairFiles(t *testing.T) {
+	samples, err := PairFiles(pairedFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("expected 2 paired samples, got %d", len(samples))
+	}
+	if samples[0].Name != "sampleA" || samples[1].Name != "sampleB" {
+		t.Fatalf("sample names not parsed correctly: %v, %v", samples[0].Name, samples[1].Name)
+	}
+	if samples[1].Files[1] != "./data/sampleB_2.fq" {
+		t.Fatal("mate not paired correctly")
+	}
+	if _, err := PairFiles(orphanFiles); err == nil {
+		t.Fatal("files without mates should raise an error")
+	}
+}
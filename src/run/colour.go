@@ -0,0 +1,205 @@
+package run
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/will-rowe/hulk/src/histosketch"
+	"github.com/will-rowe/thor/src/colour"
+	"github.com/will-rowe/thor/src/hammer"
+	"github.com/will-rowe/thor/src/pool"
+	"github.com/will-rowe/thor/src/spectrum"
+)
+
+// ColourOptions holds the options for the colour subcommand
+type ColourOptions struct {
+	SketchDir  string // the directory containing the sketches
+	Recursive  bool   // recursively search the supplied directory
+	StoreCSV   bool   // also write the colour sketches to a plain text csv file
+	OutFile    string // basename for the outfile(s)
+	Processors int    // number of processors to use
+}
+
+// Colour runs the colour subcommand, colouring a directory of histosketches and writing them to a colour sketch store
+func Colour(opts *ColourOptions) error {
+	log.Printf("starting the colour subcommand")
+	log.Printf("\tsketch directory: %v", opts.SketchDir)
+	log.Printf("\toutput file basename: %v", opts.OutFile)
+	if opts.SketchDir == "" {
+		return fmt.Errorf("no sketch directory supplied")
+	}
+	// add a slash if not already present in dir param
+	sDir := opts.SketchDir
+	if !strings.HasSuffix(sDir, "/") {
+		sDir += "/"
+	}
+	// create the sketch pile
+	hSketches, _, err := histosketch.CreateSketchCollection(sDir, opts.Recursive)
+	if err != nil {
+		return err
+	}
+	// check we have at least 1 sketch
+	if len(hSketches) < 1 {
+		return fmt.Errorf("need at least 1 sketch!")
+	}
+	// check the sketches were all built with compatible spectrums and record the shared spectrum for the store
+	spectrums, err := spectrum.FindSpectrums(sDir, opts.Recursive)
+	if err != nil {
+		return err
+	}
+	spec, err := spectrum.CheckAll(spectrums)
+	if err != nil {
+		return err
+	}
+	// the sketch length comes from the spectrum, or the first non-empty sketch if there isn't one
+	var sketchLength int
+	if spec == nil {
+		log.Printf("\tno .spectrum files found with the sketches, can't check that the sketches are comparable")
+		sketchLength = firstSketchLength(hSketches)
+	} else {
+		sketchLength = int(spec.SketchSize)
+		for id, hSketch := range hSketches {
+			// empty sketches are reported when colouring
+			if len(hSketch.Sketch) != 0 && uint(len(hSketch.Sketch)) != spec.SketchSize {
+				return fmt.Errorf("sketch length of %v (%d) does not match the spectrum (%d)", id, len(hSketch.Sketch), spec.SketchSize)
+			}
+		}
+		if err := spec.Dump(opts.OutFile + "-coloursketches" + spectrum.EXTENSION); err != nil {
+			return err
+		}
+	}
+	// colour the sketches
+	log.Printf("colouring %d sketches...", len(hSketches))
+	if err := makeColourSketches(hSketches, sketchLength, opts); err != nil {
+		return err
+	}
+	log.Printf("finished")
+	return nil
+}
+
+// firstSketchLength returns the length of the first non-empty sketch, in name order (0 if every sketch is empty)
+func firstSketchLength(hSketches map[string]*histosketch.SketchStore) int {
+	var first string
+	var sketchLength int
+	for id, hSketch := range hSketches {
+		if len(hSketch.Sketch) != 0 && (sketchLength == 0 || id < first) {
+			first, sketchLength = id, len(hSketch.Sketch)
+		}
+	}
+	return sketchLength
+}
+
+// makeColourSketches will colour the sketches and then write to a THOR data structure (and csv if requested)
+// every sketch must have the same sketchLength, sketches that don't are left out of the store
+func makeColourSketches(hSketches map[string]*histosketch.SketchStore, sketchLength int, opts *ColourOptions) error {
+	// create the csv outfile if asked for
+	var csvWriter *csv.Writer
+	if opts.StoreCSV {
+		csvFile, err := os.Create((opts.OutFile + "-coloursketches.csv"))
+		if err != nil {
+			return err
+		}
+		defer csvFile.Close()
+		csvWriter = csv.NewWriter(csvFile)
+		defer csvWriter.Flush()
+	}
+	// create an ordering
+	ordering := make([]string, len(hSketches))
+	count := 0
+	for id := range hSketches {
+		ordering[count] = id
+		count++
+	}
+	sort.Strings(ordering)
+	// set up colour sketch store
+	css := make(colour.ColourSketchStore)
+	// colour the sketches using a bounded number of go routines
+	coloured := make(colour.ColourSketchStore)
+	warnings := make([]error, len(ordering))
+	var mu sync.Mutex
+	workers := pool.NewPool(getWorkers(opts.Processors))
+	workers.SetProgress(func(done, total int) {
+		if done%1000 == 0 || done == total {
+			log.Printf("\tcoloured %d/%d sketches", done, total)
+		}
+	})
+	failures := workers.Run(len(ordering), func(i int) error {
+		sketch := hSketches[ordering[i]].Sketch
+		if len(sketch) == 0 {
+			return fmt.Errorf("empty sketch")
+		}
+		if len(sketch) != sketchLength {
+			return fmt.Errorf("sketch length (%d) does not match the other sketches (%d)", len(sketch), sketchLength)
+		}
+		// convert the sketch values to uint16 (modulo is used and a warning returned if they overflow)
+		values, overflow := colour.ConvertSketch(sketch)
+		warnings[i] = overflow
+		// colour the sketch
+		cs := colour.NewColourSketch(values, ordering[i])
+		mu.Lock()
+		coloured[ordering[i]] = cs
+		mu.Unlock()
+		return nil
+	})
+	// report any sketches that couldn't be coloured, these are left out of the store
+	if len(failures) != 0 {
+		log.Printf("failed to colour %d of %d sketches:", len(failures), len(ordering))
+		for _, failure := range failures {
+			log.Printf("\t%v: %v", ordering[failure.Index], failure.Err)
+		}
+		if len(failures) == len(ordering) {
+			return fmt.Errorf("no sketches could be coloured")
+		}
+	}
+
+	// collect the coloursketches
+	var scaled error
+	for i, id := range ordering {
+		coloursketch, ok := coloured[id]
+		if !ok {
+			continue
+		}
+		// check if sketch values were scaled
+		if warnings[i] != nil {
+			scaled = warnings[i]
+		}
+		// clean up the id so that only the genus remains
+		tmp1 := strings.TrimSuffix(coloursketch.Id, ".sketch")
+		tmp2 := strings.Split(tmp1, "/")
+		if len(tmp2) == 1 {
+			coloursketch.Id = tmp2[0]
+		} else {
+			coloursketch.Id = tmp2[len(tmp2)-1]
+		}
+		// add this coloursketch to the store
+		if _, ok := css[coloursketch.Id]; !ok {
+			css[coloursketch.Id] = coloursketch
+		} else {
+			return fmt.Errorf("duplicate sketch name found: %v", coloursketch.Id)
+		}
+		// write this colour sketch (in hex) to the csv
+		if opts.StoreCSV {
+			colours, err := coloursketch.PrintCSVline(true)
+			if err != nil {
+				return err
+			}
+			if err := csvWriter.Write([]string{coloursketch.Id, colours}); err != nil {
+				return err
+			}
+		}
+	}
+	// log if sketch values were scaled to fit uint16
+	if scaled != nil {
+		log.Printf("\t%v", scaled)
+	}
+	// add a padding line (slice of 0s) to the store
+	padLine := make([]uint32, css.GetSketchLength())
+	css[hammer.PAD_LINE] = colour.NewColourSketch(padLine, hammer.PAD_LINE)
+	// encode and write the colour sketch map to disk
+	return css.Dump(opts.OutFile + "-coloursketches.thor")
+}
@@ -0,0 +1,41 @@
+package run
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/will-rowe/hulk/src/histosketch"
+	"github.com/will-rowe/thor/src/colour"
+)
+
+// test an empty sketch is left out of the store, without setting the sketch length for the others
+func TestMakeColourSketches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thor-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	hSketches := map[string]*histosketch.SketchStore{
+		"Akkermansia.sketch":   {Sketch: []uint{}},
+		"Bacteroides.sketch":   {Sketch: []uint{1, 2, 3, 4}},
+		"Prevotella.sketch":    {Sketch: []uint{5, 6, 7, 8}},
+		"Streptococcus.sketch": {Sketch: []uint{1, 2}},
+	}
+	if length := firstSketchLength(hSketches); length != 4 {
+		t.Fatalf("expected a sketch length of 4, got %d", length)
+	}
+	opts := &ColourOptions{OutFile: filepath.Join(dir, "test")}
+	if err := makeColourSketches(hSketches, firstSketchLength(hSketches), opts); err != nil {
+		t.Fatal(err)
+	}
+	css := make(colour.ColourSketchStore)
+	if err := css.Load(opts.OutFile + "-coloursketches.thor"); err != nil {
+		t.Fatal(err)
+	}
+	// the two coloured sketches and the padding line
+	if len(css) != 3 || css["Bacteroides"] == nil || css["Prevotella"] == nil || css.GetSketchLength() != 4 {
+		t.Fatalf("unexpected colour sketch store: %d sketches", len(css))
+	}
+}
@@ -0,0 +1,132 @@
+package run
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+
+	"github.com/will-rowe/thor/src/colour"
+	"github.com/will-rowe/thor/src/explain"
+)
+
+// the suffixes of the files written by the explain subcommand
+const (
+	EXPLAIN_ROWS   = ".thor-explain-rows.tsv"   // the importance of each image row
+	EXPLAIN_GENERA = ".thor-explain-genera.tsv" // the genera of each sample, ranked by importance
+	EXPLAIN_BINS   = ".thor-explain-bins.tsv"   // the importance of each sketch bin
+	EXPLAIN_COHORT = ".thor-explain-cohort.tsv" // the genera ranked by their importance averaged across the samples
+)
+
+// ExplainOptions holds the options for the explain subcommand
+type ExplainOptions struct {
+	Saliency       []string // the saliency maps (.npy or grayscale .png), one for each image
+	Legends        []string // the row legends written by hammer, in the same order as the saliency maps
+	ColourSketches string   // the reference colour sketches used to make the images
+	OutFile        string   // basename for the outfile(s)
+}
+
+// check is a method to check the program input
+func (opts *ExplainOptions) check() error {
+	if len(opts.Saliency) == 0 {
+		return fmt.Errorf("no saliency maps supplied")
+	}
+	if len(opts.Saliency) != len(opts.Legends) {
+		return fmt.Errorf("need a row legend for each saliency map (%d saliency maps, %d legends)", len(opts.Saliency), len(opts.Legends))
+	}
+	for _, file := range append(append([]string{}, opts.Saliency...), opts.Legends...) {
+		if err := checkFile(file); err != nil {
+			return err
+		}
+	}
+	if opts.ColourSketches == "" {
+		return fmt.Errorf("require --colourSketches, the images must be explained with the colour sketches they were made with")
+	}
+	return checkFile(opts.ColourSketches)
+}
+
+// Explain runs the explain subcommand, mapping saliency maps over thor images back to the taxa and sketch bins they were drawn from
+func Explain(opts *ExplainOptions) error {
+	log.Printf("starting the explain subcommand")
+	log.Printf("checking parameters...")
+	if err := opts.check(); err != nil {
+		return err
+	}
+	log.Printf("\tnum. saliency maps: %d", len(opts.Saliency))
+	log.Printf("\tcolour sketches: %v", opts.ColourSketches)
+	log.Printf("\toutput file basename: %v", opts.OutFile)
+	css := make(colour.ColourSketchStore)
+	if err := css.Load(opts.ColourSketches); err != nil {
+		return err
+	}
+	// explain each image, using the sample name recorded in its legend
+	log.Printf("explaining %d images...", len(opts.Saliency))
+	explanations := make([]*explain.Explanation, len(opts.Saliency))
+	seen := make(map[string]bool)
+	for i, path := range opts.Saliency {
+		legendPath, err := filepath.Abs(opts.Legends[i])
+		if err != nil {
+			return err
+		}
+		if seen[legendPath] {
+			return fmt.Errorf("row legend used for more than one saliency map: %v", opts.Legends[i])
+		}
+		seen[legendPath] = true
+		saliency, err := explain.LoadSaliency(path)
+		if err != nil {
+			return fmt.Errorf("could not read saliency map (%v): %v", path, err)
+		}
+		sample, legend, err := LoadLegend(opts.Legends[i])
+		if err != nil {
+			return err
+		}
+		if explanations[i], err = explain.Explain(sample, saliency, legend, css); err != nil {
+			return fmt.Errorf("could not explain %v: %v", path, err)
+		}
+	}
+	// write the importances
+	if err := writeTable(opts.OutFile+EXPLAIN_ROWS, []string{"sample", "row", "genus", "status", "importance", "share"}, func(w io.Writer) error {
+		for _, explanation := range explanations {
+			for _, row := range explanation.Rows {
+				fmt.Fprintf(w, "%v\t%d\t%v\t%v\t%v\t%v\n", explanation.Sample, row.Row, row.Genus, row.Status, row.Importance, row.Share)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := writeTable(opts.OutFile+EXPLAIN_GENERA, []string{"sample", "rank", "genus", "importance", "share"}, func(w io.Writer) error {
+		for _, explanation := range explanations {
+			for i, genus := range explanation.Genera {
+				fmt.Fprintf(w, "%v\t%d\t%v\t%v\t%v\n", explanation.Sample, i+1, genus.Genus, genus.Importance, genus.Share)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := writeTable(opts.OutFile+EXPLAIN_BINS, []string{"sample", "bin", "importance", "share", "top_genus", "sketch_value"}, func(w io.Writer) error {
+		for _, explanation := range explanations {
+			for _, bin := range explanation.Bins {
+				fmt.Fprintf(w, "%v\t%d\t%v\t%v\t%v\t%d\n", explanation.Sample, bin.Bin, bin.Importance, bin.Share, bin.TopGenus, bin.SketchValue)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	cohort := explain.Cohort(explanations)
+	if err := writeTable(opts.OutFile+EXPLAIN_COHORT, []string{"rank", "genus", "mean_share", "mean_importance", "samples"}, func(w io.Writer) error {
+		for i, genus := range cohort {
+			fmt.Fprintf(w, "%d\t%v\t%v\t%v\t%d\n", i+1, genus.Genus, genus.MeanShare, genus.MeanImportance, genus.Samples)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if len(cohort) != 0 {
+		log.Printf("\tmost important genus across the cohort: %v (mean share %.3f)", cohort[0].Genus, cohort[0].MeanShare)
+	}
+	log.Printf("finished")
+	return nil
+}
@@ -0,0 +1,108 @@
+package run
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/will-rowe/thor/src/draw"
+)
+
+// test the explain subcommand maps a saliency map back to the genera of a hammered image
+func TestExplain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thor-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tablePath := filepath.Join(dir, "table.txt")
+	if err := ioutil.WriteFile(tablePath, []byte("#OTU ID\ts1\ttaxonomy\nOTU_1\t2500\tg__Bacteroides\nOTU_2\t100\tg__Escherichia\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hammerOpts := &HammerOptions{
+		OTUtables:      []string{tablePath},
+		Format:         "qiime",
+		ColourSketches: makeTestStore(t, dir),
+		Padding:        true,
+		OutFile:        filepath.Join(dir, "test"),
+	}
+	if err := Hammer(hammerOpts); err != nil {
+		t.Fatal(err)
+	}
+	// the image is 4x4, with Bacteroides on row 0 and padding below
+	saliencyPath := filepath.Join(dir, "saliency.npy")
+	fh, err := os.Create(saliencyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := draw.WriteNpy(fh, []int{4, 4}, []float32{3, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	fh.Close()
+	opts := &ExplainOptions{
+		Saliency:       []string{saliencyPath},
+		Legends:        []string{hammerOpts.legendFile("s1")},
+		ColourSketches: hammerOpts.ColourSketches,
+		OutFile:        filepath.Join(dir, "explained"),
+	}
+	if err := Explain(opts); err != nil {
+		t.Fatal(err)
+	}
+	genera, err := ioutil.ReadFile(opts.OutFile + EXPLAIN_GENERA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(genera) != "sample\trank\tgenus\timportance\tshare\ns1\t1\tBacteroides\t4\t1\n" {
+		t.Fatalf("unexpected genera:\n%s", genera)
+	}
+	cohort, err := ioutil.ReadFile(opts.OutFile + EXPLAIN_COHORT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(cohort), "1\tBacteroides\t1\t4\t1\n") {
+		t.Fatalf("unexpected cohort:\n%s", cohort)
+	}
+	bins, err := ioutil.ReadFile(opts.OutFile + EXPLAIN_BINS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(bins)), "\n"); len(lines) != 5 || !strings.HasPrefix(lines[1], "s1\t0\t3\t0.75\tBacteroides\t") {
+		t.Fatalf("unexpected bins:\n%s", bins)
+	}
+	rows, err := ioutil.ReadFile(opts.OutFile + EXPLAIN_ROWS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(rows)), "\n"); len(lines) != 5 || lines[4] != "s1\t3\t\tpadding\t0\t0" {
+		t.Fatalf("unexpected rows:\n%s", rows)
+	}
+	// mismatched inputs
+	opts.Legends = nil
+	if err := Explain(opts); err == nil {
+		t.Fatal("saliency maps without legends should return an error")
+	}
+	opts.Saliency = []string{saliencyPath, saliencyPath}
+	opts.Legends = []string{hammerOpts.legendFile("s1"), filepath.Join(dir, ".", filepath.Base(hammerOpts.legendFile("s1")))}
+	if err := Explain(opts); err == nil {
+		t.Fatal("a legend used twice should return an error")
+	}
+	// legends with the same name in different directories are different images
+	otherDir := filepath.Join(dir, "other")
+	if err := os.Mkdir(otherDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	legend, err := ioutil.ReadFile(hammerOpts.legendFile("s1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherLegend := filepath.Join(otherDir, filepath.Base(hammerOpts.legendFile("s1")))
+	if err := ioutil.WriteFile(otherLegend, legend, 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts.Legends = []string{hammerOpts.legendFile("s1"), otherLegend}
+	if err := Explain(opts); err != nil {
+		t.Fatal(err)
+	}
+}
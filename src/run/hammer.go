@@ -0,0 +1,891 @@
+package run
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/will-rowe/thor/src/colour"
+	"github.com/will-rowe/thor/src/draw"
+	"github.com/will-rowe/thor/src/hammer"
+	"github.com/will-rowe/thor/src/newick"
+	"github.com/will-rowe/thor/src/pool"
+	"github.com/will-rowe/thor/src/spectrum"
+	"github.com/will-rowe/thor/src/version"
+)
+
+// SupportedFormats are the currently supported otu table formats
+var SupportedFormats = append([]string{"qiime", "mothur", "dada2", "biom", "qza"}, hammer.ProfileFormats...)
+
+// HammerOptions holds the options for the hammer subcommand
+type HammerOptions struct {
+	OTUtables      []string            // the input OTU tables (- reads a table from Stdin)
+	Format         string              // the otuTable format
+	ColourSketches string              // the reference colour sketches
+	AlphaAbundance bool                // replace the alpha channel of the colour sketch with the OTU abundance
+	Padding        bool                // pad out the image with white pixels if OTUs are absent
+	Abundance      string              // how to scale abundances to pixel values (see hammer.AbundanceTypes, defaults to auto)
+	BitDepth       int                 // the bits per channel of the PNGs (8 or 16, defaults to 8)
+	Spectrum       string              // a spectrum file that the colour sketches must be compatible with
+	Taxonomy       string              // a taxonomy file assigning lineages to the OTU ids, for tables without a taxonomy column
+	MinConfidence  float64             // the minimum confidence for a taxonomy file assignment to be used
+	Label          string              // the distance label to use from mothur shared files
+	Rank           string              // the rank to use from taxonomic profiles (defaults to genus)
+	Synonyms       string              // a synonym table used to match OTU table genera to the colour sketches
+	Taxdump        string              // an NCBI taxdump directory (names.dmp and nodes.dmp) used to match genera on their taxid
+	RowOrder       string              // how to order the rows of each image (see hammer.RowOrderings)
+	OrderFile      string              // a file listing the genera in row order, for the fixed row order
+	Tree           string              // a Newick tree of the reference genera, for the phylogeny row order
+	Filter         hammer.Filter       // the abundance and prevalence thresholds used to drop OTUs
+	Augment        hammer.Augmentation // the training variants to make of each sample (none if Augment.Variants is 0)
+	Tensor         string              // also write each sample as an N-channel tensor in this format (see draw.TensorFormats)
+	Channels       []string            // the tensor channels to write (see hammer.Channels)
+	LegendTable    bool                // also write a long format legend table for every sample in the run
+	OutFile        string              // basename for the outfile(s)
+	Processors     int                 // number of processors to use
+	Stdin          io.Reader           // where to read a - OTU table from (defaults to os.Stdin)
+	taxonomy       hammer.Taxonomy
+	nameMatcher    *hammer.NameMatcher
+	channels       []draw.Channel
+	stdin          *checksumReader
+	storeChecksum  string
+	abundance      string // how the run is scaled, set by the first table
+	abundanceTable string // the table that set the scaling
+}
+
+// STDIN is the OTU table name used to read a table from STDIN
+const STDIN = "-"
+
+// check is a method to check the program input
+func (opts *HammerOptions) check() error {
+	// check specified format is supported
+	if !checkSupported(opts.Format, SupportedFormats) {
+		return fmt.Errorf("OTU table format not supported: %v", opts.Format)
+	}
+	// check the rank
+	if _, err := hammer.ParseRank(opts.Rank); err != nil {
+		return err
+	}
+	// check the abundance scaling
+	if opts.Abundance == "" {
+		opts.Abundance = hammer.ABUNDANCE_AUTO
+	}
+	if !checkSupported(opts.Abundance, hammer.AbundanceTypes) {
+		return fmt.Errorf("abundance scaling not supported: %v", opts.Abundance)
+	}
+	// check the row ordering
+	if opts.RowOrder == "" {
+		opts.RowOrder = hammer.ORDER_ABUNDANCE
+	}
+	if !checkSupported(opts.RowOrder, hammer.RowOrderings) {
+		return fmt.Errorf("row order not supported: %v", opts.RowOrder)
+	}
+	if opts.RowOrder == hammer.ORDER_FIXED {
+		if opts.OrderFile == "" {
+			return fmt.Errorf("the fixed row order needs an order file")
+		}
+		if err := checkFile(opts.OrderFile); err != nil {
+			return err
+		}
+	}
+	if opts.RowOrder == hammer.ORDER_PHYLOGENY {
+		if opts.Tree == "" {
+			return fmt.Errorf("the phylogeny row order needs a Newick tree")
+		}
+		if err := checkFile(opts.Tree); err != nil {
+			return err
+		}
+	}
+	// absent genera must be drawn as padding to keep every genus on the same row
+	if opts.RowOrder != hammer.ORDER_ABUNDANCE && !opts.Padding {
+		return fmt.Errorf("the %v row order needs --padding, so that absent genera keep their row", opts.RowOrder)
+	}
+	// check the OTU tables
+	if len(opts.OTUtables) == 0 {
+		return fmt.Errorf("no OTU tables supplied")
+	}
+	var stdin int
+	for _, otuTable := range opts.OTUtables {
+		if otuTable == STDIN {
+			if stdin++; stdin > 1 {
+				return fmt.Errorf("STDIN (-) can only be used for one OTU table")
+			}
+			continue
+		}
+		if err := checkFile(otuTable); err != nil {
+			return err
+		}
+		// TODO: check the supplied file is actually an OTU table
+
+		// TODO: check that the supplied file is in the specified format
+
+	}
+	// check the taxonomy file
+	if opts.Taxonomy != "" {
+		if err := checkFile(opts.Taxonomy); err != nil {
+			return err
+		}
+	}
+	// check the name harmonisation files
+	if opts.Synonyms != "" {
+		if err := checkFile(opts.Synonyms); err != nil {
+			return err
+		}
+	}
+	if opts.Taxdump != "" {
+		for _, file := range []string{hammer.TAXDUMP_NAMES, hammer.TAXDUMP_NODES} {
+			if err := checkFile(filepath.Join(opts.Taxdump, file)); err != nil {
+				return err
+			}
+		}
+	}
+	// check the augmentation
+	if opts.Augment.Variants < 0 {
+		return fmt.Errorf("the number of augmented variants can't be negative: %d", opts.Augment.Variants)
+	}
+	// check the PNG bit depth
+	if opts.BitDepth == 0 {
+		opts.BitDepth = 8
+	}
+	if opts.BitDepth != 8 && opts.BitDepth != 16 {
+		return fmt.Errorf("PNG bit depth must be 8 or 16: %d", opts.BitDepth)
+	}
+	// check the tensor output
+	if opts.Tensor != "" {
+		if err := draw.CheckTensorFormat(opts.Tensor); err != nil {
+			return err
+		}
+		channels, err := hammer.GetChannels(opts.Channels)
+		if err != nil {
+			return err
+		}
+		opts.channels = channels
+	}
+	// check the colour sketch file
+	if opts.ColourSketches == "" {
+		return fmt.Errorf("require --colourSketches, run `thor colour` if you haven't already")
+	}
+	return checkFile(opts.ColourSketches)
+}
+
+// readTable is a method to read an OTU table from a file or STDIN
+func (opts *HammerOptions) readTable(path string) (*hammer.OTUTable, error) {
+	tableOpts := hammer.TableOptions{
+		Format:   opts.Format,
+		Name:     path,
+		Taxonomy: opts.taxonomy,
+		Label:    opts.Label,
+		Rank:     opts.Rank,
+	}
+	if path != STDIN {
+		return hammer.ReadOTUTable(path, tableOpts)
+	}
+	// record the checksum of the table as it is read, as STDIN can't be read again
+	if opts.Stdin == nil {
+		opts.stdin = newChecksumReader(os.Stdin)
+	} else {
+		opts.stdin = newChecksumReader(opts.Stdin)
+	}
+	return hammer.NewOTUTableWithOptions(opts.stdin, tableOpts)
+}
+
+// tableSource is an OTU table waiting to be hammered, which is read when it is needed unless it is already loaded
+type tableSource struct {
+	name  string
+	path  string
+	table *hammer.OTUTable
+}
+
+// tableSources is a method to get the OTU tables of the run
+// taxonomic profiles hold a single sample, so they are read now (using the worker pool) and combined into one table
+func (opts *HammerOptions) tableSources() ([]*tableSource, error) {
+	sources := make([]*tableSource, len(opts.OTUtables))
+	for i, path := range opts.OTUtables {
+		sources[i] = &tableSource{name: path, path: path}
+	}
+	if !hammer.IsProfileFormat(opts.Format) {
+		return sources, nil
+	}
+	tables := make([]*hammer.OTUTable, len(sources))
+	failures := pool.NewPool(getWorkers(opts.Processors)).Run(len(sources), func(i int) error {
+		table, _, err := opts.loadTable(sources[i])
+		tables[i] = table
+		return err
+	})
+	if len(failures) != 0 {
+		return nil, tableError(sources[failures[0].Index].name, failures[0].Err)
+	}
+	for i, table := range tables {
+		if err := opts.setAbundance(table, sources[i].name); err != nil {
+			return nil, err
+		}
+	}
+	merged, err := hammer.MergeOTUTables(tables)
+	if err != nil {
+		return nil, fmt.Errorf("could not combine profiles: %v", err)
+	}
+	return []*tableSource{{name: fmt.Sprintf("%d %v profiles", len(opts.OTUtables), opts.Format), table: merged}}, nil
+}
+
+// loadTable is a method to get an OTU table ready to hammer, setting the filter and matching the genera to the colour sketches
+// it returns the number of genera that were renamed by the name matching
+func (opts *HammerOptions) loadTable(source *tableSource) (*hammer.OTUTable, int, error) {
+	if source.table != nil {
+		return source.table, 0, nil
+	}
+	table, err := opts.readTable(source.path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := table.SetFilter(opts.Filter); err != nil {
+		return nil, 0, err
+	}
+	renamed, err := table.HarmoniseNames(opts.nameMatcher)
+	if err != nil {
+		return nil, 0, err
+	}
+	return table, renamed, nil
+}
+
+// tableError wraps an error raised while reading an OTU table, parse errors already say where the problem is
+func tableError(tableName string, err error) error {
+	if _, ok := err.(*hammer.ParseError); ok {
+		return fmt.Errorf("could not parse OTU table: %v", err)
+	}
+	return fmt.Errorf("could not process OTU table (%v): %v", tableName, err)
+}
+
+// abundanceSums is a method to sum the relative abundance of each genus over every OTU table, for the mean abundance row order
+// the tables are read (using the worker pool) and released once they are summed, apart from a table read from STDIN or the only
+// table in the run, which are kept for hammering so they aren't read twice
+func (opts *HammerOptions) abundanceSums(sources []*tableSource) (*hammer.AbundanceSums, error) {
+	tableSums := make([]*hammer.AbundanceSums, len(sources))
+	failures := pool.NewPool(getWorkers(opts.Processors)).Run(len(sources), func(i int) error {
+		table, _, err := opts.loadTable(sources[i])
+		if err != nil {
+			return err
+		}
+		if sources[i].path == STDIN || len(sources) == 1 {
+			sources[i].table = table
+		}
+		tableSums[i] = hammer.NewAbundanceSums()
+		tableSums[i].Add(table)
+		return nil
+	})
+	if len(failures) != 0 {
+		return nil, tableError(sources[failures[0].Index].name, failures[0].Err)
+	}
+	// merge in table order, so the sums don't depend on which table finished first
+	sums := hammer.NewAbundanceSums()
+	for _, tableSum := range tableSums {
+		sums.Merge(tableSum)
+	}
+	return sums, nil
+}
+
+// loadedTable is an OTU table that has been read for hammering
+type loadedTable struct {
+	table   *hammer.OTUTable
+	renamed int
+	err     error
+}
+
+// prefetchTables is a method to read the OTU tables in order on a separate go routine, so the next table is read while the current one is hammered
+// a read table waits until the last one has been hammered, so no more than two tables are held at once
+// it stops after the first error, or once done is closed
+func (opts *HammerOptions) prefetchTables(sources []*tableSource, done <-chan struct{}) <-chan loadedTable {
+	loaded := make(chan loadedTable)
+	go func() {
+		defer close(loaded)
+		for _, source := range sources {
+			table, renamed, err := opts.loadTable(source)
+			source.table = nil
+			select {
+			case loaded <- loadedTable{table, renamed, err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return loaded
+}
+
+// setNameMatcher is a method to set up the matching of OTU table genera to the colour sketch store keys
+func (opts *HammerOptions) setNameMatcher(css colour.ColourSketchStore) error {
+	opts.nameMatcher = hammer.NewNameMatcher()
+	if opts.Synonyms != "" {
+		if err := opts.nameMatcher.LoadSynonyms(opts.Synonyms); err != nil {
+			return err
+		}
+		log.Printf("\tsynonyms: %v", opts.Synonyms)
+	}
+	if opts.Taxdump != "" {
+		taxdump, err := hammer.LoadTaxdump(opts.Taxdump)
+		if err != nil {
+			return err
+		}
+		opts.nameMatcher.SetTaxdump(taxdump)
+		log.Printf("\ttaxdump: %v", opts.Taxdump)
+	}
+	names := make([]string, 0, len(css))
+	for name := range css {
+		if name != hammer.PAD_LINE {
+			names = append(names, name)
+		}
+	}
+	for _, clash := range opts.nameMatcher.SetReference(names) {
+		log.Printf("\tcolour sketch name matches another colour sketch after harmonisation, it will only be used for exact matches: %v", clash)
+	}
+	return nil
+}
+
+// setAbundance is a method to scale an OTU table the same way as every other table in the run, so that there is one abundance cap per run
+// with auto scaling the first table decides, and every later table must also hold relative abundances or counts
+func (opts *HammerOptions) setAbundance(table *hammer.OTUTable, tableName string) error {
+	relative := opts.Abundance == hammer.ABUNDANCE_RELATIVE
+	if opts.Abundance == hammer.ABUNDANCE_AUTO {
+		relative = table.IsRelative()
+		if opts.abundance != "" && (opts.abundance == hammer.ABUNDANCE_RELATIVE) != relative {
+			return fmt.Errorf("OTU tables hold a mix of relative abundances and counts (%v and %v), use --abundance counts or --abundance relative to hammer them together", opts.abundanceTable, tableName)
+		}
+	}
+	table.SetRelative(relative)
+	if opts.abundance != "" {
+		return nil
+	}
+	opts.abundance, opts.abundanceTable = hammer.ABUNDANCE_COUNTS, tableName
+	if relative {
+		opts.abundance = hammer.ABUNDANCE_RELATIVE
+	}
+	log.Printf("	abundances scaled as %v (abundance cap: %v)", opts.abundance, table.GetAbundanceCap())
+	return nil
+}
+
+// rowOrder is a method to get the genera in the requested row order, the order is nil for the abundance row order
+func (opts *HammerOptions) rowOrder(sources []*tableSource, css colour.ColourSketchStore, sketchLength int) ([]string, error) {
+	var order []string
+	switch opts.RowOrder {
+	case hammer.ORDER_ABUNDANCE:
+		return nil, nil
+	case hammer.ORDER_STORE:
+		order = hammer.StoreOrder(css)
+	case hammer.ORDER_MEAN:
+		sums, err := opts.abundanceSums(sources)
+		if err != nil {
+			return nil, err
+		}
+		order = sums.Order()
+	case hammer.ORDER_FIXED:
+		names, err := hammer.LoadOrder(opts.OrderFile)
+		if err != nil {
+			return nil, err
+		}
+		var unmatched []string
+		order, unmatched = hammer.MatchOrder(names, opts.nameMatcher)
+		if len(unmatched) != 0 {
+			log.Printf("\t%d genera in the order file could not be matched to the colour sketches and have been skipped", len(unmatched))
+		}
+	case hammer.ORDER_PHYLOGENY:
+		tree, err := newick.Load(opts.Tree)
+		if err != nil {
+			return nil, fmt.Errorf("could not read tree (%v): %v", opts.Tree, err)
+		}
+		var unmatched []string
+		order, unmatched = hammer.PhylogenyOrder(tree, opts.nameMatcher)
+		if len(unmatched) != 0 {
+			log.Printf("\t%d tree leaves could not be matched to the colour sketches and have been skipped", len(unmatched))
+		}
+	}
+	// only genera in the colour sketches can hold a row
+	order, missing := hammer.FilterOrder(order, css)
+	if len(missing) != 0 && opts.RowOrder != hammer.ORDER_MEAN {
+		log.Printf("\t%d genera in the row order are not in the colour sketches and have been skipped", len(missing))
+	}
+	if len(order) == 0 {
+		return nil, fmt.Errorf("none of the genera in the row order are in the colour sketches")
+	}
+	if len(order) > sketchLength {
+		log.Printf("\trow order has %d genera, only the first %d will be used", len(order), sketchLength)
+	}
+	return order, nil
+}
+
+// keepRows is a method to keep the OTUs for each table that will be drawn as rows in the images
+// the top N most abundant OTUs are kept if there is no row order
+func (opts *HammerOptions) keepRows(tables []*hammer.OTUTable, tableNames []string, order []string, sketchLength int) error {
+	for i, table := range tables {
+		var err error
+		if order == nil {
+			err = table.KeepTopN(sketchLength)
+		} else {
+			err = table.KeepOrder(order, sketchLength)
+		}
+		if err != nil {
+			return fmt.Errorf("could not process OTU table (%v): %v", tableNames[i], err)
+		}
+	}
+	return nil
+}
+
+// checkStoreSpectrum checks a colour sketch store against the spectrum it was built with
+// if a spectrum file is supplied, the store must have a compatible spectrum
+// the store spectrum is returned, which is nil if the store doesn't have one
+func checkStoreSpectrum(storePath string, sketchLength int, spectrumPath string) (*spectrum.Spectrum, error) {
+	storeSpec, err := spectrum.LoadSidecar(storePath)
+	if err != nil {
+		return nil, err
+	}
+	if storeSpec == nil {
+		if spectrumPath != "" {
+			return nil, fmt.Errorf("no spectrum file found for colour sketches, can't check compatibility: %v", storePath)
+		}
+		log.Printf("\tno spectrum file found for colour sketches")
+		return nil, nil
+	}
+	if storeSpec.SketchSize != uint(sketchLength) {
+		return nil, fmt.Errorf("colour sketch length (%d) does not match the spectrum (%d)", sketchLength, storeSpec.SketchSize)
+	}
+	log.Printf("\tspectrum: k=%d, sketch size=%d, countmin=%dx%d", storeSpec.KmerSize, storeSpec.SketchSize, storeSpec.Tables, storeSpec.Counters)
+	if spectrumPath == "" {
+		return storeSpec, nil
+	}
+	spec := &spectrum.Spectrum{}
+	if err := spec.Load(spectrumPath); err != nil {
+		return nil, err
+	}
+	if err := spec.Compatible(storeSpec); err != nil {
+		return nil, err
+	}
+	return storeSpec, nil
+}
+
+// Hammer runs the hammer subcommand, transforming each sample in a set of OTU tables into an image
+// the tables are hammered one at a time while the next table is read, so no more than two tables are held in memory at once
+// (taxonomic profiles are combined into a single table first), and the samples of each table are hammered by the worker pool
+func Hammer(opts *HammerOptions) error {
+	log.Printf("starting the hammer subcommand")
+	// check the supplied files and then log some stuff
+	log.Printf("checking parameters...")
+	if err := opts.check(); err != nil {
+		return err
+	}
+	log.Printf("\tinput OTU tables:")
+	for _, file := range opts.OTUtables {
+		log.Printf("\t\t%v", file)
+	}
+	log.Printf("\tOTU table format: %v", opts.Format)
+	log.Printf("\toutput file basename: %v", opts.OutFile)
+	log.Printf("\tinclude OTU abundance: %t", opts.AlphaAbundance)
+	log.Printf("\tpad PNG: %t", opts.Padding)
+	log.Printf("\tabundance scaling: %v", opts.Abundance)
+	log.Printf("\tPNG bit depth: %d", opts.BitDepth)
+	log.Printf("\trow order: %v", opts.RowOrder)
+	log.Printf("\tOTU filter: min. abundance %v, min. relative abundance %v, min. prevalence %v", opts.Filter.MinAbundance, opts.Filter.MinRelativeAbundance, opts.Filter.MinPrevalence)
+	if opts.Tensor != "" {
+		names := make([]string, len(opts.channels))
+		for i, channel := range opts.channels {
+			names[i] = channel.Name
+		}
+		log.Printf("\ttensor output: %v (channels: %v)", opts.Tensor, strings.Join(names, ", "))
+	}
+	if opts.Augment.Variants > 0 {
+		log.Printf("\taugmentation: %d variants per sample (seed %d, subsample %v, dropout %v below %v, noise %v)", opts.Augment.Variants, opts.Augment.Seed, opts.Augment.Subsample, opts.Augment.Dropout, opts.Augment.DropoutThreshold, opts.Augment.Noise)
+	}
+	log.Printf("\tcolour sketches: %v", opts.ColourSketches)
+	// start the run report, recording the settings and the checksums of the input files
+	report, err := opts.newReport()
+	if err != nil {
+		return err
+	}
+	// load the taxonomy for tables without a taxonomy column
+	if opts.Taxonomy != "" {
+		taxonomy, err := hammer.LoadTaxonomy(opts.Taxonomy, opts.MinConfidence)
+		if err != nil {
+			return err
+		}
+		log.Printf("\ttaxonomy: %v (%d OTU ids, min. confidence %v)", opts.Taxonomy, len(taxonomy), opts.MinConfidence)
+		opts.taxonomy = taxonomy
+	}
+	// load the reference colour sketches
+	css := make(colour.ColourSketchStore)
+	if err := css.Load(opts.ColourSketches); err != nil {
+		return err
+	}
+	sketchLength := css.GetSketchLength()
+	log.Printf("\tsketch length: %d", sketchLength)
+	// check the colour sketches against their spectrum
+	storeSpec, err := checkStoreSpectrum(opts.ColourSketches, sketchLength, opts.Spectrum)
+	if err != nil {
+		return err
+	}
+	if err := report.SetStore(opts.ColourSketches, css, storeSpec); err != nil {
+		return err
+	}
+	opts.storeChecksum = report.Store.SHA256
+	// set up the name matching
+	if err := opts.setNameMatcher(css); err != nil {
+		return err
+	}
+	// get the OTU tables, which are read as they are hammered unless they are taxonomic profiles
+	log.Printf("processing OTU table(s)...")
+	opts.abundance, opts.abundanceTable = "", ""
+	sources, err := opts.tableSources()
+	if err != nil {
+		return err
+	}
+	// get the row order from the original samples, so that augmentation doesn't change it
+	order, err := opts.rowOrder(sources, css, sketchLength)
+	if err != nil {
+		return err
+	}
+	run := &hammerRun{
+		css:          css,
+		sketchLength: sketchLength,
+		order:        order,
+		report:       report,
+		samplePool:   pool.NewPool(getWorkers(opts.Processors)),
+		seen:         make(map[string]string),
+	}
+	legendTable := opts.OutFile + LEGEND_EXTENSION
+	if opts.LegendTable {
+		fh, err := os.Create(legendTable)
+		if err != nil {
+			return err
+		}
+		defer fh.Close()
+		run.legendFile, run.legend = fh, bufio.NewWriter(fh)
+		fmt.Fprintln(run.legend, strings.Join(legendHeader, "\t"))
+	}
+	// hammer the tables one at a time, so that memory depends on the size of the largest table rather than the number of samples in the run
+	done := make(chan struct{})
+	defer close(done)
+	var next int
+	for loaded := range opts.prefetchTables(sources, done) {
+		source := sources[next]
+		next++
+		if loaded.err != nil {
+			return tableError(source.name, loaded.err)
+		}
+		if loaded.renamed != 0 {
+			log.Printf("\t%v: %d genera renamed to match the colour sketches", source.name, loaded.renamed)
+		}
+		if err := opts.hammerTable(run, loaded.table, source.name); err != nil {
+			return err
+		}
+	}
+	if opts.LegendTable {
+		if err := run.legend.Flush(); err != nil {
+			return err
+		}
+		if err := run.legendFile.Close(); err != nil {
+			return err
+		}
+		log.Printf("\tlegend table: %v", legendTable)
+	}
+	// record the OTU tables, now that any table on STDIN has been read
+	if err := opts.addTableInputs(report); err != nil {
+		return err
+	}
+	report.Settings.Abundance = opts.abundance
+	report.Finished = time.Now().UTC()
+	reportFile := opts.OutFile + REPORT_EXTENSION
+	if err := report.Dump(reportFile); err != nil {
+		return err
+	}
+	log.Printf("\trun report: %v", reportFile)
+	if len(run.failures) != 0 {
+		log.Printf("failed to hammer %d samples:", len(run.failures))
+		for _, failure := range run.failures {
+			log.Printf("\t%v", failure)
+		}
+		return fmt.Errorf("failed to hammer %d of %d samples, see log for details", len(run.failures), run.numSamples)
+	}
+	// link the augmented variants to their source samples
+	if len(run.augmented) != 0 {
+		manifest := opts.OutFile + AUGMENT_MANIFEST
+		if err := writeAugmentManifest(manifest, run.augmented, run.seen); err != nil {
+			return err
+		}
+		log.Printf("\taugmentation manifest: %v", manifest)
+	}
+	log.Printf("finished")
+	return nil
+}
+
+// AUGMENT_MANIFEST is the suffix of the file linking augmented variants to their source samples
+const AUGMENT_MANIFEST = ".thor-augment.tsv"
+
+// writeAugmentManifest writes a tab separated file linking each augmented variant to its source sample and table
+func writeAugmentManifest(path string, augmented []hammer.AugmentedSample, sampleTables map[string]string) error {
+	fh, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(fh)
+	fmt.Fprintln(w, "variant\tsource_sample\tsource_table\tvariant_number\tseed")
+	for _, sample := range augmented {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%d\t%d\n", sample.Name, sample.Source, sampleTables[sample.Source], sample.Variant, sample.Seed)
+	}
+	if err := w.Flush(); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
+
+// hammerRun holds what the OTU tables of a hammer run share, as the tables are hammered one at a time
+type hammerRun struct {
+	css          colour.ColourSketchStore
+	sketchLength int
+	order        []string // the row order, nil for the abundance row order
+	report       *HammerReport
+	samplePool   *pool.Pool
+	legendFile   *os.File
+	legend       *bufio.Writer // the legend table, nil if it wasn't requested
+	seen         map[string]string
+	augmented    []hammer.AugmentedSample
+	failures     []string // the samples that could not be hammered, along with the error
+	numTables    int
+	numSamples   int
+}
+
+// hammerTable is a method to hammer every sample in an OTU table, along with any augmented variants of the samples
+// the samples are recorded in the report and legend table once they are hammered, so the table can then be released
+func (opts *HammerOptions) hammerTable(run *hammerRun, table *hammer.OTUTable, tableName string) error {
+	if err := opts.setAbundance(table, tableName); err != nil {
+		return err
+	}
+	run.report.Settings.AbundanceCap = table.GetAbundanceCap()
+	tables := []*hammer.OTUTable{table}
+	tableNames := []string{tableName}
+	variants := make(map[string]*hammer.AugmentedSample)
+	if opts.Augment.Variants > 0 {
+		augmented, samples, err := table.Augment(opts.Augment)
+		if err != nil {
+			return fmt.Errorf("could not augment OTU table (%v): %v", tableName, err)
+		}
+		tables = append(tables, augmented)
+		tableNames = append(tableNames, fmt.Sprintf("%v (%d augmented variants per sample)", tableName, opts.Augment.Variants))
+		for i := range samples {
+			variants[samples[i].Name] = &samples[i]
+		}
+		run.augmented = append(run.augmented, samples...)
+	}
+	// get the top N most abundant OTUs for each sample, or the OTUs in the requested row order
+	for _, table := range tables {
+		table.SetWorkers(getWorkers(opts.Processors))
+	}
+	if err := opts.keepRows(tables, tableNames, run.order, run.sketchLength); err != nil {
+		return err
+	}
+	// log the tables in order and queue the samples, making sure sample names are unique so the output is deterministic
+	jobs := []sampleJob{}
+	for i, table := range tables {
+		run.numTables++
+		log.Printf("\ttable %d: %v", run.numTables, tableNames[i])
+		log.Printf("\tnum. samples: %d", table.GetNumSamples())
+		log.Printf("\tnum. OTU ids at genus level: %d", table.GetTotalGenusOTUs())
+		log.Printf("\tnum. OTU ids without a genus: %d", table.GetUnclassifiedOTUs())
+		for j := 0; j < table.GetNumSamples(); j++ {
+			sample, err := table.GetSampleName(j)
+			if err != nil {
+				return err
+			}
+			if prev, ok := run.seen[sample]; ok {
+				return fmt.Errorf("sample name `%v` found in more than one OTU table (%v and %v)", sample, prev, tableNames[i])
+			}
+			run.seen[sample] = tableNames[i]
+			jobs = append(jobs, sampleJob{table, j, sample, tableNames[i], variants[sample]})
+		}
+	}
+	// colour, draw, encode and write each sample using a bounded number of workers
+	log.Printf("hammering %d samples...", len(jobs))
+	run.samplePool.SetProgress(func(done, total int) {
+		if done%1000 == 0 || done == total {
+			log.Printf("\thammered %d/%d samples", done, total)
+		}
+	})
+	failures := run.samplePool.Run(len(jobs), func(i int) error {
+		return hammerSample(jobs[i], run.css, run.sketchLength, opts)
+	})
+	// record how each image was made
+	if err := opts.addSampleReports(run.report, jobs, run.css, run.sketchLength, failures); err != nil {
+		return err
+	}
+	if run.legend != nil {
+		if err := opts.printLegendTable(run.legend, jobs, run.css, run.sketchLength); err != nil {
+			return err
+		}
+	}
+	for _, failure := range failures {
+		run.failures = append(run.failures, fmt.Sprintf("%v: %v", jobs[failure.Index].sample, failure.Err))
+	}
+	run.numSamples += len(jobs)
+	return nil
+}
+
+// sampleJob is a sample waiting to be hammered into an image
+type sampleJob struct {
+	table     *hammer.OTUTable
+	index     int
+	sample    string
+	tableName string
+	variant   *hammer.AugmentedSample
+}
+
+// hammerSample colours the top N OTUs for a sample, draws them and writes the PNG
+func hammerSample(job sampleJob, css colour.ColourSketchStore, sketchLength int, opts *HammerOptions) error {
+	// create the canvas
+	// TODO: this is created as a square for now
+	newPNG := draw.NewThorPNG
+	if opts.BitDepth == 16 {
+		newPNG = draw.NewThorPNG16
+	}
+	img, err := newPNG(sketchLength, sketchLength)
+	if err != nil {
+		return err
+	}
+	if opts.BitDepth == 16 {
+		// 16-bit PNGs hold the sketch value in R and the abundance in B
+		lines, err := job.table.ColourSample64(job.index, css, opts.Padding)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if line == nil {
+				continue
+			}
+			if err := img.DrawOTU64(line); err != nil {
+				return err
+			}
+		}
+	} else {
+		sampleRGBA, err := job.table.ColourSample(job.index, css, opts.Padding)
+		if err != nil {
+			return err
+		}
+		// collect the pixel vectors
+		for _, line := range sampleRGBA {
+			// if padding is not requested, skip this line
+			if line == nil {
+				if opts.Padding == false {
+					continue
+				}
+				// TODO: if OTU not found in RefSeq, a nil line will be returned - need to handle this!
+				continue
+			}
+			if err := img.DrawOTU(line); err != nil {
+				return err
+			}
+		}
+	}
+	// describe the image in its text chunks, then write the png and its row legend
+	rows, err := job.table.GetRows(job.index, css, opts.Padding)
+	if err != nil {
+		return err
+	}
+	text, err := opts.sampleText(job, rows, sketchLength)
+	if err != nil {
+		return err
+	}
+	for keyword, value := range text {
+		if err := img.SetText(keyword, value); err != nil {
+			return err
+		}
+	}
+	if err := img.Save(opts.imageFile(job.sample), opts.Padding); err != nil {
+		return err
+	}
+	if err := opts.writeLegend(opts.legendFile(job.sample), job.sample, rows, sketchLength); err != nil {
+		return err
+	}
+	if opts.Tensor == "" {
+		return nil
+	}
+	return tensorSample(job, css, sketchLength, opts)
+}
+
+// the PNG text keywords used to describe each image
+const (
+	TEXT_SOFTWARE      = "Software"
+	TEXT_SAMPLE        = "thor-sample"
+	TEXT_TABLE         = "thor-table"
+	TEXT_SOURCE        = "thor-source"
+	TEXT_STORE         = "thor-store"
+	TEXT_STORE_SHA256  = "thor-store-sha256"
+	TEXT_ROWS          = "thor-rows"
+	TEXT_NORMALISATION = "thor-normalisation"
+)
+
+// sampleText is a method to get the PNG text chunks describing the image for a sample
+// the rows are a JSON list holding the genus drawn on each image row, with an empty string for padding or empty rows
+func (opts *HammerOptions) sampleText(job sampleJob, rows []hammer.Row, sketchLength int) (map[string]string, error) {
+	genera := make([]string, sketchLength)
+	for _, row := range rows {
+		if row.Index != -1 && row.Index < sketchLength {
+			genera[row.Index] = row.Genus
+		}
+	}
+	rowText, err := json.Marshal(genera)
+	if err != nil {
+		return nil, err
+	}
+	normalisation, err := json.Marshal(opts.normalisation(job.table))
+	if err != nil {
+		return nil, err
+	}
+	text := map[string]string{
+		TEXT_SOFTWARE:      "thor " + version.VERSION,
+		TEXT_SAMPLE:        job.sample,
+		TEXT_TABLE:         job.tableName,
+		TEXT_STORE:         opts.ColourSketches,
+		TEXT_STORE_SHA256:  opts.storeChecksum,
+		TEXT_ROWS:          string(rowText),
+		TEXT_NORMALISATION: string(normalisation),
+	}
+	if job.variant != nil {
+		text[TEXT_SOURCE] = fmt.Sprintf("%v (variant %d)", job.variant.Source, job.variant.Variant)
+	}
+	return text, nil
+}
+
+// imageFile is a method to get the filename of the image for a sample
+func (opts *HammerOptions) imageFile(sample string) string {
+	return fmt.Sprintf("%v-%v.thor-image.png", opts.OutFile, sample)
+}
+
+// tensorFile is a method to get the filename of the tensor for a sample
+func (opts *HammerOptions) tensorFile(sample string) string {
+	return fmt.Sprintf("%v-%v.thor-tensor%v", opts.OutFile, sample, draw.TensorExtensions[opts.Tensor])
+}
+
+// tensorSample draws the kept OTUs for a sample as an N-channel tensor and writes it in the requested format
+// the rows match the PNG, with any rows left over at the end of the tensor set to 0
+func tensorSample(job sampleJob, css colour.ColourSketchStore, sketchLength int, opts *HammerOptions) error {
+	rows, err := job.table.ChannelSample(job.index, css, opts.channels, opts.Padding)
+	if err != nil {
+		return err
+	}
+	tensor, err := draw.NewTensor(sketchLength, sketchLength, opts.channels)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if row == nil {
+			continue
+		}
+		if err := tensor.DrawRow(row); err != nil {
+			return err
+		}
+	}
+	return tensor.Save(opts.tensorFile(job.sample), opts.Tensor)
+}
@@ -0,0 +1,471 @@
+package run
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/will-rowe/thor/src/colour"
+	"github.com/will-rowe/thor/src/draw"
+	"github.com/will-rowe/thor/src/hammer"
+)
+
+var (
+	testTable = "../hammer/otu-table.txt"
+	genera    = []string{"Streptococcus", "Bacteroides", "Simonsiella", "Propionibacterium"}
+)
+
+// makeTestStore writes a small colour sketch store for the test OTU table
+func makeTestStore(t *testing.T, dir string) string {
+	css := make(colour.ColourSketchStore)
+	for i, genus := range genera {
+		css[genus] = colour.NewColourSketch([]uint32{uint32(i), 1, 2, 3}, genus)
+	}
+	css[hammer.PAD_LINE] = colour.NewColourSketch(make([]uint32, 4), hammer.PAD_LINE)
+	storePath := filepath.Join(dir, "test-coloursketches.thor")
+	if err := css.Dump(storePath); err != nil {
+		t.Fatal(err)
+	}
+	return storePath
+}
+
+// test the hammer subcommand returns errors rather than exiting
+func TestHammerErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thor-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	storePath := makeTestStore(t, dir)
+	opts := &HammerOptions{
+		OTUtables:      []string{"./missing-table.txt"},
+		Format:         "qiime",
+		ColourSketches: storePath,
+		OutFile:        filepath.Join(dir, "test"),
+	}
+	if err := Hammer(opts); err == nil {
+		t.Fatal("missing OTU table should return an error")
+	}
+	opts.OTUtables = []string{testTable}
+	opts.Format = "csv"
+	if err := Hammer(opts); err == nil {
+		t.Fatal("unsupported OTU table format should return an error")
+	}
+	// a table that isn't valid BIOM should give a parse error
+	opts.Format = "biom"
+	if err := Hammer(opts); err == nil || !strings.Contains(err.Error(), "could not decode BIOM table") {
+		t.Fatalf("malformed BIOM table should return a parse error, got: %v", err)
+	}
+	opts.Format = "qiime"
+	opts.OTUtables = []string{testTable, testTable}
+	if err := Hammer(opts); err == nil {
+		t.Fatal("duplicate sample names across tables should return an error")
+	}
+	opts.OTUtables = []string{testTable}
+	opts.Filter.MinPrevalence = 2
+	if err := Hammer(opts); err == nil {
+		t.Fatal("invalid filter should return an error")
+	}
+	opts.Filter.MinPrevalence = 0
+	opts.Taxonomy = "./missing-taxonomy.tsv"
+	if err := Hammer(opts); err == nil {
+		t.Fatal("missing taxonomy file should return an error")
+	}
+}
+
+// test the hammer subcommand writes an image for each sample
+func TestHammer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thor-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	opts := &HammerOptions{
+		OTUtables:      []string{testTable},
+		Format:         "qiime",
+		ColourSketches: makeTestStore(t, dir),
+		Padding:        true,
+		OutFile:        filepath.Join(dir, "test"),
+		Processors:     2,
+	}
+	if err := Hammer(opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "test-700114607.thor-image.png")); err != nil {
+		t.Fatal("no image written for sample")
+	}
+	// read the table from STDIN
+	fh, err := os.Open(testTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+	opts.OTUtables = []string{STDIN}
+	opts.Stdin = fh
+	opts.OutFile = filepath.Join(dir, "stdin")
+	if err := Hammer(opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stdin-700114607.thor-image.png")); err != nil {
+		t.Fatal("no image written for sample read from STDIN")
+	}
+	// use a taxonomy file for a table without a taxonomy column
+	tablePath := filepath.Join(dir, "asv-table.txt")
+	if err := ioutil.WriteFile(tablePath, []byte("#OTU ID\tasvSample\nASV_1\t10\nASV_2\t0.5\nASV_3\t2\nASV_4\t0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	taxonomyPath := filepath.Join(dir, "taxonomy.tsv")
+	if err := ioutil.WriteFile(taxonomyPath, []byte("ASV_1\tRoot;g__Bacteroides\nASV_2\tRoot;g__Simonsiella\nASV_3\tRoot;g__Streptococcus\nASV_4\tRoot;g__Propionibacterium\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts.OTUtables = []string{tablePath}
+	opts.OutFile = filepath.Join(dir, "asv")
+	if err := Hammer(opts); err == nil {
+		t.Fatal("a table without a taxonomy column should need a taxonomy file")
+	}
+	opts.Taxonomy = taxonomyPath
+	if err := Hammer(opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "asv-asvSample.thor-image.png")); err != nil {
+		t.Fatal("no image written for sample using a taxonomy file")
+	}
+}
+
+// test the hammer subcommand with a fixed row order
+func TestHammerRowOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thor-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	opts := &HammerOptions{
+		OTUtables:      []string{testTable},
+		Format:         "qiime",
+		ColourSketches: makeTestStore(t, dir),
+		RowOrder:       "phylum",
+		OutFile:        filepath.Join(dir, "test"),
+	}
+	if err := Hammer(opts); err == nil {
+		t.Fatal("unsupported row order should return an error")
+	}
+	opts.RowOrder = "fixed"
+	if err := Hammer(opts); err == nil {
+		t.Fatal("fixed row order without an order file should return an error")
+	}
+	opts.RowOrder = "phylogeny"
+	if err := Hammer(opts); err == nil {
+		t.Fatal("phylogeny row order without a tree should return an error")
+	}
+	opts.Tree = filepath.Join(dir, "tree.nwk")
+	if err := ioutil.WriteFile(opts.Tree, []byte("((Bacteroides,Simonsiella),(Streptococcus,Propionibacterium));"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Hammer(opts); err == nil {
+		t.Fatal("fixed row orders without padding should return an error")
+	}
+	opts.Padding = true
+	// order file names are harmonised to the colour sketches like the tree leaves
+	opts.OrderFile = filepath.Join(dir, "order.txt")
+	if err := ioutil.WriteFile(opts.OrderFile, []byte("g__Simonsiella\nBacteroides fragilis\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, rowOrder := range []string{"store", "mean", "phylogeny", "fixed"} {
+		opts.RowOrder = rowOrder
+		opts.OutFile = filepath.Join(dir, rowOrder)
+		if err := Hammer(opts); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, rowOrder+"-700114607.thor-image.png")); err != nil {
+			t.Fatalf("no image written for sample using %v row order", rowOrder)
+		}
+	}
+}
+
+// test that repeated runs over a table with tied abundances produce identical images
+func TestHammerReproducible(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thor-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tablePath := filepath.Join(dir, "ties.txt")
+	table := "#OTU ID\ttiedSample\tConsensus Lineage\n"
+	for i, genus := range genera {
+		table += fmt.Sprintf("OTU_%d\t5\tRoot;g__%v\n", i, genus)
+	}
+	if err := ioutil.WriteFile(tablePath, []byte(table), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := &HammerOptions{
+		OTUtables:      []string{tablePath},
+		Format:         "qiime",
+		ColourSketches: makeTestStore(t, dir),
+		Processors:     4,
+	}
+	var first string
+	for i := 0; i < 10; i++ {
+		opts.OutFile = filepath.Join(dir, fmt.Sprintf("run%d", i))
+		if err := Hammer(opts); err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadFile(opts.OutFile + "-tiedSample.thor-image.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash := fmt.Sprintf("%x", sha256.Sum256(data))
+		if i == 0 {
+			first = hash
+		} else if hash != first {
+			t.Fatalf("run %d produced a different image (%v != %v)", i, hash, first)
+		}
+	}
+}
+
+// test the hammer subcommand combines single sample profiles
+func TestHammerProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thor-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	opts := &HammerOptions{
+		Format:         "kraken2",
+		ColourSketches: makeTestStore(t, dir),
+		OutFile:        filepath.Join(dir, "profile"),
+	}
+	for _, sample := range []string{"sampleA", "sampleB"} {
+		report := filepath.Join(dir, sample+".kreport")
+		if err := ioutil.WriteFile(report, []byte("100.00\t100\t0\tR\t1\troot\n 60.00\t60\t60\tG\t816\t  Bacteroides\n 40.00\t40\t40\tG\t1301\t  Streptococcus\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		opts.OTUtables = append(opts.OTUtables, report)
+	}
+	if err := Hammer(opts); err != nil {
+		t.Fatal(err)
+	}
+	for _, sample := range []string{"sampleA", "sampleB"} {
+		if _, err := os.Stat(filepath.Join(dir, "profile-"+sample+".thor-image.png")); err != nil {
+			t.Fatalf("no image written for profile: %v", sample)
+		}
+	}
+	opts.Rank = "strain"
+	if err := Hammer(opts); err == nil {
+		t.Fatal("unsupported rank should return an error")
+	}
+}
+
+// test the hammer subcommand writes a tensor for each sample
+func TestHammerTensor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thor-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	opts := &HammerOptions{
+		OTUtables:      []string{testTable},
+		Format:         "qiime",
+		ColourSketches: makeTestStore(t, dir),
+		Padding:        true,
+		Tensor:         "zarr",
+		OutFile:        filepath.Join(dir, "test"),
+	}
+	if err := Hammer(opts); err == nil {
+		t.Fatal("unsupported tensor format should return an error")
+	}
+	opts.Tensor = draw.TENSOR_NPY
+	opts.Channels = []string{hammer.CHANNEL_SKETCH, "phylum"}
+	if err := Hammer(opts); err == nil {
+		t.Fatal("unsupported tensor channel should return an error")
+	}
+	opts.Channels = []string{hammer.CHANNEL_SKETCH, hammer.CHANNEL_ABUNDANCE, hammer.CHANNEL_PREVALENCE, hammer.CHANNEL_DEPTH}
+	if err := Hammer(opts); err != nil {
+		t.Fatal(err)
+	}
+	fh, err := os.Open(filepath.Join(dir, "test-700114607.thor-tensor.npy"))
+	if err != nil {
+		t.Fatal("no tensor written for sample")
+	}
+	defer fh.Close()
+	shape, _, err := draw.ReadNpy(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shape) != 3 || shape[0] != 4 || shape[1] != 4 || shape[2] != 4 {
+		t.Fatalf("incorrect tensor shape: %v", shape)
+	}
+	opts.Tensor = draw.TENSOR_HDF5
+	if err := Hammer(opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "test-700114607.thor-tensor.h5")); err != nil {
+		t.Fatal("no HDF5 tensor written for sample")
+	}
+}
+
+// test the hammer subcommand writes 16 bits per channel PNGs
+func TestHammer16(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thor-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	opts := &HammerOptions{
+		OTUtables:      []string{testTable},
+		Format:         "qiime",
+		ColourSketches: makeTestStore(t, dir),
+		Padding:        true,
+		BitDepth:       12,
+		OutFile:        filepath.Join(dir, "test"),
+	}
+	if err := Hammer(opts); err == nil {
+		t.Fatal("unsupported bit depth should return an error")
+	}
+	opts.BitDepth = 16
+	if err := Hammer(opts); err != nil {
+		t.Fatal(err)
+	}
+	fh, err := os.Open(filepath.Join(dir, "test-700114607.thor-image.png"))
+	if err != nil {
+		t.Fatal("no image written for sample")
+	}
+	defer fh.Close()
+	img, err := png.Decode(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model := img.ColorModel(); model != color.RGBA64Model && model != color.NRGBA64Model {
+		t.Fatal("PNG was not written with 16 bits per channel")
+	}
+}
+
+// test the hammer subcommand writes augmented variants and their manifest
+func TestHammerAugment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thor-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	opts := &HammerOptions{
+		OTUtables:      []string{testTable},
+		Format:         "qiime",
+		ColourSketches: makeTestStore(t, dir),
+		Padding:        true,
+		Augment:        hammer.Augmentation{Variants: 2, Seed: 1, Subsample: 0},
+		OutFile:        filepath.Join(dir, "test"),
+	}
+	if err := Hammer(opts); err == nil {
+		t.Fatal("invalid augmentation should return an error")
+	}
+	opts.Augment.Subsample = 0.5
+	if err := Hammer(opts); err != nil {
+		t.Fatal(err)
+	}
+	for _, sample := range []string{"700114607", "700114607-aug1", "700114607-aug2"} {
+		if _, err := os.Stat(filepath.Join(dir, "test-"+sample+".thor-image.png")); err != nil {
+			t.Fatalf("no image written for %v", sample)
+		}
+	}
+	manifest, err := ioutil.ReadFile(opts.OutFile + AUGMENT_MANIFEST)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(manifest)), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "700114607-aug1\t700114607\t"+testTable+"\t1\t") {
+		t.Fatalf("unexpected augmentation manifest:\n%s", manifest)
+	}
+}
+
+// test every table in a run is scaled the same way
+func TestHammerAbundance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thor-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	relativePath := filepath.Join(dir, "relative.txt")
+	if err := ioutil.WriteFile(relativePath, []byte("#OTU ID\ts1\ttaxonomy\nOTU_1\t0.4\tg__Bacteroides\nOTU_2\t0.6\tg__Streptococcus\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := &HammerOptions{
+		OTUtables:      []string{testTable, relativePath},
+		Format:         "qiime",
+		ColourSketches: makeTestStore(t, dir),
+		OutFile:        filepath.Join(dir, "test"),
+	}
+	if err := Hammer(opts); err == nil || !strings.Contains(err.Error(), "mix of relative abundances and counts") {
+		t.Fatalf("tables of relative abundances and counts should not be hammered together: %v", err)
+	}
+	opts.Abundance = "percent"
+	if err := Hammer(opts); err == nil {
+		t.Fatal("unsupported abundance scaling should return an error")
+	}
+	opts.Abundance = hammer.ABUNDANCE_COUNTS
+	if err := Hammer(opts); err != nil {
+		t.Fatal(err)
+	}
+	report, err := LoadHammerReport(opts.OutFile + REPORT_EXTENSION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Settings.Abundance != hammer.ABUNDANCE_COUNTS || report.Settings.AbundanceCap != hammer.COUNT_ABUNDANCE_CAP {
+		t.Fatalf("abundance scaling not recorded: %+v", report.Settings)
+	}
+	for _, sample := range report.Samples {
+		if sample.Normalisation.Relative || sample.Normalisation.AbundanceCap != hammer.COUNT_ABUNDANCE_CAP {
+			t.Fatalf("sample not scaled as counts: %+v", sample)
+		}
+	}
+}
+
+// test that tables hammered one at a time are reported in input order, with a legend table covering every table
+func TestHammerTables(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thor-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	secondPath := filepath.Join(dir, "second.txt")
+	if err := ioutil.WriteFile(secondPath, []byte("#OTU ID\ts1\ts2\ttaxonomy\nOTU_1\t40\t1\tg__Simonsiella\nOTU_2\t60\t2\tg__Streptococcus\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := &HammerOptions{
+		OTUtables:      []string{testTable, secondPath},
+		Format:         "qiime",
+		ColourSketches: makeTestStore(t, dir),
+		RowOrder:       hammer.ORDER_MEAN,
+		Padding:        true,
+		LegendTable:    true,
+		Processors:     2,
+		OutFile:        filepath.Join(dir, "test"),
+	}
+	if err := Hammer(opts); err != nil {
+		t.Fatal(err)
+	}
+	report, err := LoadHammerReport(opts.OutFile + REPORT_EXTENSION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var samples []string
+	for _, sample := range report.Samples {
+		samples = append(samples, sample.Sample)
+	}
+	if strings.Join(samples, ",") != "700114607,s1,s2" {
+		t.Fatalf("samples not reported in table order: %v", samples)
+	}
+	legend, err := ioutil.ReadFile(opts.OutFile + LEGEND_EXTENSION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sample := range samples {
+		if !strings.Contains(string(legend), "\n"+sample+"\t") {
+			t.Fatalf("legend table is missing sample %v", sample)
+		}
+	}
+}
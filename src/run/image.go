@@ -0,0 +1,263 @@
+package run
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/will-rowe/hulk/src/histosketch"
+	hVersion "github.com/will-rowe/hulk/src/version"
+	"github.com/will-rowe/thor/src/colour"
+	"github.com/will-rowe/thor/src/draw"
+	"github.com/will-rowe/thor/src/hammer"
+	"github.com/will-rowe/thor/src/reads"
+	"github.com/will-rowe/thor/src/spectrum"
+)
+
+// SupportedLayouts are the currently supported image layouts
+var SupportedLayouts = []string{"tile", "nearest"}
+
+// ImageOptions holds the options for the image subcommand
+type ImageOptions struct {
+	Reads          []string // the read file(s) for the sample
+	Fasta          bool     // the read file(s) are FASTA rather than FASTQ
+	SampleName     string   // the sample name (defaults to the basename of the first read file)
+	Layout         string   // how to lay out the image rows
+	ColourSketches string   // the reference colour sketches (required for the nearest layout)
+	KmerSize       int      // size of k-mer
+	Epsilon        float64  // epsilon value for countminsketch generation
+	Delta          float64  // delta value for countminsketch generation
+	MinCount       int      // minimum k-mer count for it to be histosketched
+	SketchSize     uint     // size of sketch
+	QualTrim       bool     // quality trim the ends of reads
+	MinQual        int      // minimum base quality used when trimming
+	MinReadLength  int      // minimum read length after trimming (0 uses the k-mer size)
+	ReuseSpectrum  string   // a spectrum file from a previous run, to make sure the sketches are comparable
+	OutFile        string   // basename for the outfile(s)
+	Processors     int      // number of processors to use
+}
+
+// check is a method to check the program input
+func (opts *ImageOptions) check() error {
+	// check the layout
+	if !checkSupported(opts.Layout, SupportedLayouts) {
+		return fmt.Errorf("image layout not supported: %v", opts.Layout)
+	}
+	if opts.Layout == "nearest" && opts.ColourSketches == "" {
+		return fmt.Errorf("--layout nearest requires --colourSketches, run `thor colour` if you haven't already")
+	}
+	// check the read files
+	if len(opts.Reads) == 0 {
+		return fmt.Errorf("no read files supplied")
+	}
+	for _, file := range opts.Reads {
+		if err := checkFile(file); err != nil {
+			return err
+		}
+	}
+	if opts.Fasta && opts.QualTrim {
+		return fmt.Errorf("--qualTrim can't be used with --fasta")
+	}
+	// check the colour sketch file
+	if opts.ColourSketches != "" {
+		if err := checkFile(opts.ColourSketches); err != nil {
+			return err
+		}
+	}
+	// load the parameters from a previous run if requested
+	if opts.ReuseSpectrum != "" {
+		if err := loadSpectrumParams(opts.ReuseSpectrum, &opts.Epsilon, &opts.Delta, &opts.KmerSize, &opts.MinCount, &opts.SketchSize); err != nil {
+			return err
+		}
+	}
+	if opts.KmerSize < 1 || opts.SketchSize < 1 {
+		return fmt.Errorf("k-mer size and sketch size must be greater than 0")
+	}
+	// set the defaults
+	if opts.SampleName == "" {
+		opts.SampleName = reads.SingleFiles(opts.Reads)[0].Name
+	}
+	if opts.MinReadLength == 0 {
+		opts.MinReadLength = opts.KmerSize
+	}
+	return nil
+}
+
+// neighbour is used to rank the reference sketches by similarity to the sample
+type neighbour struct {
+	id         string
+	similarity float64
+}
+
+// getNeighbours ranks the reference sketches by decreasing similarity to the sample coloursketch, returning the top n
+func getNeighbours(sample colour.ColourSketchStore, css colour.ColourSketchStore, id string, n int) ([]neighbour, error) {
+	neighbours := []neighbour{}
+	for key, cs := range css {
+		if key == hammer.PAD_LINE {
+			continue
+		}
+		sim, err := sample[id].Similarity(cs)
+		if err != nil {
+			return nil, fmt.Errorf("can't compare sample to reference sketch %v: %v", key, err)
+		}
+		neighbours = append(neighbours, neighbour{key, sim})
+	}
+	// sort by similarity, using the id to break ties
+	sort.Slice(neighbours, func(i, j int) bool {
+		if neighbours[i].similarity != neighbours[j].similarity {
+			return neighbours[i].similarity > neighbours[j].similarity
+		}
+		return neighbours[i].id < neighbours[j].id
+	})
+	if len(neighbours) > n {
+		neighbours = neighbours[0:n]
+	}
+	return neighbours, nil
+}
+
+// Image runs the image subcommand, sketching a sample's reads and drawing the coloured sketch as an image
+func Image(opts *ImageOptions) error {
+	log.Printf("starting the image subcommand")
+	log.Printf("\tuses: hulk (version %s)", hVersion.VERSION)
+	// check the supplied files and then log some stuff
+	log.Printf("checking parameters...")
+	if err := opts.check(); err != nil {
+		return err
+	}
+	log.Printf("\tsample: %v", opts.SampleName)
+	log.Printf("\tread files: %v", strings.Join(opts.Reads, ", "))
+	log.Printf("\toutput file basename: %v", opts.OutFile)
+	log.Printf("\timage layout: %v", opts.Layout)
+	log.Printf("\tk-mer size: %d", opts.KmerSize)
+	log.Printf("\tmin. k-mer count: %d", opts.MinCount)
+	log.Printf("\tsketch size: %d", opts.SketchSize)
+	// sketch the reads into a temporary directory
+	log.Printf("sketching the sample...")
+	tmpDir, err := ioutil.TempDir("", "thor-image")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	cms := histosketch.NewCountMinSketch(opts.Epsilon, opts.Delta, 1.0)
+	params := sketchParams{
+		fasta:         opts.Fasta,
+		kSize:         opts.KmerSize,
+		minCount:      opts.MinCount,
+		sketchSize:    opts.SketchSize,
+		qualTrim:      opts.QualTrim,
+		minQual:       opts.MinQual,
+		minReadLength: opts.MinReadLength,
+		numCPU:        getWorkers(opts.Processors),
+	}
+	spec := newSpectrum(cms, opts.Epsilon, opts.Delta, params)
+	if err := checkReusedSpectrum(opts.ReuseSpectrum, spec); err != nil {
+		return err
+	}
+	sample := reads.Sample{Name: opts.SampleName, Files: opts.Reads}
+	if err := sketchSample(sample, cms, params, filepath.Join(tmpDir, sample.Name+".sketch")); err != nil {
+		return fmt.Errorf("could not sketch the sample reads: %v", err)
+	}
+	sketches, _, err := histosketch.CreateSketchCollection(tmpDir+"/", false)
+	if err != nil {
+		return err
+	}
+	if len(sketches) != 1 {
+		return fmt.Errorf("could not sketch the sample reads")
+	}
+	// colour the sketch
+	log.Printf("colouring the sketch...")
+	sampleStore := make(colour.ColourSketchStore)
+	for _, hSketch := range sketches {
+		values, overflow := colour.ConvertSketch(hSketch.Sketch)
+		if overflow != nil {
+			log.Printf("\t%v", overflow)
+		}
+		sampleStore[sample.Name] = colour.NewColourSketch(values, sample.Name)
+	}
+	if err := sampleStore[sample.Name].Adjust('A', 255); err != nil {
+		return err
+	}
+	// draw the image
+	log.Printf("drawing the image...")
+	filename := fmt.Sprintf("%v-%v.thor-image.png", opts.OutFile, sample.Name)
+	if err := drawSampleImage(sampleStore, sample.Name, spec, opts, filename); err != nil {
+		return err
+	}
+	log.Printf("\twritten: %v", filename)
+	log.Printf("finished")
+	return nil
+}
+
+// drawSampleImage draws the coloured sample sketch using the requested layout and writes the PNG
+func drawSampleImage(sampleStore colour.ColourSketchStore, id string, spec *spectrum.Spectrum, opts *ImageOptions, filename string) error {
+	sketchLength := sampleStore.GetSketchLength()
+	sampleLine, err := sampleStore[id].PrintPNGline()
+	if err != nil {
+		return err
+	}
+	img, err := draw.NewThorPNG(sketchLength, sketchLength)
+	if err != nil {
+		return err
+	}
+	switch opts.Layout {
+	case "tile":
+		for i := 0; i < sketchLength; i++ {
+			if err := img.DrawOTU(sampleLine); err != nil {
+				return err
+			}
+		}
+	case "nearest":
+		// load the reference colour sketches
+		css := make(colour.ColourSketchStore)
+		if err := css.Load(opts.ColourSketches); err != nil {
+			return err
+		}
+		if css.GetSketchLength() != sketchLength {
+			return fmt.Errorf("sample sketch length (%d) does not match the reference colour sketches (%d)", sketchLength, css.GetSketchLength())
+		}
+		storeSpec, err := spectrum.LoadSidecar(opts.ColourSketches)
+		if err != nil {
+			return err
+		}
+		if storeSpec != nil {
+			if err := storeSpec.Compatible(spec); err != nil {
+				return err
+			}
+		} else {
+			log.Printf("\tno spectrum file found for colour sketches, can't check compatibility")
+		}
+		// the first row is the sample, the rest are the nearest references
+		if err := img.DrawOTU(sampleLine); err != nil {
+			return err
+		}
+		neighbours, err := getNeighbours(sampleStore, css, id, sketchLength-1)
+		if err != nil {
+			return err
+		}
+		log.Printf("\tnearest reference sketches:")
+		for _, n := range neighbours {
+			log.Printf("\t\t%v\t%.4f", n.id, n.similarity)
+			// encode the similarity in the B slot and set the A slot to visible
+			csCopy := css[n.id].CopySketch()
+			if err := csCopy.Adjust('B', uint8(n.similarity*255)); err != nil {
+				return err
+			}
+			if err := csCopy.Adjust('A', 255); err != nil {
+				return err
+			}
+			line, err := csCopy.PrintPNGline()
+			if err != nil {
+				return err
+			}
+			if err := img.DrawOTU(line); err != nil {
+				return err
+			}
+		}
+	}
+	// write the png, padding if there were not enough reference sketches
+	return img.Save(filename, true)
+}
@@ -0,0 +1,149 @@
+package run
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/will-rowe/thor/src/colour"
+	"github.com/will-rowe/thor/src/hammer"
+)
+
+// LEGEND_EXTENSION is the suffix of the row legends, which map each image row to its genus
+const LEGEND_EXTENSION = ".thor-legend.tsv"
+
+// legendHeader are the columns of a row legend, the sample column lets each legend be joined to the report and PNG text
+var legendHeader = []string{"sample", "row", "genus", "abundance", "scaled", "pixel", "status"}
+
+// legendFile is a method to get the filename of the row legend for a sample
+func (opts *HammerOptions) legendFile(sample string) string {
+	return fmt.Sprintf("%v-%v%v", opts.OutFile, sample, LEGEND_EXTENSION)
+}
+
+// legendRows adds the padding rows that fill the end of a padded image to the kept OTU rows
+func (opts *HammerOptions) legendRows(rows []hammer.Row, sketchLength int) []hammer.Row {
+	if !opts.Padding {
+		return rows
+	}
+	var imageRows int
+	for _, row := range rows {
+		if row.Index != -1 {
+			imageRows++
+		}
+	}
+	for ; imageRows < sketchLength; imageRows++ {
+		rows = append(rows, hammer.Row{Index: imageRows, Status: hammer.ROW_PADDING})
+	}
+	return rows
+}
+
+// printLegend prints the legend lines for a sample, in the order the OTUs were kept
+// OTUs that were not drawn have a row of NA, and the pixel is the abundance as it is held in the B slot
+func (opts *HammerOptions) printLegend(w io.Writer, sample string, rows []hammer.Row) {
+	levels := math.MaxUint8
+	if opts.BitDepth == 16 {
+		levels = math.MaxUint16
+	}
+	for _, row := range rows {
+		index := "NA"
+		if row.Index != -1 {
+			index = fmt.Sprint(row.Index)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%d\t%v\n", sample, index, row.Genus, row.Abundance, row.Scaled, int(row.Scaled*float64(levels)), row.Status)
+	}
+}
+
+// writeLegend is a method to write the row legend for a sample
+func (opts *HammerOptions) writeLegend(path, sample string, rows []hammer.Row, sketchLength int) error {
+	return writeTable(path, legendHeader, func(w io.Writer) error {
+		opts.printLegend(w, sample, opts.legendRows(rows, sketchLength))
+		return nil
+	})
+}
+
+// printLegendTable is a method to print the row legends of a set of samples to the long format legend table for the run
+func (opts *HammerOptions) printLegendTable(w io.Writer, jobs []sampleJob, colourStore colour.ColourSketchStore, sketchLength int) error {
+	for _, job := range jobs {
+		rows, err := job.table.GetRows(job.index, colourStore, opts.Padding)
+		if err != nil {
+			return err
+		}
+		opts.printLegend(w, job.sample, opts.legendRows(rows, sketchLength))
+	}
+	return nil
+}
+
+// LoadLegend reads the row legend written for an image, returning the sample name and the rows
+func LoadLegend(path string) (string, []hammer.Row, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer fh.Close()
+	scanner := bufio.NewScanner(fh)
+	if !scanner.Scan() || scanner.Text() != strings.Join(legendHeader, "\t") {
+		return "", nil, fmt.Errorf("%v: not a row legend, was expecting the header: %v", path, strings.Join(legendHeader, " "))
+	}
+	var sample string
+	var rows []hammer.Row
+	for line := 2; scanner.Scan(); line++ {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != len(legendHeader) {
+			return "", nil, fmt.Errorf("%v: line %d: was expecting %d columns, found %d", path, line, len(legendHeader), len(fields))
+		}
+		if sample == "" {
+			sample = fields[0]
+		}
+		if fields[0] != sample {
+			return "", nil, fmt.Errorf("%v: line %d: legend holds more than one sample (%v and %v), use the row legend for a single image", path, line, sample, fields[0])
+		}
+		row := hammer.Row{Index: -1, Genus: fields[2], Status: fields[6]}
+		if fields[1] != "NA" {
+			if row.Index, err = strconv.Atoi(fields[1]); err != nil || row.Index < 0 {
+				return "", nil, fmt.Errorf("%v: line %d: invalid row: %v", path, line, fields[1])
+			}
+		}
+		if row.Abundance, err = strconv.ParseFloat(fields[3], 64); err != nil {
+			return "", nil, fmt.Errorf("%v: line %d: invalid abundance: %v", path, line, fields[3])
+		}
+		if row.Scaled, err = strconv.ParseFloat(fields[4], 64); err != nil {
+			return "", nil, fmt.Errorf("%v: line %d: invalid scaled abundance: %v", path, line, fields[4])
+		}
+		switch row.Status {
+		case hammer.ROW_DRAWN, hammer.ROW_MISSING, hammer.ROW_PADDING:
+		default:
+			return "", nil, fmt.Errorf("%v: line %d: unknown row status: %v", path, line, row.Status)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return "", nil, err
+	}
+	if sample == "" {
+		return "", nil, fmt.Errorf("%v: no sample found in row legend", path)
+	}
+	return sample, rows, nil
+}
+
+// writeTable writes a tab separated file, with a header line followed by the lines printed by a function
+func writeTable(path string, header []string, print func(w io.Writer) error) error {
+	fh, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(fh)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	if err := print(w); err != nil {
+		fh.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
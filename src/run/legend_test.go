@@ -0,0 +1,96 @@
+package run
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// test the hammer subcommand writes a row legend for each image, and the legend table for the run
+func TestHammerLegend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thor-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tablePath := filepath.Join(dir, "table.txt")
+	if err := ioutil.WriteFile(tablePath, []byte("#OTU ID\ts1\ts2\ttaxonomy\nOTU_1\t2500\t10\tg__Bacteroides\nOTU_2\t100\t0\tg__Escherichia\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := &HammerOptions{
+		OTUtables:      []string{tablePath},
+		Format:         "qiime",
+		ColourSketches: makeTestStore(t, dir),
+		Padding:        true,
+		LegendTable:    true,
+		OutFile:        filepath.Join(dir, "test"),
+	}
+	if err := Hammer(opts); err != nil {
+		t.Fatal(err)
+	}
+	legend, err := ioutil.ReadFile(opts.legendFile("s1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Join([]string{
+		"sample\trow\tgenus\tabundance\tscaled\tpixel\tstatus",
+		"s1\t0\tBacteroides\t2500\t0.5\t127\tdrawn",
+		"s1\tNA\tEscherichia\t100\t0.02\t5\tmissing",
+		"s1\t1\t\t0\t0\t0\tpadding",
+		"s1\t2\t\t0\t0\t0\tpadding",
+		"s1\t3\t\t0\t0\t0\tpadding",
+		"",
+	}, "\n")
+	if string(legend) != expected {
+		t.Fatalf("unexpected legend:\n%s", legend)
+	}
+	table, err := ioutil.ReadFile(opts.OutFile + LEGEND_EXTENSION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(table)), "\n")
+	if lines[0] != "sample\trow\tgenus\tabundance\tscaled\tpixel\tstatus" || !strings.HasPrefix(lines[1], "s1\t0\tBacteroides\t") {
+		t.Fatalf("unexpected legend table:\n%s", table)
+	}
+	// a header line, 5 lines for s1 and 4 lines for s2 (which has no missing genus)
+	if len(lines) != 10 {
+		t.Fatalf("expected 10 lines in the legend table, got %d", len(lines))
+	}
+}
+
+// test a row legend can be read back
+func TestLoadLegend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thor-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test"+LEGEND_EXTENSION)
+	if err := ioutil.WriteFile(path, []byte("sample\trow\tgenus\tabundance\tscaled\tpixel\tstatus\ns1\t0\tBacteroides\t2500\t0.5\t127\tdrawn\ns1\tNA\tEscherichia\t100\t0.02\t5\tmissing\ns1\t1\t\t0\t0\t0\tpadding\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sample, rows, err := LoadLegend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample != "s1" || len(rows) != 3 || rows[0].Genus != "Bacteroides" || rows[0].Scaled != 0.5 || rows[1].Index != -1 || rows[2].Status != "padding" {
+		t.Fatalf("legend not read: %+v", rows)
+	}
+	if err := ioutil.WriteFile(path, []byte("sample\trow\tgenus\tabundance\tscaled\tpixel\tstatus\ns1\t0\tBacteroides\t2500\t0.5\t127\tblurred\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LoadLegend(path); err == nil {
+		t.Fatal("unknown row status should return an error")
+	}
+	if err := ioutil.WriteFile(path, []byte("sample\trow\tgenus\tabundance\tscaled\tpixel\tstatus\ns1\t0\tBacteroides\t2500\t0.5\t127\tdrawn\ns2\t0\tBacteroides\t10\t0.002\t0\tdrawn\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LoadLegend(path); err == nil {
+		t.Fatal("a legend table holding more than one sample should return an error")
+	}
+	if _, _, err := LoadLegend(testTable); err == nil {
+		t.Fatal("a file that isn't a legend should return an error")
+	}
+}
@@ -0,0 +1,77 @@
+package run
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/will-rowe/thor/src/draw"
+)
+
+// MetadataOptions holds the options for the metadata subcommand
+type MetadataOptions struct {
+	Images []string  // the PNGs to read the text chunks from
+	JSON   bool      // print the text chunks as JSON
+	Out    io.Writer // where to print the text chunks (defaults to os.Stdout)
+}
+
+// check is a method to check the program input
+func (opts *MetadataOptions) check() error {
+	if len(opts.Images) == 0 {
+		return fmt.Errorf("no images supplied")
+	}
+	for _, image := range opts.Images {
+		if err := checkFile(image); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Metadata runs the metadata subcommand, printing the text chunks that describe how each image was made
+func Metadata(opts *MetadataOptions) error {
+	if err := opts.check(); err != nil {
+		return err
+	}
+	out := opts.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	metadata := make(map[string]map[string]string, len(opts.Images))
+	for _, image := range opts.Images {
+		fh, err := os.Open(image)
+		if err != nil {
+			return err
+		}
+		text, err := draw.ReadText(fh)
+		fh.Close()
+		if err != nil {
+			return fmt.Errorf("could not read image metadata (%v): %v", image, err)
+		}
+		metadata[image] = text
+	}
+	if opts.JSON {
+		data, err := json.MarshalIndent(metadata, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	}
+	// print a line for each keyword of each image, in the order given
+	for _, image := range opts.Images {
+		keywords := make([]string, 0, len(metadata[image]))
+		for keyword := range metadata[image] {
+			keywords = append(keywords, keyword)
+		}
+		sort.Strings(keywords)
+		for _, keyword := range keywords {
+			if _, err := fmt.Fprintf(out, "%v\t%v\t%q\n", image, keyword, metadata[image][keyword]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package run
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// test the metadata subcommand reads back the text chunks written by hammer
+func TestMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thor-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	hammerOpts := &HammerOptions{
+		OTUtables:      []string{testTable},
+		Format:         "qiime",
+		ColourSketches: makeTestStore(t, dir),
+		Padding:        true,
+		OutFile:        filepath.Join(dir, "test"),
+	}
+	if err := Hammer(hammerOpts); err != nil {
+		t.Fatal(err)
+	}
+	if err := Metadata(&MetadataOptions{}); err == nil {
+		t.Fatal("no images should return an error")
+	}
+	image := hammerOpts.imageFile("700114607")
+	var out bytes.Buffer
+	opts := &MetadataOptions{Images: []string{image}, JSON: true, Out: &out}
+	if err := Metadata(opts); err != nil {
+		t.Fatal(err)
+	}
+	metadata := make(map[string]map[string]string)
+	if err := json.Unmarshal(out.Bytes(), &metadata); err != nil {
+		t.Fatal(err)
+	}
+	text := metadata[image]
+	if text[TEXT_SAMPLE] != "700114607" || text[TEXT_TABLE] != testTable || text[TEXT_STORE_SHA256] == "" {
+		t.Fatalf("incorrect image metadata: %v", text)
+	}
+	var rows []string
+	if err := json.Unmarshal([]byte(text[TEXT_ROWS]), &rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 || rows[0] == "" {
+		t.Fatalf("incorrect row mapping: %v", rows)
+	}
+	// the plain output has a line per keyword
+	out.Reset()
+	opts.JSON = false
+	if err := Metadata(opts); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != len(text) {
+		t.Fatalf("expected %d lines, got %d", len(text), len(lines))
+	}
+	// the report is not an image
+	opts.Images = []string{hammerOpts.OutFile + REPORT_EXTENSION}
+	if err := Metadata(opts); err == nil {
+		t.Fatal("non-PNG input should return an error")
+	}
+}
@@ -0,0 +1,319 @@
+package run
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	hVersion "github.com/will-rowe/hulk/src/version"
+	"github.com/will-rowe/thor/src/colour"
+	"github.com/will-rowe/thor/src/hammer"
+	"github.com/will-rowe/thor/src/pool"
+	"github.com/will-rowe/thor/src/spectrum"
+	"github.com/will-rowe/thor/src/version"
+)
+
+// REPORT_EXTENSION is the suffix of the JSON provenance sidecar written for each hammer run
+const REPORT_EXTENSION = ".thor-report.json"
+
+// HammerReport is the machine readable record of a hammer run, so that any image can be traced back to how it was made
+type HammerReport struct {
+	ThorVersion string          `json:"thor_version"`
+	HulkVersion string          `json:"hulk_version"`
+	Started     time.Time       `json:"started"`
+	Finished    time.Time       `json:"finished"`
+	Settings    ReportSettings  `json:"settings"`
+	Inputs      []ReportInput   `json:"inputs"`
+	Store       ReportStore     `json:"store"`
+	Samples     []*ReportSample `json:"samples"`
+}
+
+// ReportSettings records the hammer options that change how the images are made
+type ReportSettings struct {
+	Format        string              `json:"format"`
+	Rank          string              `json:"rank,omitempty"`
+	Label         string              `json:"label,omitempty"`
+	MinConfidence float64             `json:"min_confidence"`
+	RowOrder      string              `json:"row_order"`
+	Padding       bool                `json:"padding"`
+	Abundance     string              `json:"abundance"`
+	AbundanceCap  float64             `json:"abundance_cap"`
+	BitDepth      int                 `json:"bit_depth"`
+	Filter        hammer.Filter       `json:"filter"`
+	Augment       hammer.Augmentation `json:"augment"`
+	Tensor        string              `json:"tensor,omitempty"`
+	Channels      []string            `json:"channels,omitempty"`
+}
+
+// ReportInput records an input file and its checksum
+type ReportInput struct {
+	Role   string `json:"role"`
+	Path   string `json:"path"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// ReportStore records the colour sketch store used for the images, along with the spectrum it was built with (if known)
+type ReportStore struct {
+	Path         string             `json:"path"`
+	SHA256       string             `json:"sha256"`
+	NumSketches  int                `json:"num_sketches"`
+	SketchLength int                `json:"sketch_length"`
+	Spectrum     *spectrum.Spectrum `json:"spectrum"`
+}
+
+// ReportSample records how the image for a sample was made
+type ReportSample struct {
+	Sample         string              `json:"sample"`
+	Table          string              `json:"table"`
+	Source         string              `json:"source,omitempty"`
+	Variant        int                 `json:"variant,omitempty"`
+	Image          string              `json:"image"`
+	Legend         string              `json:"legend"`
+	Tensor         string              `json:"tensor,omitempty"`
+	Drawn          int                 `json:"drawn"`
+	Missing        int                 `json:"missing"`
+	Padded         int                 `json:"padded"`
+	MissingGenera  []string            `json:"missing_genera,omitempty"`
+	TotalAbundance float64             `json:"total_abundance"`
+	Normalisation  ReportNormalisation `json:"normalisation"`
+	Error          string              `json:"error,omitempty"`
+}
+
+// ReportNormalisation records how the abundances of a sample were scaled to pixel values
+type ReportNormalisation struct {
+	Relative     bool    `json:"relative"`
+	AbundanceCap float64 `json:"abundance_cap"`
+	Levels       int     `json:"levels"`
+}
+
+// normalisation is a method to get how the abundances of a table are scaled to pixel values
+func (opts *HammerOptions) normalisation(table *hammer.OTUTable) ReportNormalisation {
+	return ReportNormalisation{
+		Relative:     table.IsRelative(),
+		AbundanceCap: table.GetAbundanceCap(),
+		Levels:       1 << uint(opts.BitDepth),
+	}
+}
+
+// Dump is a method to write the report to disk as indented JSON
+func (report *HammerReport) Dump(path string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	fh, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := fh.Write(append(data, '\n')); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
+
+// AddInput is a method to record an input file, along with its size and checksum
+func (report *HammerReport) AddInput(role, path string) error {
+	fh, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	cr := newChecksumReader(fh)
+	if _, err := io.Copy(ioutil.Discard, cr); err != nil {
+		return err
+	}
+	report.Inputs = append(report.Inputs, cr.input(role, path))
+	return nil
+}
+
+// checksumReader records the size and checksum of the data read through it
+type checksumReader struct {
+	r     io.Reader
+	hash  hash.Hash
+	bytes int64
+}
+
+// newChecksumReader is the checksumReader constructor
+func newChecksumReader(r io.Reader) *checksumReader {
+	return &checksumReader{r: r, hash: sha256.New()}
+}
+
+// Read is a method to satisfy the io.Reader interface
+func (cr *checksumReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.hash.Write(p[:n])
+	cr.bytes += int64(n)
+	return n, err
+}
+
+// input is a method to get the record of the data read so far
+func (cr *checksumReader) input(role, path string) ReportInput {
+	return ReportInput{role, path, cr.bytes, hex.EncodeToString(cr.hash.Sum(nil))}
+}
+
+// LoadHammerReport reads a hammer report from disk
+func LoadHammerReport(path string) (*HammerReport, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	report := &HammerReport{}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// newReport is a method to start the report for a hammer run, recording the settings and the input files other than the OTU tables
+func (opts *HammerOptions) newReport() (*HammerReport, error) {
+	report := &HammerReport{
+		ThorVersion: version.VERSION,
+		HulkVersion: hVersion.VERSION,
+		Started:     time.Now().UTC(),
+		Settings: ReportSettings{
+			Format:        opts.Format,
+			Rank:          opts.Rank,
+			Label:         opts.Label,
+			MinConfidence: opts.MinConfidence,
+			RowOrder:      opts.RowOrder,
+			Padding:       opts.Padding,
+			BitDepth:      opts.BitDepth,
+			Filter:        opts.Filter,
+			Augment:       opts.Augment,
+			Tensor:        opts.Tensor,
+		},
+	}
+	for _, channel := range opts.channels {
+		report.Settings.Channels = append(report.Settings.Channels, channel.Name)
+	}
+	inputs := [][2]string{
+		{"taxonomy", opts.Taxonomy},
+		{"synonyms", opts.Synonyms},
+		{"order", opts.OrderFile},
+		{"tree", opts.Tree},
+		{"spectrum", opts.Spectrum},
+	}
+	if opts.Taxdump != "" {
+		inputs = append(inputs, [2]string{"taxdump", filepath.Join(opts.Taxdump, hammer.TAXDUMP_NAMES)}, [2]string{"taxdump", filepath.Join(opts.Taxdump, hammer.TAXDUMP_NODES)})
+	}
+	for _, input := range inputs {
+		if input[1] == "" {
+			continue
+		}
+		if err := report.AddInput(input[0], input[1]); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// addTableInputs is a method to record the OTU tables once they have been read, so that a table read from STDIN has its checksum
+func (opts *HammerOptions) addTableInputs(report *HammerReport) error {
+	for _, path := range opts.OTUtables {
+		if path != STDIN {
+			if err := report.AddInput("otu_table", path); err != nil {
+				return err
+			}
+			continue
+		}
+		// the table reader may stop before the end of the data
+		if _, err := io.Copy(ioutil.Discard, opts.stdin); err != nil {
+			return err
+		}
+		report.Inputs = append(report.Inputs, opts.stdin.input("otu_table", path))
+	}
+	return nil
+}
+
+// SetStore is a method to record the colour sketch store used for the images
+func (report *HammerReport) SetStore(path string, colourStore colour.ColourSketchStore, storeSpec *spectrum.Spectrum) error {
+	fh, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	cr := newChecksumReader(fh)
+	if _, err := io.Copy(ioutil.Discard, cr); err != nil {
+		return err
+	}
+	report.Store = ReportStore{
+		Path:         path,
+		SHA256:       cr.input("store", path).SHA256,
+		SketchLength: colourStore.GetSketchLength(),
+		Spectrum:     storeSpec,
+	}
+	for genus := range colourStore {
+		if genus != hammer.PAD_LINE {
+			report.Store.NumSketches++
+		}
+	}
+	return nil
+}
+
+// addSampleReports is a method to record how the image for each sample was made, along with any failures
+// it is called for each table in turn, and the samples are added to those already in the report
+func (opts *HammerOptions) addSampleReports(report *HammerReport, jobs []sampleJob, colourStore colour.ColourSketchStore, sketchLength int, failures []pool.Failure) error {
+	samples := make([]*ReportSample, len(jobs))
+	for i, job := range jobs {
+		rows, err := job.table.GetRows(job.index, colourStore, opts.Padding)
+		if err != nil {
+			return err
+		}
+		sampleData, err := job.table.GetSampleData(job.index)
+		if err != nil {
+			return err
+		}
+		sample := &ReportSample{
+			Sample:        job.sample,
+			Table:         job.tableName,
+			Image:         opts.imageFile(job.sample),
+			Legend:        opts.legendFile(job.sample),
+			Normalisation: opts.normalisation(job.table),
+		}
+		if opts.Tensor != "" {
+			sample.Tensor = opts.tensorFile(job.sample)
+		}
+		if job.variant != nil {
+			sample.Source = job.variant.Source
+			sample.Variant = job.variant.Variant
+		}
+		for _, abundance := range sampleData {
+			sample.TotalAbundance += abundance
+		}
+		var imageRows int
+		for _, row := range rows {
+			switch row.Status {
+			case hammer.ROW_DRAWN:
+				sample.Drawn++
+			case hammer.ROW_MISSING:
+				sample.Missing++
+				sample.MissingGenera = append(sample.MissingGenera, row.Genus)
+			case hammer.ROW_PADDING:
+				if row.Index != -1 {
+					sample.Padded++
+				}
+			}
+			if row.Index != -1 {
+				imageRows++
+			}
+		}
+		// the rest of a padded image is filled with padding rows
+		if opts.Padding && imageRows < sketchLength {
+			sample.Padded += sketchLength - imageRows
+		}
+		samples[i] = sample
+	}
+	for _, failure := range failures {
+		samples[failure.Index].Error = failure.Err.Error()
+	}
+	report.Samples = append(report.Samples, samples...)
+	return nil
+}
@@ -0,0 +1,78 @@
+package run
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/will-rowe/thor/src/version"
+)
+
+// test the hammer subcommand writes a run report
+func TestHammerReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thor-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	opts := &HammerOptions{
+		OTUtables:      []string{testTable},
+		Format:         "qiime",
+		ColourSketches: makeTestStore(t, dir),
+		Padding:        true,
+		OutFile:        filepath.Join(dir, "test"),
+	}
+	if err := Hammer(opts); err != nil {
+		t.Fatal(err)
+	}
+	report, err := LoadHammerReport(opts.OutFile + REPORT_EXTENSION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ThorVersion != version.VERSION || report.Settings.Format != "qiime" || !report.Settings.Padding || report.Settings.Abundance != "counts" || report.Settings.AbundanceCap != 5000 {
+		t.Fatalf("incorrect run settings: %+v", report)
+	}
+	data, err := ioutil.ReadFile(testTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum := sha256.Sum256(data)
+	if len(report.Inputs) != 1 || report.Inputs[0].Path != testTable || report.Inputs[0].SHA256 != hex.EncodeToString(checksum[:]) || report.Inputs[0].Bytes != int64(len(data)) {
+		t.Fatalf("incorrect inputs: %+v", report.Inputs)
+	}
+	if report.Store.NumSketches != len(genera) || report.Store.SketchLength != 4 || report.Store.SHA256 == "" {
+		t.Fatalf("incorrect store: %+v", report.Store)
+	}
+	if len(report.Samples) != 1 {
+		t.Fatalf("expected 1 sample, got %d", len(report.Samples))
+	}
+	sample := report.Samples[0]
+	if sample.Sample != "700114607" || sample.Image != opts.imageFile("700114607") || sample.Table != testTable {
+		t.Fatalf("incorrect sample: %+v", sample)
+	}
+	if sample.Drawn+sample.Padded != 4 || sample.Missing != 0 || sample.TotalAbundance == 0 || sample.Normalisation.Levels != 256 {
+		t.Fatalf("incorrect sample counts: %+v", sample)
+	}
+	// a table read from STDIN has the same checksum
+	fh, err := os.Open(testTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+	opts.OTUtables = []string{STDIN}
+	opts.Stdin = fh
+	opts.OutFile = filepath.Join(dir, "stdin")
+	if err := Hammer(opts); err != nil {
+		t.Fatal(err)
+	}
+	stdinReport, err := LoadHammerReport(opts.OutFile + REPORT_EXTENSION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdinReport.Inputs[0].SHA256 != report.Inputs[0].SHA256 {
+		t.Fatal("checksum of a table read from STDIN does not match")
+	}
+}
@@ -0,0 +1,38 @@
+// run contains the library functions that carry out the thor subcommands
+// each function takes an options struct and returns an error, so that thor can be embedded and tested
+
+package run
+
+import (
+	"fmt"
+	"os"
+)
+
+// checkFile makes sure a file exists and can be accessed
+func checkFile(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("file does not exist: %v", path)
+		}
+		return fmt.Errorf("can't access file (check permissions): %v", path)
+	}
+	return nil
+}
+
+// checkSupported makes sure a value is in a list of supported values
+func checkSupported(value string, supported []string) bool {
+	for _, s := range supported {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
+
+// getWorkers returns the number of workers to use for a number of processors
+func getWorkers(processors int) int {
+	if processors < 1 {
+		return 1
+	}
+	return processors
+}
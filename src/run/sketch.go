@@ -0,0 +1,301 @@
+package run
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/will-rowe/hulk/src/histosketch"
+	"github.com/will-rowe/hulk/src/stream"
+	hVersion "github.com/will-rowe/hulk/src/version"
+	"github.com/will-rowe/thor/src/pool"
+	"github.com/will-rowe/thor/src/reads"
+	"github.com/will-rowe/thor/src/spectrum"
+)
+
+// SketchOptions holds the options for the sketch subcommand
+type SketchOptions struct {
+	Fasta         string  // FASTA file(s) to sketch, will perform a glob using the given string
+	Fastq         string  // FASTQ file(s) to sketch, will perform a glob using the given string
+	Paired        bool    // pair up R1/R2 FASTQ files and sketch each pair together
+	QualTrim      bool    // quality trim the ends of FASTQ reads
+	MinQual       int     // minimum base quality used when trimming
+	MinReadLength int     // minimum read length after trimming (0 uses the k-mer size)
+	SketchAlgo    string  // the sketching algorithm to use (histosketch or minhash)
+	KmerSize      int     // size of k-mer
+	Epsilon       float64 // epsilon value for countminsketch generation
+	Delta         float64 // delta value for countminsketch generation
+	MinCount      int     // minimum count number for a kmer to be added to the histosketch from this interval
+	SketchSize    uint    // size of sketch
+	ReuseSpectrum string  // a spectrum file from a previous run, to make sure the sketches are comparable
+	OutFile       string  // basename for the outfile(s)
+	Processors    int     // number of processors to use
+	samples       []reads.Sample
+}
+
+// sketchParams holds the parameters needed by the sketching pipeline
+type sketchParams struct {
+	fasta         bool
+	kSize         int
+	minCount      int
+	sketchSize    uint
+	qualTrim      bool
+	minQual       int
+	minReadLength int
+	numCPU        int
+}
+
+// check is a method to check the user supplied parameters
+func (opts *SketchOptions) check() error {
+	// check the algorithm is minhash or histosketch
+	if !checkSupported(opts.SketchAlgo, []string{"histosketch", "minhash"}) {
+		return fmt.Errorf("--sketchAlgo must be either histosketch or minhash")
+	}
+	log.Printf("\tsketching algorithm: %v", opts.SketchAlgo)
+	// load the parameters from a previous run if requested
+	if opts.ReuseSpectrum != "" {
+		if err := loadSpectrumParams(opts.ReuseSpectrum, &opts.Epsilon, &opts.Delta, &opts.KmerSize, &opts.MinCount, &opts.SketchSize); err != nil {
+			return err
+		}
+		log.Printf("\treusing spectrum: %v", opts.ReuseSpectrum)
+	}
+	if opts.KmerSize < 1 || opts.SketchSize < 1 {
+		return fmt.Errorf("k-mer size and sketch size must be greater than 0")
+	}
+	// check the read options
+	if opts.Fasta != "" && opts.Fastq != "" {
+		return fmt.Errorf("supply either --fasta or --fastq, not both")
+	}
+	if opts.Fastq == "" && (opts.Paired || opts.QualTrim) {
+		return fmt.Errorf("--paired and --qualTrim can only be used with --fastq")
+	}
+	if opts.MinQual < 0 || opts.MinReadLength < 0 {
+		return fmt.Errorf("--minQual and --minReadLength can't be negative")
+	}
+	if opts.MinReadLength == 0 {
+		opts.MinReadLength = opts.KmerSize
+	}
+	// check if using STDIN or file(s)
+	if opts.Fasta == "" && opts.Fastq == "" {
+		stat, err := os.Stdin.Stat()
+		if err != nil {
+			return fmt.Errorf("error with STDIN")
+		}
+		if (stat.Mode() & os.ModeNamedPipe) == 0 {
+			return fmt.Errorf("no STDIN found")
+		}
+		log.Printf("\tinput file: using STDIN")
+		return nil
+	}
+	// check the supplied file(s)
+	return opts.checkInputFiles()
+}
+
+// checkInputFiles is a method to check the files being read exist and are FASTQ/FASTA
+func (opts *SketchOptions) checkInputFiles() error {
+	glob, suffixes := opts.Fasta, []string{"fasta", "fna", "fa"}
+	if opts.Fastq != "" {
+		glob, suffixes = opts.Fastq, []string{"fastq", "fq"}
+	}
+	inputFiles, err := filepath.Glob(glob)
+	if err != nil {
+		return err
+	}
+	if len(inputFiles) == 0 {
+		return fmt.Errorf("no files found matching: %v", glob)
+	}
+	for _, inputFile := range inputFiles {
+		if err := checkFile(inputFile); err != nil {
+			return err
+		}
+		splitFilename := strings.Split(inputFile, ".")
+		var ext string
+		if splitFilename[len(splitFilename)-1] == "gz" {
+			ext = splitFilename[len(splitFilename)-2]
+		} else {
+			ext = splitFilename[len(splitFilename)-1]
+		}
+		if ext == "" {
+			return fmt.Errorf("could not parse filename")
+		}
+		if !checkSupported(ext, suffixes) {
+			return fmt.Errorf("does not look like a %v file: %v", suffixes[0], inputFile)
+		}
+	}
+	// group the files into samples
+	if opts.Paired {
+		opts.samples, err = reads.PairFiles(inputFiles)
+		return err
+	}
+	opts.samples = reads.SingleFiles(inputFiles)
+	return nil
+}
+
+// Sketch runs the sketch subcommand, histosketching each sample and recording the spectrum used
+func Sketch(opts *SketchOptions) error {
+	log.Printf("starting the sketch subcommand")
+	log.Printf("\tuses: hulk (version %s)", hVersion.VERSION)
+	// check the supplied files and then log some stuff
+	log.Printf("checking parameters...")
+	if err := opts.check(); err != nil {
+		return err
+	}
+	log.Printf("\tinput files:")
+	for _, sample := range opts.samples {
+		log.Printf("\t\t%v: %v", sample.Name, strings.Join(sample.Files, ", "))
+	}
+	log.Printf("\toutput file basename: %v", opts.OutFile)
+	log.Printf("\tno. processors: %d", opts.Processors)
+	log.Printf("\tk-mer size: %d", opts.KmerSize)
+	log.Printf("\tmin. k-mer count: %d", opts.MinCount)
+	if opts.Fastq != "" {
+		log.Printf("\tpaired reads: %t", opts.Paired)
+		log.Printf("\tquality trimming: %t", opts.QualTrim)
+		if opts.QualTrim {
+			log.Printf("\tmin. base quality: %d", opts.MinQual)
+			log.Printf("\tmin. read length: %d", opts.MinReadLength)
+		}
+	}
+	log.Printf("\tsketch size: %d", opts.SketchSize)
+	// create the base countmin sketch for recording the k-mer spectrum
+	log.Printf("creating the base countmin sketch for kmer counting...")
+	// TODO: epsilon and delta values need some checking
+	cms := histosketch.NewCountMinSketch(opts.Epsilon, opts.Delta, 1.0)
+	log.Printf("\tnumber of tables: %d", cms.Tables())
+	log.Printf("\tnumber of counters per table: %d", cms.Counters())
+	params := sketchParams{
+		fasta:         (opts.Fastq == ""),
+		kSize:         opts.KmerSize,
+		minCount:      opts.MinCount,
+		sketchSize:    opts.SketchSize,
+		qualTrim:      opts.QualTrim,
+		minQual:       opts.MinQual,
+		minReadLength: opts.MinReadLength,
+	}
+	// record the spectrum so that later runs and commands can check compatibility
+	spec := newSpectrum(cms, opts.Epsilon, opts.Delta, params)
+	if err := checkReusedSpectrum(opts.ReuseSpectrum, spec); err != nil {
+		return err
+	}
+	if err := spec.Dump(opts.OutFile + spectrum.EXTENSION); err != nil {
+		return err
+	}
+	log.Printf("\tspectrum written to: %v", opts.OutFile+spectrum.EXTENSION)
+	log.Printf("sketching %d samples...", len(opts.samples))
+	// sketch the samples using a bounded number of pipelines, sharing the processors between them
+	workers := pool.NewPool(getWorkers(opts.Processors))
+	if workers.GetWorkers() >= len(opts.samples) && len(opts.samples) != 0 {
+		params.numCPU = getWorkers(opts.Processors) / len(opts.samples)
+	} else {
+		params.numCPU = 1
+	}
+	log.Printf("\trunning %d pipelines at a time, each with %d processors", workers.GetWorkers(), params.numCPU)
+	workers.SetProgress(func(done, total int) {
+		log.Printf("\tsketched %d/%d", done, total)
+	})
+	failures := workers.Run(len(opts.samples), func(i int) error {
+		sample := opts.samples[i]
+		return sketchSample(sample, cms, params, opts.OutFile+"-hulk."+sample.Name+".sketch")
+	})
+	// report any samples that failed
+	if len(failures) != 0 {
+		log.Printf("failed to sketch %d samples:", len(failures))
+		for _, failure := range failures {
+			log.Printf("\t%v (%v): %v", opts.samples[failure.Index].Name, strings.Join(opts.samples[failure.Index].Files, ", "), failure.Err)
+		}
+		return fmt.Errorf("failed to sketch %d of %d samples, see log for details", len(failures), len(opts.samples))
+	}
+	log.Printf("finished")
+	return nil
+}
+
+// sketchSample runs the hulk pipeline on the files of one sample and writes the histosketch to sketchFile
+// the hulk pipeline doesn't return errors, so any existing sketchFile is removed first and the sample fails if none is written
+func sketchSample(sample reads.Sample, cms *histosketch.CountMinSketch, params sketchParams, sketchFile string) error {
+	if err := os.Remove(sketchFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not remove existing sketch: %v", err)
+	}
+	// create the pipeline
+	pipeline := stream.NewPipeline()
+	// initialise processes
+	dataStream := stream.NewDataStreamer()
+	fastqHandler := stream.NewFastqHandler()
+	fastqChecker := stream.NewFastqChecker()
+	counter := stream.NewCounter()
+	sketcher := stream.NewSketcher()
+	// add in the process parameters TODO: consolidate and remove some of these
+	dataStream.InputFile = sample.Files
+	fastqHandler.Fasta, counter.Fasta = params.fasta, params.fasta
+	fastqChecker.Ksize, counter.Ksize = params.kSize, params.kSize
+	counter.Interval = 0
+	counter.Spectrum, sketcher.Spectrum = cms.Copy(), cms.Copy()
+	counter.NumCPU, sketcher.NumCPU = params.numCPU, params.numCPU
+	counter.SketchSize, sketcher.SketchSize = params.sketchSize, params.sketchSize
+	counter.ChunkSize = -1
+	sketcher.MinCount = float64(params.minCount)
+	sketcher.DecayRatio = 1.0
+	sketcher.OutFile = sketchFile
+	// arrange pipeline processes
+	fastqHandler.Input = dataStream.Output
+	fastqChecker.Input = fastqHandler.Output
+	counter.Input = fastqChecker.Output
+	sketcher.Input = counter.TheCollector
+	// add the quality trimmer between the handler and the checker if requested
+	if params.qualTrim {
+		trimmer := reads.NewQualityTrimmer()
+		trimmer.MinQual, trimmer.MinReadLength = params.minQual, params.minReadLength
+		trimmer.Input = fastqHandler.Output
+		fastqChecker.Input = trimmer.Output
+		pipeline.AddProcesses(dataStream, fastqHandler, trimmer, fastqChecker, counter, sketcher)
+	} else {
+		pipeline.AddProcesses(dataStream, fastqHandler, fastqChecker, counter, sketcher)
+	}
+	// run the pipeline
+	pipeline.Run()
+	if _, err := os.Stat(sketchFile); err != nil {
+		return fmt.Errorf("no sketch written")
+	}
+	return nil
+}
+
+// newSpectrum records the parameters used by the sketching pipeline, along with a fingerprint of how hulk's countmin sketch hashes
+func newSpectrum(cms *histosketch.CountMinSketch, epsilon, delta float64, params sketchParams) *spectrum.Spectrum {
+	probe := histosketch.NewCountMinSketch(spectrum.PROBE_EPSILON, spectrum.PROBE_DELTA, 1.0)
+	return &spectrum.Spectrum{
+		Epsilon:         epsilon,
+		Delta:           delta,
+		Tables:          int64(cms.Tables()),
+		Counters:        int64(cms.Counters()),
+		KmerSize:        params.kSize,
+		SketchSize:      params.sketchSize,
+		MinCount:        params.minCount,
+		DecayRatio:      1.0,
+		HulkVersion:     hVersion.VERSION,
+		HashFingerprint: spectrum.Fingerprint(probe.Add),
+	}
+}
+
+// loadSpectrumParams loads a spectrum file and overwrites the supplied sketching parameters with the recorded ones
+func loadSpectrumParams(path string, epsilon, delta *float64, kSize, minCount *int, sketchSize *uint) error {
+	prev := &spectrum.Spectrum{}
+	if err := prev.Load(path); err != nil {
+		return fmt.Errorf("could not load spectrum file (%v): %v", path, err)
+	}
+	*epsilon, *delta = prev.Epsilon, prev.Delta
+	*kSize, *minCount, *sketchSize = prev.KmerSize, prev.MinCount, prev.SketchSize
+	return nil
+}
+
+// checkReusedSpectrum makes sure that a new spectrum is compatible with a reused one (if one was supplied)
+func checkReusedSpectrum(path string, spec *spectrum.Spectrum) error {
+	if path == "" {
+		return nil
+	}
+	prev := &spectrum.Spectrum{}
+	if err := prev.Load(path); err != nil {
+		return err
+	}
+	return prev.Compatible(spec)
+}
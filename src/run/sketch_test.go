@@ -0,0 +1,33 @@
+package run
+
+import (
+	"testing"
+)
+
+// test the sketch options are checked before any sketching starts
+func TestSketchOptions(t *testing.T) {
+	opts := &SketchOptions{
+		Fasta:      "./missing/*.fasta",
+		SketchAlgo: "histosketch",
+		KmerSize:   21,
+		SketchSize: 200,
+	}
+	if err := opts.check(); err == nil {
+		t.Fatal("glob matching no files should return an error")
+	}
+	opts.SketchAlgo = "bloomfilter"
+	if err := opts.check(); err == nil {
+		t.Fatal("unsupported sketch algorithm should return an error")
+	}
+	opts.SketchAlgo = "histosketch"
+	opts.Fastq = "./missing/*.fastq"
+	if err := opts.check(); err == nil {
+		t.Fatal("supplying --fasta and --fastq should return an error")
+	}
+	opts.Fasta, opts.Paired = "", false
+	opts.QualTrim = true
+	opts.Fastq = ""
+	if err := opts.check(); err == nil {
+		t.Fatal("--qualTrim without --fastq should return an error")
+	}
+}
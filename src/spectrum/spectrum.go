@@ -0,0 +1,182 @@
+// spectrum contains the types/methods/functions to record and check the parameters used to build a set of histosketches
+
+package spectrum
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// EXTENSION is the file extension used for spectrum files
+const EXTENSION = ".spectrum"
+
+// the parameters of the small countmin sketch used to make a hash fingerprint, it is small so that the probe keys collide
+const (
+	PROBE_EPSILON = 0.1
+	PROBE_DELTA   = 0.01
+	PROBE_KEYS    = 1024
+	PROBE_SEED    = 0x74686f72 // "thor"
+)
+
+// Spectrum records the countmin sketch and histosketch parameters used to build a set of sketches
+// hulk does not expose the seeds for its hash functions, so a fingerprint of how the countmin sketch hashes is recorded instead
+type Spectrum struct {
+	Epsilon         float64 `json:"epsilon"`
+	Delta           float64 `json:"delta"`
+	Tables          int64   `json:"tables"`
+	Counters        int64   `json:"counters"`
+	KmerSize        int     `json:"kmer_size"`
+	SketchSize      uint    `json:"sketch_size"`
+	MinCount        int     `json:"min_count"`
+	DecayRatio      float64 `json:"decay_ratio"`
+	HulkVersion     string  `json:"hulk_version"`
+	HashFingerprint string  `json:"hash_fingerprint"`
+}
+
+// Fingerprint returns a digest of how a countmin sketch hashes, given the Add method of an empty sketch (see the PROBE constants)
+// a fixed set of probe keys is added to the sketch and the estimates it returns depend on which probes share counters,
+// so sketches that use different hash functions or seeds get different fingerprints, whatever machine they were built on
+func Fingerprint(add func(key uint64, increment float64) float64) string {
+	digest := sha256.New()
+	buf := make([]byte, 8)
+	key := uint64(PROBE_SEED)
+	for i := 0; i < PROBE_KEYS; i++ {
+		// splitmix64, so the probe keys are spread over the key space like hashed k-mers
+		key += 0x9e3779b97f4a7c15
+		probe := key
+		probe = (probe ^ (probe >> 30)) * 0xbf58476d1ce4e5b9
+		probe = (probe ^ (probe >> 27)) * 0x94d049bb133111eb
+		probe ^= probe >> 31
+		binary.LittleEndian.PutUint64(buf, math.Float64bits(add(probe, 1)))
+		digest.Write(buf)
+	}
+	return hex.EncodeToString(digest.Sum(nil))
+}
+
+// Dump a Spectrum to disk
+func (spectrum *Spectrum) Dump(path string) error {
+	b, err := msgpack.Marshal(spectrum)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// Load a Spectrum from disk
+func (spectrum *Spectrum) Load(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return msgpack.Unmarshal(b, spectrum)
+}
+
+// Compatible is a method to check that sketches built with two spectrums can be compared
+// it returns an error listing every parameter that differs, spectrums without a hash fingerprint can't be checked so they are never compatible
+func (spectrum *Spectrum) Compatible(other *Spectrum) error {
+	var mismatches []string
+	if spectrum.Epsilon != other.Epsilon {
+		mismatches = append(mismatches, fmt.Sprintf("epsilon (%v : %v)", spectrum.Epsilon, other.Epsilon))
+	}
+	if spectrum.Delta != other.Delta {
+		mismatches = append(mismatches, fmt.Sprintf("delta (%v : %v)", spectrum.Delta, other.Delta))
+	}
+	if spectrum.Tables != other.Tables || spectrum.Counters != other.Counters {
+		mismatches = append(mismatches, fmt.Sprintf("countmin dimensions (%dx%d : %dx%d)", spectrum.Tables, spectrum.Counters, other.Tables, other.Counters))
+	}
+	if spectrum.KmerSize != other.KmerSize {
+		mismatches = append(mismatches, fmt.Sprintf("k-mer size (%d : %d)", spectrum.KmerSize, other.KmerSize))
+	}
+	if spectrum.SketchSize != other.SketchSize {
+		mismatches = append(mismatches, fmt.Sprintf("sketch size (%d : %d)", spectrum.SketchSize, other.SketchSize))
+	}
+	if spectrum.MinCount != other.MinCount {
+		mismatches = append(mismatches, fmt.Sprintf("min. k-mer count (%d : %d)", spectrum.MinCount, other.MinCount))
+	}
+	if spectrum.DecayRatio != other.DecayRatio {
+		mismatches = append(mismatches, fmt.Sprintf("decay ratio (%v : %v)", spectrum.DecayRatio, other.DecayRatio))
+	}
+	if spectrum.HulkVersion != other.HulkVersion {
+		mismatches = append(mismatches, fmt.Sprintf("hulk version (%v : %v)", spectrum.HulkVersion, other.HulkVersion))
+	}
+	if spectrum.HashFingerprint == "" || other.HashFingerprint == "" {
+		mismatches = append(mismatches, "hash fingerprint (missing, rebuild the sketches to record one)")
+	} else if spectrum.HashFingerprint != other.HashFingerprint {
+		mismatches = append(mismatches, fmt.Sprintf("hash fingerprint (%.12v : %.12v)", spectrum.HashFingerprint, other.HashFingerprint))
+	}
+	if len(mismatches) != 0 {
+		return fmt.Errorf("incompatible sketch spectrums, mismatched %v", strings.Join(mismatches, ", "))
+	}
+	return nil
+}
+
+// SidecarPath returns the path of the spectrum file that accompanies a file (e.g. a colour sketch store)
+func SidecarPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + EXTENSION
+}
+
+// LoadSidecar will load the spectrum file that accompanies a file, returning nil if there isn't one
+func LoadSidecar(path string) (*Spectrum, error) {
+	sidecar := SidecarPath(path)
+	if _, err := os.Stat(sidecar); os.IsNotExist(err) {
+		return nil, nil
+	}
+	spectrum := &Spectrum{}
+	if err := spectrum.Load(sidecar); err != nil {
+		return nil, fmt.Errorf("could not load spectrum file (%v): %v", sidecar, err)
+	}
+	return spectrum, nil
+}
+
+// FindSpectrums will find and load all the spectrum files in a directory
+func FindSpectrums(dir string, recursive bool) (map[string]*Spectrum, error) {
+	spectrums := make(map[string]*Spectrum)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != filepath.Clean(dir) && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != EXTENSION {
+			return nil
+		}
+		spectrum := &Spectrum{}
+		if err := spectrum.Load(path); err != nil {
+			return fmt.Errorf("could not load spectrum file (%v): %v", path, err)
+		}
+		spectrums[path] = spectrum
+		return nil
+	})
+	return spectrums, err
+}
+
+// CheckAll makes sure that all the spectrums in a set are compatible, returning the shared spectrum
+// if the set is empty, nil is returned
+func CheckAll(spectrums map[string]*Spectrum) (*Spectrum, error) {
+	var refPath string
+	var ref *Spectrum
+	for path, spectrum := range spectrums {
+		if ref == nil || path < refPath {
+			refPath, ref = path, spectrum
+		}
+	}
+	for path, spectrum := range spectrums {
+		if err := ref.Compatible(spectrum); err != nil {
+			return nil, fmt.Errorf("%v vs %v: %v", refPath, path, err)
+		}
+	}
+	return ref, nil
+}
@@ -0,0 +1,86 @@
+package spectrum
+
+import (
+	"math"
+	"os"
+	"testing"
+)
+
+var (
+	specA = &Spectrum{Epsilon: 0.00001, Delta: 0.9, Tables: 3, Counters: 271829, KmerSize: 21, SketchSize: 200, MinCount: 1, DecayRatio: 1.0, HulkVersion: "0.0.1", HashFingerprint: "abc123"}
+	specB = &Spectrum{Epsilon: 0.00001, Delta: 0.9, Tables: 3, Counters: 271829, KmerSize: 31, SketchSize: 200, MinCount: 1, DecayRatio: 1.0, HulkVersion: "0.0.1", HashFingerprint: "abc123"}
+)
+
+// test the compatibility check
+func TestCompatible(t *testing.T) {
+	if err := specA.Compatible(specA); err != nil {
+		t.Fatal(err)
+	}
+	if err := specA.Compatible(specB); err == nil {
+		t.Fatal("spectrums with different k-mer sizes should not be compatible")
+	}
+	for _, fingerprint := range []string{"", "def456"} {
+		other := *specA
+		other.HashFingerprint = fingerprint
+		if err := specA.Compatible(&other); err == nil {
+			t.Fatalf("spectrums with hash fingerprints %v and %v should not be compatible", specA.HashFingerprint, fingerprint)
+		}
+	}
+	if _, err := CheckAll(map[string]*Spectrum{"a": specA, "b": specB}); err == nil {
+		t.Fatal("CheckAll should fail for incompatible spectrums")
+	}
+	if spec, err := CheckAll(map[string]*Spectrum{}); err != nil || spec != nil {
+		t.Fatal("CheckAll should return nil for an empty set")
+	}
+}
+
+// test the dump and load methods
+func TestDumpLoad(t *testing.T) {
+	if err := specA.Dump("./test.spectrum"); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := LoadSidecar("./test.thor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec == nil {
+		t.Fatal("could not find spectrum sidecar")
+	}
+	if err := specA.Compatible(spec); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove("./test.spectrum"); err != nil {
+		t.Fatal(err)
+	}
+	if spec, err := LoadSidecar("./missing.thor"); err != nil || spec != nil {
+		t.Fatal("missing sidecar should return nil")
+	}
+}
+
+// testSketch is a countmin sketch with seeded hash functions, to check the fingerprints
+type testSketch struct {
+	seed     uint64
+	counters [4][16]float64
+}
+
+// Add is a method to add a key to the test sketch, returning the estimated count
+func (sketch *testSketch) Add(key uint64, increment float64) float64 {
+	estimate := math.MaxFloat64
+	for i := range sketch.counters {
+		j := ((key + uint64(i)*0xbf58476d1ce4e5b9) * (sketch.seed<<1 | 1) * 0x9e3779b97f4a7c15) >> 60
+		sketch.counters[i][j] += increment
+		estimate = math.Min(estimate, sketch.counters[i][j])
+	}
+	return estimate
+}
+
+// test that the fingerprint only depends on how the sketch hashes
+func TestFingerprint(t *testing.T) {
+	a, b, c := &testSketch{seed: 1}, &testSketch{seed: 1}, &testSketch{seed: 2}
+	if Fingerprint(a.Add) != Fingerprint(b.Add) {
+		t.Fatal("sketches with the same hash functions should have the same fingerprint")
+	}
+	if Fingerprint((&testSketch{seed: 1}).Add) == Fingerprint(c.Add) {
+		t.Fatal("sketches with different hash seeds should have different fingerprints")
+	}
+}